@@ -0,0 +1,338 @@
+package main
+
+import (
+	"chess-engine/engine"
+	"chess-engine/handlers"
+	"context"
+	"fmt"
+	"image/color"
+	"math"
+	"strconv"
+	"sync/atomic"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+// analysisPVMoves is how many moves of each line the analysis panel shows.
+const analysisPVMoves = 10
+
+const analysisPanelHeight = 220
+
+// threatDepth limits the search for the opponent's threat, which only needs to see the next few moves.
+const threatDepth = 5
+
+var (
+	// analysisOn runs the engine on whatever position the board shows.
+	analysisOn    bool
+	analysisLines = 3
+
+	// analyzedFEN is the position the running analysis is for.
+	analyzedFEN  string
+	stopAnalysis context.CancelFunc = func() {}
+	// analysisID goes up with every restart so results of a stopped analysis are dropped.
+	analysisID atomic.Int64
+
+	// showBestArrow and showThreatArrow draw the engine's best move and the opponent's
+	// threat, its best move if it could move again, as arrows on the board.
+	showBestArrow   = true
+	showThreatArrow bool
+	// analysisBest and analysisThreat are the moves behind those arrows, the zero Move
+	// until the analysis finds them. Like the arrows they are only used on the UI thread.
+	analysisBest, analysisThreat handlers.Move
+	engineArrows                 []boardArrow
+
+	analysisPanel *fyne.Container
+	analysisText  *widget.Label
+	scoreBar      *evalBar
+)
+
+// setAnalysis turns analysis mode on or off.
+func setAnalysis(on bool) {
+	analysisOn = on
+	analyzedFEN = ""
+	if on {
+		analysisPanel.Show()
+		scoreBar.Show()
+	} else {
+		analysisPanel.Hide()
+		scoreBar.Hide()
+	}
+	// the containers only lay out visible objects, so they need a new layout
+	boardContainer.Refresh()
+	sidePanel.Refresh()
+	redrawBoard()
+}
+
+// updateAnalysis restarts the analysis when the shown position changed. It is called
+// whenever the board is redrawn, so it follows moves, history steps and new games.
+func updateAnalysis() {
+	if !analysisOn || editing {
+		stopAnalysis()
+		analyzedFEN = ""
+		analysisBest, analysisThreat, engineArrows = handlers.Move{}, handlers.Move{}, nil
+		return
+	}
+	pos := shownPosition()
+	if fen := pos.FEN(); fen != analyzedFEN {
+		analyzedFEN = fen
+		startAnalysis(pos)
+	}
+}
+
+// startAnalysis searches pos without limits in the background and shows every completed depth.
+func startAnalysis(pos handlers.Position) {
+	stopAnalysis()
+	id := analysisID.Add(1)
+	ctx, cancel := context.WithCancel(context.Background())
+	stopAnalysis = cancel
+	analysisBest, analysisThreat, engineArrows = handlers.Move{}, handlers.Move{}, nil
+
+	count := min(analysisLines, len(pos.LegalMoves()))
+	if count == 0 {
+		analysisText.SetText("No legal moves")
+		scoreBar.SetScore(0, 0)
+		return
+	}
+	analysisText.SetText("Analyzing...")
+
+	// the search runs in the background, the panel, the eval bar and the analysis
+	// variables are only touched on the UI thread
+	go func() {
+		lines := make([]engine.Info, count)
+		shown := 0
+		analysisEngine.searcher().Search(ctx, pos, engine.Limits{MultiPV: count}, func(info engine.Info) {
+			if analysisID.Load() != id || info.MultiPV < 1 || info.MultiPV > count {
+				return
+			}
+			// every line is shown as it comes in, an engine without MultiPV only sends the first
+			lines[info.MultiPV-1] = info
+			shown = max(shown, info.MultiPV)
+			current := append([]engine.Info(nil), lines[:shown]...)
+			fyne.Do(func() {
+				if analysisID.Load() != id {
+					return
+				}
+				showAnalysis(pos, current)
+				if info.MultiPV == 1 {
+					analysisBest = info.PV[0]
+					updateEngineArrows()
+				}
+			})
+		})
+		fyne.Do(func() {
+			if analysisID.Load() == id && analysisEngine.checkFailed() {
+				restartAnalysis()
+			}
+		})
+	}()
+
+	// the threat is what the opponent would play if the side to move passed, which
+	// is not possible when it is in check; the built-in engine finds it, so an external
+	// analysis engine is not kept from its main search
+	if pos.InCheck() {
+		return
+	}
+	passed := pos
+	passed.WhiteTurn = !pos.WhiteTurn
+	passed.EnPassant = handlers.NoSquare
+	go func() {
+		aiEngine.Search(ctx, passed, engine.Limits{Depth: threatDepth}, func(info engine.Info) {
+			threat := info.PV[0]
+			fyne.Do(func() {
+				if analysisID.Load() != id {
+					return
+				}
+				analysisThreat = threat
+				updateEngineArrows()
+			})
+		})
+	}()
+}
+
+// updateEngineArrows shows the arrows for the engine's best move and the threat that are switched on.
+func updateEngineArrows() {
+	var arrows []boardArrow
+	if showBestArrow && analysisBest != (handlers.Move{}) {
+		arrows = append(arrows, newBoardArrow(analysisBest.From, analysisBest.To, shapeColors['B']))
+	}
+	if showThreatArrow && analysisThreat != (handlers.Move{}) {
+		arrows = append(arrows, newBoardArrow(analysisThreat.From, analysisThreat.To, shapeColors['R']))
+	}
+	engineArrows = arrows
+	redrawBoard()
+}
+
+// showAnalysis lists the lines with score, depth, nodes and moves, and sets the eval bar to the best one.
+func showAnalysis(pos handlers.Position, lines []engine.Info) {
+	text := ""
+	for i, info := range lines {
+		if i > 0 {
+			text += "\n\n"
+		}
+		pv := info.PV[:min(len(info.PV), analysisPVMoves)]
+		text += fmt.Sprintf("%s  depth %d  %s nodes\n%s",
+			formatScore(info, pos.WhiteTurn), info.Depth, formatNodes(info.Nodes), pos.SANLine(pv))
+	}
+	analysisText.SetText(text)
+
+	best := lines[0]
+	if pos.WhiteTurn {
+		scoreBar.SetScore(best.Score, best.Mate)
+	} else {
+		scoreBar.SetScore(-best.Score, -best.Mate)
+	}
+}
+
+// formatScore shows a score from White's point of view, in pawns or as moves to mate.
+func formatScore(info engine.Info, whiteTurn bool) string {
+	score, mate := info.Score, info.Mate
+	if !whiteTurn {
+		score, mate = -score, -mate
+	}
+	if mate != 0 {
+		return "#" + strconv.Itoa(mate)
+	}
+	return fmt.Sprintf("%+.2f", float64(score)/100)
+}
+
+func formatNodes(nodes int64) string {
+	switch {
+	case nodes >= 1_000_000:
+		return fmt.Sprintf("%.1fM", float64(nodes)/1_000_000)
+	case nodes >= 1_000:
+		return fmt.Sprintf("%.1fk", float64(nodes)/1_000)
+	}
+	return strconv.FormatInt(nodes, 10)
+}
+
+// newAnalysisPanel builds the list of engine lines with a selector for how many to show.
+func newAnalysisPanel() *fyne.Container {
+	analysisText = widget.NewLabel("")
+	analysisText.Wrapping = fyne.TextWrapWord
+
+	linesSelect := widget.NewSelect([]string{"1", "2", "3", "4", "5"}, func(choice string) {
+		analysisLines, _ = strconv.Atoi(choice)
+		analyzedFEN = ""
+		updateAnalysis()
+	})
+	linesSelect.SetSelected(strconv.Itoa(analysisLines))
+
+	// a fixed height keeps long lines from squeezing the move list
+	lines := container.NewVScroll(analysisText)
+	lines.SetMinSize(fyne.NewSize(0, analysisPanelHeight))
+
+	bestCheck := widget.NewCheck("Best move", func(on bool) {
+		showBestArrow = on
+		updateEngineArrows()
+	})
+	bestCheck.SetChecked(showBestArrow)
+	threatCheck := widget.NewCheck("Threat", func(on bool) {
+		showThreatArrow = on
+		updateEngineArrows()
+	})
+	threatCheck.SetChecked(showThreatArrow)
+
+	analysisPanel = container.NewVBox(
+		container.NewHBox(widget.NewLabel("Analysis"), widget.NewLabel("Lines"), linesSelect),
+		container.NewHBox(widget.NewLabel("Arrows"), bestCheck, threatCheck),
+		lines,
+	)
+	analysisPanel.Hide()
+	return analysisPanel
+}
+
+// evalBar is a vertical bar split between White and Black by the engine's score,
+// with White's part on White's side of the board.
+type evalBar struct {
+	widget.BaseWidget
+
+	white float32 // White's share of the bar, 0 to 1
+	label string
+}
+
+func newEvalBar() *evalBar {
+	bar := &evalBar{white: 0.5, label: "0.0"}
+	bar.ExtendBaseWidget(bar)
+	bar.Hide()
+	return bar
+}
+
+// SetScore shows a score from White's point of view; a mate fills the bar for the winning side.
+func (b *evalBar) SetScore(centipawns, mate int) {
+	switch {
+	case mate > 0:
+		b.white, b.label = 1, "M"+strconv.Itoa(mate)
+	case mate < 0:
+		b.white, b.label = 0, "M"+strconv.Itoa(-mate)
+	default:
+		b.white = float32(1 / (1 + math.Exp(-float64(centipawns)/400)))
+		b.label = strconv.FormatFloat(math.Abs(float64(centipawns))/100, 'f', 1, 64)
+	}
+	b.Refresh()
+}
+
+func (b *evalBar) CreateRenderer() fyne.WidgetRenderer {
+	r := &evalBarRenderer{
+		bar:        b,
+		background: canvas.NewRectangle(color.NRGBA{R: 60, G: 60, B: 60, A: 255}),
+		fill:       canvas.NewRectangle(color.NRGBA{R: 240, G: 240, B: 240, A: 255}),
+		text:       canvas.NewText("", color.Black),
+	}
+	r.text.TextSize = 10
+	r.text.Alignment = fyne.TextAlignCenter
+	r.Refresh()
+	return r
+}
+
+type evalBarRenderer struct {
+	bar        *evalBar
+	background *canvas.Rectangle
+	fill       *canvas.Rectangle
+	text       *canvas.Text
+}
+
+func (r *evalBarRenderer) Destroy() {}
+
+func (r *evalBarRenderer) MinSize() fyne.Size {
+	return fyne.NewSize(24, 0)
+}
+
+func (r *evalBarRenderer) Objects() []fyne.CanvasObject {
+	return []fyne.CanvasObject{r.background, r.fill, r.text}
+}
+
+// Layout puts White's part at the bottom, or at the top when the board is flipped, and
+// writes the score at the end of the side that is ahead.
+func (r *evalBarRenderer) Layout(size fyne.Size) {
+	r.background.Resize(size)
+	whiteHeight := size.Height * r.bar.white
+	r.fill.Resize(fyne.NewSize(size.Width, whiteHeight))
+	whiteAtTop := boardFlipped
+	if whiteAtTop {
+		r.fill.Move(fyne.NewPos(0, 0))
+	} else {
+		r.fill.Move(fyne.NewPos(0, size.Height-whiteHeight))
+	}
+
+	textHeight := r.text.MinSize().Height
+	r.text.Resize(fyne.NewSize(size.Width, textHeight))
+	whiteAhead := r.bar.white >= 0.5
+	r.text.Color = color.Black
+	if !whiteAhead {
+		r.text.Color = color.White
+	}
+	if whiteAhead == whiteAtTop {
+		r.text.Move(fyne.NewPos(0, 2))
+	} else {
+		r.text.Move(fyne.NewPos(0, size.Height-textHeight-2))
+	}
+}
+
+func (r *evalBarRenderer) Refresh() {
+	r.text.Text = r.bar.label
+	r.Layout(r.bar.Size())
+	canvas.Refresh(r.bar)
+}
@@ -0,0 +1,459 @@
+// Package api serves games over HTTP as a JSON REST API, for web front ends that
+// want the rules and the engine without the GUI. Games are kept in memory.
+//
+// Routes:
+//
+//	POST   /games                   create a game, {"fen": "..."} to start from a position
+//	GET    /games/{id}              the game's state
+//	DELETE /games/{id}              drop the game
+//	GET    /games/{id}/legal-moves  the moves the side to move may play
+//	POST   /games/{id}/moves        play {"move": "e2e4"} or {"move": "Nf3"}, UCI or SAN
+//	POST   /games/{id}/engine-move  let the engine play, {"depth": n, "movetime_ms": n} optional
+//	POST   /games/{id}/resign       {"side": "white"}, the side to move if left out
+//	POST   /games/{id}/draw         offer a draw, or accept the opponent's offer
+//	DELETE /games/{id}/draw         decline the draw offer
+//	GET    /games/{id}/pgn          the game as PGN
+//
+// Errors are answered with an HTTP status and a body such as
+// {"error": {"code": "illegal_move", "message": "...", "move": "e2e5"}}.
+//
+// A game nobody asks about is dropped after a while, see FinishedTTL and IdleTTL.
+package api
+
+import (
+	"chess-engine/engine"
+	"chess-engine/game"
+	"chess-engine/handlers"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// MaxGames is how many games the server keeps at once.
+const MaxGames = 10000
+
+// Games are dropped when no request has used them for a while: a finished game after
+// FinishedTTL, leaving time to fetch its PGN, and a game in progress after IdleTTL.
+const (
+	FinishedTTL = time.Hour
+	IdleTTL     = 24 * time.Hour
+)
+
+// Engine move limits: the time a move gets unless the request sets one, and the most
+// it may ask for.
+const (
+	DefaultMoveTime = time.Second
+	MaxMoveTime     = time.Minute
+)
+
+// maxBodyBytes bounds a request body; the largest is a FEN.
+const maxBodyBytes = 64 << 10
+
+// Error codes, the "code" of an error response.
+const (
+	CodeBadRequest   = "bad_request"
+	CodeInvalidFEN   = "invalid_fen"
+	CodeNotFound     = "not_found"
+	CodeIllegalMove  = "illegal_move"
+	CodeGameOver     = "game_over"
+	CodeInvalidSide  = "invalid_side"
+	CodeTooManyGames = "too_many_games"
+	CodeNoMove       = "no_move"
+)
+
+// Server is an http.Handler serving the games it holds, with one engine shared by all of them.
+type Server struct {
+	engine *engine.Engine
+	mux    *http.ServeMux
+
+	mu    sync.Mutex
+	games map[string]*entry
+
+	// now is the time source; tests replace it to expire games without waiting.
+	now func() time.Time
+}
+
+// entry is a game and the state the API keeps around it. Its lock is held for the
+// whole of a request, engine searches included, so requests on one game run in turn.
+type entry struct {
+	mu        sync.Mutex
+	id        string
+	game      *game.Game
+	drawOffer string // the side whose draw offer is open, "" if none
+
+	// lastUsed and over are what expire goes by; they are guarded by the server's
+	// lock, so it does not wait for a game busy with a search
+	lastUsed time.Time
+	over     bool
+}
+
+// NewServer returns a server with no games that plays engine moves with e.
+func NewServer(e *engine.Engine) *Server {
+	s := &Server{engine: e, mux: http.NewServeMux(), games: map[string]*entry{}, now: time.Now}
+	s.mux.HandleFunc("POST /games", s.createGame)
+	s.mux.HandleFunc("GET /games/{id}", s.withGame(s.getGame))
+	s.mux.HandleFunc("DELETE /games/{id}", s.withGame(s.deleteGame))
+	s.mux.HandleFunc("GET /games/{id}/legal-moves", s.withGame(s.legalMoves))
+	s.mux.HandleFunc("POST /games/{id}/moves", s.withGame(s.playMove))
+	s.mux.HandleFunc("POST /games/{id}/engine-move", s.withGame(s.engineMove))
+	s.mux.HandleFunc("POST /games/{id}/resign", s.withGame(s.resign))
+	s.mux.HandleFunc("POST /games/{id}/draw", s.withGame(s.offerDraw))
+	s.mux.HandleFunc("DELETE /games/{id}/draw", s.withGame(s.declineDraw))
+	s.mux.HandleFunc("GET /games/{id}/pgn", s.withGame(s.pgn))
+	s.mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, &apiError{http.StatusNotFound, CodeNotFound, "no such endpoint: " + r.Method + " " + r.URL.Path, ""})
+	})
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// apiError is an error answered to the client, with the status and code to send.
+type apiError struct {
+	status  int
+	code    string
+	message string
+	move    string // the move that was rejected, for illegal_move and game_over
+}
+
+func (e *apiError) Error() string { return e.message }
+
+func badRequest(format string, args ...any) *apiError {
+	return &apiError{http.StatusBadRequest, CodeBadRequest, fmt.Sprintf(format, args...), ""}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, err *apiError) {
+	type errorBody struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+		Move    string `json:"move,omitempty"`
+	}
+	writeJSON(w, err.status, map[string]errorBody{"error": {err.code, err.message, err.move}})
+}
+
+// readBody decodes the JSON request body into v. An empty body leaves v as it is,
+// since every field the API reads is optional or checked afterwards.
+func readBody(w http.ResponseWriter, r *http.Request, v any) *apiError {
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes)).Decode(v)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return badRequest("invalid JSON body: %v", err)
+	}
+	return nil
+}
+
+// withGame looks up the game named in the path and runs h with it locked.
+func (s *Server) withGame(h func(w http.ResponseWriter, r *http.Request, e *entry) *apiError) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		s.mu.Lock()
+		e := s.games[id]
+		s.mu.Unlock()
+		if e == nil {
+			writeError(w, &apiError{http.StatusNotFound, CodeNotFound, "no game with id " + id, ""})
+			return
+		}
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		if err := h(w, r, e); err != nil {
+			writeError(w, err)
+		}
+		s.mu.Lock()
+		e.lastUsed, e.over = s.now(), e.game.Over()
+		s.mu.Unlock()
+	}
+}
+
+// expire drops the games that have not been used for longer than their TTL.
+// s.mu must be held.
+func (s *Server) expire() {
+	now := s.now()
+	for id, e := range s.games {
+		ttl := IdleTTL
+		if e.over {
+			ttl = FinishedTTL
+		}
+		if now.Sub(e.lastUsed) > ttl {
+			delete(s.games, id)
+		}
+	}
+}
+
+// moveJSON is a move in both notations.
+type moveJSON struct {
+	UCI string `json:"uci"`
+	SAN string `json:"san"`
+}
+
+// stateJSON is the state of a game as the API reports it.
+type stateJSON struct {
+	ID        string    `json:"id"`
+	FEN       string    `json:"fen"`
+	Turn      string    `json:"turn"`
+	Check     bool      `json:"check"`
+	Moves     []string  `json:"moves"`
+	UCIMoves  []string  `json:"uci_moves"`
+	LastMove  *moveJSON `json:"last_move,omitempty"`
+	Result    string    `json:"result"`
+	Reason    string    `json:"reason,omitempty"`
+	DrawOffer string    `json:"draw_offer,omitempty"`
+}
+
+func (e *entry) state() stateJSON {
+	g := e.game
+	pos := g.Position()
+	st := stateJSON{
+		ID:        e.id,
+		FEN:       pos.FEN(),
+		Turn:      sideName(pos.WhiteTurn),
+		Check:     pos.InCheck(),
+		Moves:     g.SAN(),
+		UCIMoves:  []string{},
+		Result:    string(g.Result()),
+		Reason:    string(g.Reason()),
+		DrawOffer: e.drawOffer,
+	}
+	for _, m := range g.Moves() {
+		st.UCIMoves = append(st.UCIMoves, m.String())
+	}
+	if st.Moves == nil {
+		st.Moves = []string{}
+	}
+	if ply := g.Ply(); ply > 0 {
+		st.LastMove = &moveJSON{st.UCIMoves[ply-1], st.Moves[ply-1]}
+	}
+	return st
+}
+
+func sideName(white bool) string {
+	if white {
+		return "white"
+	}
+	return "black"
+}
+
+// newID returns a random game id that is hard to guess.
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (s *Server) createGame(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		FEN string `json:"fen"`
+	}
+	if err := readBody(w, r, &body); err != nil {
+		writeError(w, err)
+		return
+	}
+	g := game.New()
+	if body.FEN != "" {
+		pos, err := handlers.ParseFEN(body.FEN)
+		if err == nil {
+			err = pos.Validate()
+		}
+		if err != nil {
+			writeError(w, &apiError{http.StatusBadRequest, CodeInvalidFEN, err.Error(), ""})
+			return
+		}
+		g = game.NewFromPosition(pos)
+	}
+
+	s.mu.Lock()
+	e := &entry{id: newID(), game: g, lastUsed: s.now(), over: g.Over()}
+	s.expire()
+	full := len(s.games) >= MaxGames
+	if !full {
+		s.games[e.id] = e
+	}
+	s.mu.Unlock()
+	if full {
+		writeError(w, &apiError{http.StatusServiceUnavailable, CodeTooManyGames, "the server holds too many games", ""})
+		return
+	}
+	w.Header().Set("Location", "/games/"+e.id)
+	writeJSON(w, http.StatusCreated, e.state())
+}
+
+func (s *Server) getGame(w http.ResponseWriter, r *http.Request, e *entry) *apiError {
+	writeJSON(w, http.StatusOK, e.state())
+	return nil
+}
+
+func (s *Server) deleteGame(w http.ResponseWriter, r *http.Request, e *entry) *apiError {
+	s.mu.Lock()
+	delete(s.games, e.id)
+	s.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (s *Server) legalMoves(w http.ResponseWriter, r *http.Request, e *entry) *apiError {
+	moves := []moveJSON{}
+	if !e.game.Over() {
+		pos := e.game.Position()
+		for _, m := range pos.LegalMoves() {
+			moves = append(moves, moveJSON{m.String(), pos.SAN(m)})
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string][]moveJSON{"moves": moves})
+	return nil
+}
+
+// play plays m for the side to move; an open draw offer of the opponent lapses, as
+// playing on declines it.
+func (e *entry) play(m handlers.Move, text string) *apiError {
+	white := e.game.Position().WhiteTurn
+	if err := e.game.Play(m); err != nil {
+		if errors.Is(err, game.ErrGameOver) {
+			return &apiError{http.StatusConflict, CodeGameOver, "the game is over", text}
+		}
+		return &apiError{http.StatusUnprocessableEntity, CodeIllegalMove, text + " is not a legal move", text}
+	}
+	if e.drawOffer != sideName(white) {
+		e.drawOffer = ""
+	}
+	return nil
+}
+
+func (s *Server) playMove(w http.ResponseWriter, r *http.Request, e *entry) *apiError {
+	var body struct {
+		Move string `json:"move"`
+	}
+	if err := readBody(w, r, &body); err != nil {
+		return err
+	}
+	if body.Move == "" {
+		return badRequest("move is missing")
+	}
+	if e.game.Over() {
+		return &apiError{http.StatusConflict, CodeGameOver, "the game is over", body.Move}
+	}
+	pos := e.game.Position()
+	m, err := pos.ParseMove(body.Move)
+	if err != nil {
+		return &apiError{http.StatusUnprocessableEntity, CodeIllegalMove, err.Error(), body.Move}
+	}
+	if err := e.play(m, body.Move); err != nil {
+		return err
+	}
+	writeJSON(w, http.StatusOK, e.state())
+	return nil
+}
+
+// engineMove searches the position and plays the engine's move. The search stops
+// early if the client goes away, and the move is then not played.
+func (s *Server) engineMove(w http.ResponseWriter, r *http.Request, e *entry) *apiError {
+	var body struct {
+		Depth      int `json:"depth"`
+		MoveTimeMS int `json:"movetime_ms"`
+	}
+	if err := readBody(w, r, &body); err != nil {
+		return err
+	}
+	if body.Depth < 0 || body.MoveTimeMS < 0 {
+		return badRequest("depth and movetime_ms cannot be negative")
+	}
+	if e.game.Over() {
+		return &apiError{http.StatusConflict, CodeGameOver, "the game is over", ""}
+	}
+
+	limits := engine.Limits{Depth: body.Depth, MoveTime: min(time.Duration(body.MoveTimeMS)*time.Millisecond, MaxMoveTime)}
+	if limits.MoveTime == 0 && limits.Depth == 0 {
+		limits.MoveTime = DefaultMoveTime
+	}
+	// a depth alone could run for hours, so the search is always bounded in time
+	ctx, cancel := context.WithTimeout(r.Context(), MaxMoveTime)
+	defer cancel()
+
+	m, ok := s.engine.Search(ctx, e.game.Position(), limits, nil)
+	if r.Context().Err() != nil {
+		return nil
+	}
+	if !ok {
+		return &apiError{http.StatusConflict, CodeNoMove, "the side to move has no legal move", ""}
+	}
+	if err := e.play(m, m.String()); err != nil {
+		return err
+	}
+	writeJSON(w, http.StatusOK, e.state())
+	return nil
+}
+
+// side reads the "side" of a resign or draw request, the side to move if it is left out.
+func (e *entry) side(w http.ResponseWriter, r *http.Request) (string, *apiError) {
+	var body struct {
+		Side string `json:"side"`
+	}
+	if err := readBody(w, r, &body); err != nil {
+		return "", err
+	}
+	switch body.Side {
+	case "":
+		return sideName(e.game.Position().WhiteTurn), nil
+	case "white", "black":
+		return body.Side, nil
+	}
+	return "", &apiError{http.StatusBadRequest, CodeInvalidSide, `side must be "white" or "black"`, ""}
+}
+
+func (s *Server) resign(w http.ResponseWriter, r *http.Request, e *entry) *apiError {
+	side, err := e.side(w, r)
+	if err != nil {
+		return err
+	}
+	if e.game.Over() {
+		return &apiError{http.StatusConflict, CodeGameOver, "the game is over", ""}
+	}
+	e.game.Resign(side == "white")
+	e.drawOffer = ""
+	writeJSON(w, http.StatusOK, e.state())
+	return nil
+}
+
+// offerDraw records a draw offer by side, or agrees the draw if the opponent offered one.
+func (s *Server) offerDraw(w http.ResponseWriter, r *http.Request, e *entry) *apiError {
+	side, err := e.side(w, r)
+	if err != nil {
+		return err
+	}
+	if e.game.Over() {
+		return &apiError{http.StatusConflict, CodeGameOver, "the game is over", ""}
+	}
+	if e.drawOffer != "" && e.drawOffer != side {
+		e.game.AgreeDraw()
+		e.drawOffer = ""
+	} else {
+		e.drawOffer = side
+	}
+	writeJSON(w, http.StatusOK, e.state())
+	return nil
+}
+
+func (s *Server) declineDraw(w http.ResponseWriter, r *http.Request, e *entry) *apiError {
+	e.drawOffer = ""
+	writeJSON(w, http.StatusOK, e.state())
+	return nil
+}
+
+func (s *Server) pgn(w http.ResponseWriter, r *http.Request, e *entry) *apiError {
+	w.Header().Set("Content-Type", "application/x-chess-pgn")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", e.id+".pgn"))
+	io.WriteString(w, e.game.PGN())
+	return nil
+}
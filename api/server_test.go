@@ -0,0 +1,272 @@
+package api
+
+import (
+	"chess-engine/engine"
+	"chess-engine/game"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// call sends a request to s and decodes a JSON response into a generic map.
+func call(t *testing.T, s *Server, method, path, body string) (int, map[string]any, *http.Response) {
+	t.Helper()
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest(method, path, reader))
+	resp := w.Result()
+	var decoded map[string]any
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+		if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+	}
+	return resp.StatusCode, decoded, resp
+}
+
+// newGame creates a game, from fen unless it is empty, and returns its id.
+func newGame(t *testing.T, s *Server, fen string) string {
+	t.Helper()
+	body := ""
+	if fen != "" {
+		body = fmt.Sprintf(`{"fen": %q}`, fen)
+	}
+	status, state, _ := call(t, s, "POST", "/games", body)
+	if status != http.StatusCreated {
+		t.Fatalf("POST /games: status %d, %v", status, state)
+	}
+	return state["id"].(string)
+}
+
+func errorCode(body map[string]any) string {
+	if e, ok := body["error"].(map[string]any); ok {
+		return e["code"].(string)
+	}
+	return ""
+}
+
+func TestRequests(t *testing.T) {
+	type step struct {
+		method, path, body string
+		status             int
+		code               string // the error code, "" for a success
+		field, value       string // a field of the response to check, "" for none
+	}
+	tests := []struct {
+		name  string
+		fen   string
+		steps []step
+	}{
+		{"state of a new game", "", []step{
+			{"GET", "/games/{id}", "", 200, "", "fen", "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"},
+			{"GET", "/games/{id}", "", 200, "", "turn", "white"},
+			{"GET", "/games/{id}", "", 200, "", "result", "*"},
+		}},
+		{"moves in both notations", "", []step{
+			{"POST", "/games/{id}/moves", `{"move": "e2e4"}`, 200, "", "turn", "black"},
+			{"POST", "/games/{id}/moves", `{"move": "Nf6"}`, 200, "", "moves", "e4 Nf6"},
+			{"GET", "/games/{id}", "", 200, "", "uci_moves", "e2e4 g8f6"},
+		}},
+		{"illegal moves", "", []step{
+			{"POST", "/games/{id}/moves", `{"move": "e2e5"}`, 422, CodeIllegalMove, "", ""},
+			{"POST", "/games/{id}/moves", `{"move": "Ke2"}`, 422, CodeIllegalMove, "", ""},
+			{"POST", "/games/{id}/moves", `{}`, 400, CodeBadRequest, "", ""},
+			{"POST", "/games/{id}/moves", `{"move": `, 400, CodeBadRequest, "", ""},
+		}},
+		{"mate ends the game", "", []step{
+			{"POST", "/games/{id}/moves", `{"move": "f3"}`, 200, "", "", ""},
+			{"POST", "/games/{id}/moves", `{"move": "e5"}`, 200, "", "", ""},
+			{"POST", "/games/{id}/moves", `{"move": "g4"}`, 200, "", "", ""},
+			{"POST", "/games/{id}/moves", `{"move": "Qh4#"}`, 200, "", "reason", "checkmate"},
+			{"POST", "/games/{id}/moves", `{"move": "a3"}`, 409, CodeGameOver, "", ""},
+			{"POST", "/games/{id}/engine-move", "", 409, CodeGameOver, "", ""},
+			{"GET", "/games/{id}/legal-moves", "", 200, "", "moves", ""},
+		}},
+		{"legal moves", "4k3/8/8/8/8/8/8/4K2R w K - 0 1", []step{
+			{"GET", "/games/{id}/legal-moves", "", 200, "", "moves", "Kd2 Ke2 Kf2 Kd1 Kf1 O-O Rh2 Rh3 Rh4 Rh5 Rh6 Rh7 Rh8+ Rg1 Rf1"},
+		}},
+		{"engine move", "", []step{
+			{"POST", "/games/{id}/engine-move", `{"depth": 1}`, 200, "", "turn", "black"},
+			{"POST", "/games/{id}/engine-move", `{"depth": -1}`, 400, CodeBadRequest, "", ""},
+		}},
+		{"resignation", "", []step{
+			{"POST", "/games/{id}/resign", `{"side": "purple"}`, 400, CodeInvalidSide, "", ""},
+			{"POST", "/games/{id}/resign", `{"side": "black"}`, 200, "", "result", "1-0"},
+			{"POST", "/games/{id}/resign", "", 409, CodeGameOver, "", ""},
+		}},
+		{"draw offer accepted", "", []step{
+			{"POST", "/games/{id}/draw", `{"side": "white"}`, 200, "", "draw_offer", "white"},
+			{"POST", "/games/{id}/draw", `{"side": "black"}`, 200, "", "result", "1/2-1/2"},
+		}},
+		{"draw offer declined", "", []step{
+			{"POST", "/games/{id}/draw", "", 200, "", "draw_offer", "white"},
+			{"DELETE", "/games/{id}/draw", "", 200, "", "draw_offer", ""},
+		}},
+		{"draw offer lapses when the opponent plays on", "", []step{
+			{"POST", "/games/{id}/draw", `{"side": "white"}`, 200, "", "", ""},
+			{"POST", "/games/{id}/moves", `{"move": "e4"}`, 200, "", "draw_offer", "white"},
+			{"POST", "/games/{id}/moves", `{"move": "e5"}`, 200, "", "draw_offer", ""},
+		}},
+		{"deleted game", "", []step{
+			{"DELETE", "/games/{id}", "", 204, "", "", ""},
+			{"GET", "/games/{id}", "", 404, CodeNotFound, "", ""},
+			{"DELETE", "/games/{id}", "", 404, CodeNotFound, "", ""},
+		}},
+		{"unknown game and route", "", []step{
+			{"GET", "/games/nosuchgame", "", 404, CodeNotFound, "", ""},
+			{"GET", "/players", "", 404, CodeNotFound, "", ""},
+		}},
+	}
+	s := NewServer(engine.New())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id := newGame(t, s, tt.fen)
+			for _, st := range tt.steps {
+				path := strings.ReplaceAll(st.path, "{id}", id)
+				status, body, _ := call(t, s, st.method, path, st.body)
+				if status != st.status || errorCode(body) != st.code {
+					t.Fatalf("%s %s %s: status %d %v, want %d %q", st.method, st.path, st.body, status, body, st.status, st.code)
+				}
+				if st.field != "" {
+					if got := fieldString(body[st.field]); got != st.value {
+						t.Errorf("%s %s %s: %s = %q, want %q", st.method, st.path, st.body, st.field, got, st.value)
+					}
+				}
+			}
+		})
+	}
+}
+
+// fieldString flattens a response field for comparison: a list of moves becomes the
+// moves separated by spaces, SAN for move objects.
+func fieldString(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []any:
+		var parts []string
+		for _, item := range v {
+			if m, ok := item.(map[string]any); ok {
+				item = m["san"]
+			}
+			parts = append(parts, fmt.Sprint(item))
+		}
+		return strings.Join(parts, " ")
+	}
+	return fmt.Sprint(v)
+}
+
+func TestCreateGame(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		code   string
+	}{
+		{"standard", "", 201, ""},
+		{"from a FEN", `{"fen": "4k3/8/8/8/8/8/4P3/4K3 w - - 0 1"}`, 201, ""},
+		{"broken FEN", `{"fen": "rubbish"}`, 400, CodeInvalidFEN},
+		{"impossible position", `{"fen": "8/8/8/8/8/8/8/8 w - - 0 1"}`, 400, CodeInvalidFEN},
+		{"bad JSON", `{"fen"`, 400, CodeBadRequest},
+	}
+	s := NewServer(engine.New())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body, resp := call(t, s, "POST", "/games", tt.body)
+			if status != tt.status || errorCode(body) != tt.code {
+				t.Fatalf("status %d %v, want %d %q", status, body, tt.status, tt.code)
+			}
+			if status == 201 && resp.Header.Get("Location") != "/games/"+body["id"].(string) {
+				t.Errorf("Location = %q", resp.Header.Get("Location"))
+			}
+		})
+	}
+}
+
+func TestPGN(t *testing.T) {
+	s := NewServer(engine.New())
+	id := newGame(t, s, "")
+	call(t, s, "POST", "/games/"+id+"/moves", `{"move": "d4"}`)
+	_, _, resp := call(t, s, "GET", "/games/"+id+"/pgn", "")
+	if resp.StatusCode != 200 || resp.Header.Get("Content-Type") != "application/x-chess-pgn" {
+		t.Fatalf("status %d, Content-Type %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	text, _ := io.ReadAll(resp.Body)
+	g, err := game.ParsePGN(string(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moves := g.SAN(); len(moves) != 1 || moves[0] != "d4" {
+		t.Errorf("PGN moves %v, want [d4]", moves)
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	s := NewServer(engine.New())
+	s.now = func() time.Time { return now }
+
+	finished := newGame(t, s, "")
+	call(t, s, "POST", "/games/"+finished+"/resign", "")
+	playing := newGame(t, s, "")
+	exists := func(id string) bool {
+		status, _, _ := call(t, s, "GET", "/games/"+id, "")
+		return status == http.StatusOK
+	}
+
+	// expiry happens when a game is created
+	now = now.Add(FinishedTTL + time.Minute)
+	newGame(t, s, "")
+	if s.games[finished] != nil {
+		t.Errorf("a finished game outlived FinishedTTL")
+	}
+	if !exists(playing) {
+		t.Fatalf("a game in progress was dropped after FinishedTTL")
+	}
+
+	// the GET above used the game, so it is kept for another IdleTTL
+	now = now.Add(IdleTTL - time.Minute)
+	newGame(t, s, "")
+	if !exists(playing) {
+		t.Fatalf("a game in progress was dropped before IdleTTL")
+	}
+	now = now.Add(IdleTTL + time.Minute)
+	newGame(t, s, "")
+	if s.games[playing] != nil {
+		t.Errorf("an idle game outlived IdleTTL")
+	}
+}
+
+func TestFullServer(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	s := NewServer(engine.New())
+	s.now = func() time.Time { return now }
+	for i := range MaxGames {
+		id := fmt.Sprint(i)
+		s.games[id] = &entry{id: id, game: game.New(), lastUsed: now}
+	}
+
+	status, body, _ := call(t, s, "POST", "/games", "")
+	if status != http.StatusServiceUnavailable || errorCode(body) != CodeTooManyGames {
+		t.Fatalf("status %d %v, want 503 %s", status, body, CodeTooManyGames)
+	}
+	call(t, s, "DELETE", "/games/0", "")
+	newGame(t, s, "")
+
+	// once the games go idle they make room for new ones
+	now = now.Add(IdleTTL + time.Minute)
+	id := newGame(t, s, "")
+	if len(s.games) != 1 || s.games[id] == nil {
+		t.Errorf("%d games kept, want only the one created after the others went idle", len(s.games))
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"image/color"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// Preference keys for the appearance settings, kept between runs.
+const (
+	boardThemePreference = "boardTheme"
+	pieceSetPreference   = "pieceSetDir"
+)
+
+// boardTheme is a pair of square colors.
+type boardTheme struct {
+	name        string
+	light, dark color.Color
+}
+
+var boardThemes = []boardTheme{
+	{"Wood", color.NRGBA{R: 240, G: 217, B: 181, A: 255}, color.NRGBA{R: 181, G: 136, B: 99, A: 255}},
+	{"Green", color.NRGBA{R: 238, G: 238, B: 210, A: 255}, color.NRGBA{R: 118, G: 150, B: 86, A: 255}},
+	{"Blue", color.NRGBA{R: 222, G: 227, B: 230, A: 255}, color.NRGBA{R: 140, G: 162, B: 173, A: 255}},
+	{"Gray", color.NRGBA{R: 220, G: 220, B: 220, A: 255}, color.NRGBA{R: 145, G: 145, B: 145, A: 255}},
+	{"Black and white", color.White, color.Black},
+}
+
+var currentBoardTheme = boardThemes[0]
+
+// pieceSetDir is the directory the pieces are loaded from, empty for the built-in set.
+var pieceSetDir string
+
+func squareColor(row, col int) color.Color {
+	if (row+col)%2 == 1 {
+		return currentBoardTheme.dark
+	}
+	return currentBoardTheme.light
+}
+
+func findBoardTheme(name string) (boardTheme, bool) {
+	for _, t := range boardThemes {
+		if t.name == name {
+			return t, true
+		}
+	}
+	return boardTheme{}, false
+}
+
+// loadAppearance applies the saved board theme and piece set. A piece set that can
+// no longer be loaded falls back to the built-in one.
+func loadAppearance(prefs fyne.Preferences) {
+	if t, ok := findBoardTheme(prefs.String(boardThemePreference)); ok {
+		currentBoardTheme = t
+	}
+	if dir := prefs.String(pieceSetPreference); dir != "" {
+		set, err := loadPieceSet(dir)
+		if err != nil {
+			logger.Warn("using the built-in pieces", "dir", dir, "err", err)
+			return
+		}
+		pieceSetDir = dir
+		usePieceSet(set)
+	}
+}
+
+func setBoardTheme(t boardTheme) {
+	currentBoardTheme = t
+	fyne.CurrentApp().Preferences().SetString(boardThemePreference, t.name)
+	if chessBoard != nil {
+		chessBoard.restyle()
+	}
+}
+
+// setPieceSet switches to the pieces in dir, or the built-in ones when dir is empty,
+// and keeps the current set if dir is missing any of them.
+func setPieceSet(dir string) error {
+	set, err := loadPieceSet(dir)
+	if err != nil {
+		return err
+	}
+	pieceSetDir = dir
+	usePieceSet(set)
+	fyne.CurrentApp().Preferences().SetString(pieceSetPreference, dir)
+	if chessBoard != nil {
+		chessBoard.restyle()
+	}
+	refreshCaptures()
+	return nil
+}
+
+func pieceSetName() string {
+	if pieceSetDir == "" {
+		return "Built-in"
+	}
+	return pieceSetDir
+}
+
+// showAppearanceDialog lets the player pick the board colors and the piece set; changes apply right away.
+func showAppearanceDialog() {
+	var themeNames []string
+	for _, t := range boardThemes {
+		themeNames = append(themeNames, t.name)
+	}
+	themeSelect := widget.NewSelect(themeNames, func(name string) {
+		if t, ok := findBoardTheme(name); ok && t.name != currentBoardTheme.name {
+			setBoardTheme(t)
+		}
+	})
+	themeSelect.SetSelected(currentBoardTheme.name)
+
+	pieceSetLabel := widget.NewLabel(pieceSetName())
+	pieceSetLabel.Wrapping = fyne.TextWrapBreak
+	builtinButton := widget.NewButton("Built-in", func() {
+		if err := setPieceSet(""); err != nil {
+			dialog.ShowError(err, chessWindow)
+		}
+		pieceSetLabel.SetText(pieceSetName())
+	})
+	folderButton := widget.NewButton("Load from folder...", func() {
+		dialog.ShowFolderOpen(func(folder fyne.ListableURI, err error) {
+			if err != nil {
+				dialog.ShowError(err, chessWindow)
+				return
+			}
+			if folder == nil {
+				return
+			}
+			if err := setPieceSet(folder.Path()); err != nil {
+				dialog.ShowError(err, chessWindow)
+			}
+			pieceSetLabel.SetText(pieceSetName())
+		}, chessWindow)
+	})
+
+	form := widget.NewForm(
+		widget.NewFormItem("Board", themeSelect),
+		widget.NewFormItem("Pieces", container.NewVBox(pieceSetLabel, container.NewHBox(builtinButton, folderButton))),
+	)
+	appearanceDialog := dialog.NewCustom("Appearance", "Close", form, chessWindow)
+	appearanceDialog.Resize(fyne.NewSize(420, 0))
+	appearanceDialog.Show()
+}
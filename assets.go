@@ -0,0 +1,80 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"fyne.io/fyne/v2"
+)
+
+// pieceFiles are the built-in piece images, compiled into the binary so it runs from any directory.
+//
+//go:embed chess-gui/peices/*.svg
+var pieceFiles embed.FS
+
+const embeddedPieceDir = "chess-gui/peices"
+
+// pieceResources are the images of the piece set in use, keyed by piece.
+var (
+	pieceResources   map[rune]fyne.Resource
+	pieceResourcesMu sync.Mutex
+)
+
+// loadPieceSet reads the twelve piece images from dir, or the built-in set when dir
+// is empty. A directory uses the built-in file names, e.g. whiteKnight.svg, and may
+// provide PNG files instead of SVG ones.
+func loadPieceSet(dir string) (map[rune]fyne.Resource, error) {
+	set := make(map[rune]fyne.Resource, len(mpPieceToImage))
+	for piece, name := range mpPieceToImage {
+		if dir == "" {
+			data, err := pieceFiles.ReadFile(path.Join(embeddedPieceDir, name))
+			if err != nil {
+				return nil, err
+			}
+			set[piece] = fyne.NewStaticResource(name, data)
+			continue
+		}
+
+		// Fyne caches decoded images by resource name, so the name includes the directory
+		var file string
+		var data []byte
+		var err error
+		for _, candidate := range []string{name, strings.TrimSuffix(name, ".svg") + ".png"} {
+			file = filepath.Join(dir, candidate)
+			if data, err = os.ReadFile(file); err == nil {
+				break
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("piece set %s has no %s", dir, name)
+		}
+		set[piece] = fyne.NewStaticResource(file, data)
+	}
+	return set, nil
+}
+
+// usePieceSet makes set the images the board draws from now on.
+func usePieceSet(set map[rune]fyne.Resource) {
+	pieceResourcesMu.Lock()
+	defer pieceResourcesMu.Unlock()
+	pieceResources = set
+}
+
+func pieceResource(piece rune) fyne.Resource {
+	pieceResourcesMu.Lock()
+	defer pieceResourcesMu.Unlock()
+	if pieceResources == nil {
+		set, err := loadPieceSet("")
+		if err != nil {
+			logger.Error("could not load the built-in pieces", "err", err)
+			return nil
+		}
+		pieceResources = set
+	}
+	return pieceResources[piece]
+}
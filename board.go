@@ -1,26 +1,19 @@
 package main
 
 import (
+	"chess-engine/engine"
+	"chess-engine/game"
 	"chess-engine/handlers"
-	"fmt"
-	"image/color"
-	"math/rand"
-	"path/filepath"
-	"strings"
+	"chess-engine/logging"
 
 	"fyne.io/fyne/v2"
 	"fyne.io/fyne/v2/app"
-	"fyne.io/fyne/v2/canvas"
 	"fyne.io/fyne/v2/container"
-
-	//"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
 )
 
 const boardSize = 8
-const pieceDir = "chess-gui/peices"
-
-const startFenNotation = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
 
 var mpPieceToImage = map[rune]string{
 	'P': "whitePawn.svg", 'N': "whiteKnight.svg", 'B': "whiteBishop.svg", 'R': "whiteRook.svg",
@@ -31,107 +24,44 @@ var mpPieceToImage = map[rune]string{
 
 var selectedRow, selectedCol int
 var pieceSelected bool
-var parsedBoard [8][8]rune
-var whiteTurn = true
-
-var boardContainer *fyne.Container
-var boardCells [8][8]*fyne.Container
-var blackScore = 1290
-var whiteScore = 1290
-
-type KingPosition struct {
-	Row     int
-	Col     int
-	IsCheck bool
-}
-
-var whiteKing = KingPosition{Row: 7, Col: 4, IsCheck: false}
-var blackKing = KingPosition{Row: 0, Col: 4, IsCheck: false}
-
-// func showWinnerNotification(a fyne.App, w fyne.Window, winner string) {
-// 	// Create a dialog to display the winner
-// 	content := container.NewVBox(
-// 		widget.NewLabel("Game Over"),
-// 		widget.NewLabel(winner+" wins!"),
-// 	)
-
-// 	// Create custom dialog with a close button
-// 	winnerDialog := dialog.NewCustom("Chess Game", "Play Again", content, w)
-
-// 	// Show the dialog
-// 	winnerDialog.Show()
-// }
-
-func getBlackPieces() [][2]int {
-	var positions [][2]int
-	for i := 0; i < 8; i++ {
-		for j := 0; j < 8; j++ {
-			piece := parsedBoard[i][j]
-			if piece >= 'a' && piece <= 'z' {
-				positions = append(positions, [2]int{i, j})
-			}
-		}
-	}
-	return positions
-}
-
-func bestMove(board [8][8]rune) {
 
-	blackPieces := getBlackPieces()
-	fmt.Println("black pieces", blackPieces)
-
-	if len(blackPieces) == 0 {
-		return
-	}
+// currentGame holds the board, side to move, castling rights and move history.
+var currentGame = game.New()
 
-	length := len(blackPieces)
-	random := rand.Intn(length)
-	randomPiece := blackPieces[random]
+var aiEngine = engine.New()
 
-	fmt.Println("random piece selected:", randomPiece)
+var logger = logging.For(logging.GUI)
 
-	pieceType := board[randomPiece[0]][randomPiece[1]]
+// viewPly is the position shown on the board; it trails currentGame.Ply() while
+// the player steps back through the move history.
+var viewPly int
 
-	var validMoves [][2]int
+// displayedBoard is what the board widget currently shows.
+var displayedBoard [8][8]rune
 
-	for i := 0; i < 8; i++ {
-		for j := 0; j < 8; j++ {
-			if randomPiece[0] == i && randomPiece[1] == j {
-				continue
-			}
-			if handlers.IsValidMove(board, pieceType, randomPiece[0], randomPiece[1], i, j, nil) && isPathClear(randomPiece[0], randomPiece[1], i, j) {
-				validMoves = append(validMoves, [2]int{i, j})
-			}
-		}
-	}
+var chessWindow fyne.Window
+var boardContainer *fyne.Container
+var chessBoard *boardWidget
 
-	if len(validMoves) == 0 {
-		fmt.Println("No valid moves for selected piece, trying another piece")
-		bestMove(board)
+func handlePieceClick(row, col int) {
+	// the board is locked once the game is over
+	if currentGame.Over() {
 		return
 	}
 
-	randomMoveNo := rand.Intn(len(validMoves))
-	randomMove := validMoves[randomMoveNo]
-
-	movePiece(randomPiece[0], randomPiece[1], randomMove[0], randomMove[1])
-
-	if blackKing.IsCheck {
-		bestMove(board)
-		blackKing.IsCheck = false
+	pos := currentGame.PositionAt(viewPly)
+	whiteTurn := pos.WhiteTurn
+	if !isHumanSide(whiteTurn) {
 		return
 	}
-
-}
-
-func handlePieceClick(row, col int) {
-	clickedPiece := parsedBoard[row][col]
+	clickedPiece := pos.Board[row][col]
 
 	if !pieceSelected {
 		if clickedPiece != 0 && (whiteTurn == isWhite(clickedPiece)) {
 			selectedRow, selectedCol = row, col
 			pieceSelected = true
-			fmt.Printf("Selected piece at: %d, %d\n", row, col)
+			logger.Debug("piece selected", "square", handlers.Square{Row: row, Col: col})
+			redrawBoard()
 		}
 	} else {
 
@@ -139,477 +69,203 @@ func handlePieceClick(row, col int) {
 			(whiteTurn == isWhite(clickedPiece)) &&
 			(row != selectedRow || col != selectedCol) {
 			selectedRow, selectedCol = row, col
-			fmt.Printf("Reselected piece at: %d, %d\n", row, col)
+			logger.Debug("piece selected", "square", handlers.Square{Row: row, Col: col})
+			redrawBoard()
 			return
 		}
 
 		if row == selectedRow && col == selectedCol {
 			pieceSelected = false
-			fmt.Println("Piece deselected")
+			logger.Debug("piece deselected")
+			redrawBoard()
 			return
 		}
 
-		movePiece(selectedRow, selectedCol, row, col)
+		movePiece(handlers.Square{Row: selectedRow, Col: selectedCol}, handlers.Square{Row: row, Col: col})
 	}
 }
 
-func parseFEN(fen string) [8][8]rune {
-	var board [8][8]rune
-	rows := strings.Split(fen, "/")
-	for rowIdx, row := range rows {
-		colIdx := 0
-		for _, char := range row {
-			if char >= '1' && char <= '8' {
-				colIdx += int(char - '0')
-			} else {
-				board[rowIdx][colIdx] = char
-				colIdx++
-			}
-		}
-	}
-	return board
-}
-
-func isPathClear(fromRow, fromCol, toRow, toCol int) bool {
-	rowStep, colStep := 0, 0
-	piece := parsedBoard[fromRow][fromCol]
-	if piece == 'N' || piece == 'n' {
-		return true
-	}
-	if fromRow < toRow {
-		rowStep = 1
-	} else if fromRow > toRow {
-		rowStep = -1
-	}
-
-	if fromCol < toCol {
-		colStep = 1
-	} else if fromCol > toCol {
-		colStep = -1
-	}
-
-	r, c := fromRow+rowStep, fromCol+colStep
-	for r != toRow || c != toCol {
-		if parsedBoard[r][c] != 0 {
-			return false // Path is blocked
-		}
-		r += rowStep
-		c += colStep
-	}
-
-	return true
-}
-
-func abs(x int) int {
-	if x < 0 {
-		return -x
-	}
-	return x
-}
-
-func movePiece(fromRow, fromCol, toRow, toCol int) {
-	if fromRow == toRow && fromCol == toCol {
-		pieceSelected = false
+func movePiece(from, to handlers.Square) {
+	pieceSelected = false
+	redrawBoard()
+	if from == to {
 		return
 	}
 
-	piece := parsedBoard[fromRow][fromCol]
-	isWhitePiece := piece >= 'A' && piece <= 'Z'
-	targetPiece := parsedBoard[toRow][toCol]
-	isTargetWhitePiece := targetPiece >= 'A' && targetPiece <= 'Z'
-
-	if (whiteTurn && !isWhitePiece) || (!whiteTurn && isWhitePiece) {
-		fmt.Println("Not your turn!")
-		return
-	}
-	//handle castling here...
-	if (piece == 'K' || piece == 'k') && abs(fromCol-toCol) == 2 {
-		if handlers.IsCastleable(parsedBoard, fromRow, fromCol, toRow, toCol) {
-			performCastling(fromRow, fromCol, toRow, toCol, piece)
-			return
-		}
-		fmt.Println("Not Possible to castle")
-		pieceSelected = false
-		return
-	}
-	//khud ka mat kato
-	if targetPiece != 0 && ((whiteTurn && isTargetWhitePiece) || (!whiteTurn && !isTargetWhitePiece)) {
-		fmt.Println("Can't capture own piece")
-		return
+	pos := currentGame.PositionAt(viewPly)
+	piece := pos.PieceAt(from)
+	move := handlers.NewMove(from, to, 0)
+	if piece == 'P' && to.Row == 0 {
+		move.Promotion = 'Q'
+	} else if piece == 'p' && to.Row == 7 {
+		move.Promotion = 'q'
 	}
 
-	if targetPiece != 0 {
-		if isWhitePiece {
-			blackScore -= handlers.GetValue(targetPiece)
-		} else {
-			whiteScore -= handlers.GetValue(targetPiece)
-		}
-	}
-	//clear path
-	if !isPathClear(fromRow, fromCol, toRow, toCol) {
-		fmt.Println("Path is blocked for piece:", string(piece))
+	if isLiveView() {
+		submitMove(move)
 		return
 	}
-	var promotionPiece rune
-	if whiteTurn && toRow == 0 && piece == 'P' {
-		promotionPiece = 'Q'
-	} else if !whiteTurn && toRow == 7 && piece == 'p' {
-		promotionPiece = 'q'
-	}
-
-	if !handlers.IsValidMove(parsedBoard, piece, fromRow, fromCol, toRow, toCol, &promotionPiece) {
-		fmt.Println("Invalid move for piece:", string(piece))
+	// a game over the network only goes back through a takeback both players agree to
+	if lanSession != nil {
 		return
 	}
-
-	tempBoard := [8][8]rune{}
-	for i := 0; i < 8; i++ {
-		for j := 0; j < 8; j++ {
-			tempBoard[i][j] = parsedBoard[i][j]
-		}
-	}
-	tempBoard[toRow][toCol] = piece
-	tempBoard[fromRow][fromCol] = 0
-
-	kingToCheck := &whiteKing
-	opponentKing := &blackKing
-	if !isWhitePiece {
-		kingToCheck = &blackKing
-		opponentKing = &whiteKing
-	}
-
-	//change king position if selcted piece is king
-	if piece == 'K' {
-		whiteKing.Row, whiteKing.Col = toRow, toCol
-	} else if piece == 'k' {
-		blackKing.Row, blackKing.Col = toRow, toCol
-	}
-
-	if whiteKing.IsCheck && whiteTurn {
-		if !handlers.IsSquareUnderAttack(parsedBoard, whiteKing.Row, whiteKing.Col, true) {
-			whiteKing.IsCheck = false
-		}
-	} else if blackKing.IsCheck && !whiteTurn {
-		if !handlers.IsSquareUnderAttack(parsedBoard, blackKing.Row, blackKing.Col, false) {
-			blackKing.IsCheck = false
-		}
-	}
-
-	if handlers.IsSquareUnderAttack(tempBoard, kingToCheck.Row, kingToCheck.Col, isWhitePiece) {
-		fmt.Println("Move would leave your king in check!")
+	if err := pos.CheckMove(move); err != nil {
+		logger.Debug("move rejected", "move", move, "reason", err)
 		return
 	}
+	confirmTruncation(func() {
+		submitMove(move)
+	})
+}
 
-	if promotionPiece != 0 {
-		piece = promotionPiece
-	}
-
-	parsedBoard[toRow][toCol] = piece
-	parsedBoard[fromRow][fromCol] = 0
-
-	if piece == 'K' {
-		whiteKing.Row, whiteKing.Col = toRow, toCol
-	} else if piece == 'k' {
-		blackKing.Row, blackKing.Col = toRow, toCol
-	}
+// moveMade shows a move the controller played for either side; the board follows
+// the game unless the player is looking back through the history.
+func moveMade(move handlers.Move) {
+	wasLive := viewPly == currentGame.Ply()-1
+	logger.Debug("move played", "move", currentGame.SAN()[currentGame.Ply()-1], "fen", currentGame.Position().FEN())
 
-	if handlers.IsSquareUnderAttack(parsedBoard, opponentKing.Row, opponentKing.Col, !isWhitePiece) {
-		if isWhitePiece {
-			fmt.Println("Black KING is under check")
-			blackKing.IsCheck = true
-			// Check for checkmate
-			if isCheckmate(false) {
-				fmt.Println("Checkmate! White wins!")
-			}
-		} else {
-			fmt.Println("White KING is under check")
-			whiteKing.IsCheck = true
-			// Check for checkmate
-			if isCheckmate(true) {
-				fmt.Println("Checkmate! Black wins!")
-			}
-		}
-	} else {
-		if !isWhitePiece {
-			blackKing.IsCheck = false
-		} else {
-			whiteKing.IsCheck = false
-		}
+	if wasLive {
+		showPly(currentGame.Ply())
 	}
+	refreshHistory()
+}
 
-	fmt.Printf("Moved %c from (%d, %d) to (%d, %d)\n", piece, fromRow, fromCol, toRow, toCol)
+func isLiveView() bool {
+	return viewPly == currentGame.Ply()
+}
 
-	whiteTurn = !whiteTurn
+// showPly shows the position after the given number of half-moves and redraws every
+// square that changed, which covers castling, en passant and promotion as well as plain moves.
+func showPly(ply int) {
+	viewPly = max(0, min(ply, currentGame.Ply()))
 	pieceSelected = false
-
-	if !whiteTurn {
-		bestMove(parsedBoard)
-		//whiteTurn = !whiteTurn
-	}
-
-	updateBoardUI(fromRow, fromCol, toRow, toCol)
-
-	for i := 0; i < 8; i++ {
-		for j := 0; j < 8; j++ {
-			if parsedBoard[i][j] != 0 {
-				fmt.Printf("%c ", parsedBoard[i][j])
-			} else {
-				fmt.Printf("  ")
-			}
-
-		}
-		fmt.Println()
-	}
-	//fmt.Println(whiteKing.IsCheck, blackKing.IsCheck)
-	fmt.Println("White Score:", whiteScore, "Black Score:", blackScore)
-
+	redrawBoard()
+	refreshHistory()
 }
 
-func isCheckmate(isWhiteKing bool) bool {
-	kingPos := &blackKing
-	if isWhiteKing {
-		kingPos = &whiteKing
-	}
-
-	fmt.Printf("\n=== CHECKMATE ANALYSIS ===\n")
-	fmt.Printf("Analyzing position for %s king at (%d,%d)\n",
-		map[bool]string{true: "White", false: "Black"}[isWhiteKing],
-		kingPos.Row, kingPos.Col)
-
-	// First verify the king is actually in check
-	if !kingPos.IsCheck {
-		fmt.Println("King is not in check - cannot be checkmate")
-		return false
+// shownPosition is the position on the board: the one being set up in the editor, or
+// the game position at viewPly.
+func shownPosition() handlers.Position {
+	if editing {
+		return editPosition
 	}
+	return currentGame.PositionAt(viewPly)
+}
 
-	fmt.Println("\nChecking all possible king moves:")
-	// Try all possible king moves
-	kingMoves := [][2]int{
-		{-1, -1}, {-1, 0}, {-1, 1},
-		{0, -1}, {0, 1},
-		{1, -1}, {1, 0}, {1, 1},
+// redrawBoard redraws the board when a piece, highlight or arrow changed.
+func redrawBoard() {
+	updateAnalysis()
+	board := shownPosition().Board
+	marks := computeMarks()
+	arrows := computeArrows()
+	if board == displayedBoard && marks == displayedMarks && sameArrows(arrows, displayedArrows) {
+		return
 	}
-
-	kingPiece := 'k'
-	if isWhiteKing {
-		kingPiece = 'K'
+	boardChanged := board != displayedBoard
+	displayedBoard, displayedMarks, displayedArrows = board, marks, arrows
+	if chessBoard != nil {
+		chessBoard.Refresh()
 	}
-
-	// Check each possible king move
-	for _, move := range kingMoves {
-		newRow := kingPos.Row + move[0]
-		newCol := kingPos.Col + move[1]
-
-		if newRow >= 0 && newRow < 8 && newCol >= 0 && newCol < 8 {
-			// Create a deep copy of the board
-			tempBoard := [8][8]rune{}
-			for i := 0; i < 8; i++ {
-				for j := 0; j < 8; j++ {
-					tempBoard[i][j] = parsedBoard[i][j]
-				}
-			}
-
-			targetPiece := tempBoard[newRow][newCol]
-			isTargetWhitePiece := targetPiece >= 'A' && targetPiece <= 'Z'
-
-			fmt.Printf("Testing king move to (%d,%d): ", newRow, newCol)
-
-			if targetPiece == 0 || (isWhiteKing != isTargetWhitePiece) {
-				tempBoard[newRow][newCol] = kingPiece
-				tempBoard[kingPos.Row][kingPos.Col] = 0
-				if !handlers.IsSquareUnderAttack(tempBoard, newRow, newCol, isWhiteKing) {
-					fmt.Println("LEGAL MOVE FOUND - not checkmate")
-					return false
-				}
-				fmt.Println("square is under attack")
-
-			} else {
-				fmt.Println("square occupied by friendly piece")
-			}
-		}
+	if boardChanged {
+		refreshCaptures()
 	}
-
-	// fmt.Println("\nChecking if any piece can block or capture:")
-	// for row := 0; row < 8; row++ {
-	// 	for col := 0; col < 8; col++ {
-	// 		piece := parsedBoard[row][col]
-	// 		// Skip empty squares and opponent's pieces
-	// 		if piece == 0 || (isWhiteKing != (piece >= 'A' && piece <= 'Z')) {
-	// 			continue
-	// 		}
-
-	// 		fmt.Printf("\nTesting piece %c at (%d,%d):\n", piece, row, col)
-	// 		// Try every possible destination
-	// 		for toRow := 0; toRow < 8; toRow++ {
-	// 			for toCol := 0; toCol < 8; toCol++ {
-	// 				if handlers.IsValidMove(parsedBoard, piece, row, col, toRow, toCol, nil) {
-	// 					// Create a copy of the board
-	// 					tempBoard := [8][8]rune{}
-	// 					for i := 0; i < 8; i++ {
-	// 						for j := 0; j < 8; j++ {
-	// 							tempBoard[i][j] = parsedBoard[i][j]
-	// 						}
-	// 					}
-
-	// 					tempBoard[toRow][toCol] = piece
-	// 					tempBoard[row][col] = 0
-
-	// 					if (piece == 'K' && isWhiteKing) || (piece == 'k' && !isWhiteKing) {
-	// 						if handlers.IsSquareUnderAttack(tempBoard, toRow, toCol, isWhiteKing) {
-	// 							continue
-	// 						}
-	// 					}
-
-	// 					kingCheckRow := kingPos.Row
-	// 					kingCheckCol := kingPos.Col
-	// 					if piece == kingPiece {
-	// 						kingCheckRow = toRow
-	// 						kingCheckCol = toCol
-	// 					}
-
-	// 					if !handlers.IsSquareUnderAttack(tempBoard, kingCheckRow, kingCheckCol, isWhiteKing) {
-	// 						fmt.Printf("Piece can move to (%d,%d) to prevent checkmate\n", toRow, toCol)
-	// 						return false
-	// 					}
-	// 				}
-	// 			}
-	// 		}
-	// 	}
-	// }
-
-	fmt.Println("\nCHECKMATE CONFIRMED!")
-	return true
 }
 
-func performCastling(fromRow, fromCol, toRow, toCol int, piece rune) {
-	isKingSide := toCol > fromCol
-	rookFromCol := 0
-	rookToCol := 3
-	if isKingSide {
-		rookFromCol = 7
-		rookToCol = 5
-	}
-
-	parsedBoard[toRow][toCol] = piece
-	parsedBoard[fromRow][fromCol] = 0
-
-	rook := 'R'
-	if piece == 'k' {
-		rook = 'r'
-	}
-	parsedBoard[toRow][rookToCol] = rook
-	parsedBoard[toRow][rookFromCol] = 0
-
-	updateBoardUI(fromRow, fromCol, toRow, toCol)
-	updateBoardUI(toRow, rookFromCol, toRow, rookToCol)
-
-	whiteTurn = !whiteTurn
-	pieceSelected = false
+func isWhite(piece rune) bool {
+	return piece == 'P' || piece == 'N' || piece == 'B' || piece == 'R' || piece == 'Q' || piece == 'K'
 }
 
-func updateBoardUI(fromRow, fromCol, toRow, toCol int) {
-
-	fromCell := boardCells[fromRow][fromCol]
-	toCell := boardCells[toRow][toCol]
+func generateChessBoard() *boardWidget {
+	displayedBoard = shownPosition().Board
+	displayedMarks = computeMarks()
+	displayedArrows = computeArrows()
+	chessBoard = newBoardWidget()
+	return chessBoard
+}
 
-	fromSquareColor := color.White
-	if (fromRow+fromCol)%2 == 1 {
-		fromSquareColor = color.Black
-	}
-	toSquareColor := color.White
-	if (toRow+toCol)%2 == 1 {
-		toSquareColor = color.Black
-	}
+// startNewGame resets the board; the engine moves first when it plays White.
+func startNewGame() {
+	startGame(newGameFrom(handlers.StartPosition()))
+}
 
-	fromSquare := canvas.NewRectangle(fromSquareColor)
-	toSquare := canvas.NewRectangle(toSquareColor)
-	fromSquare.SetMinSize(fyne.NewSize(75, 75))
-	toSquare.SetMinSize(fyne.NewSize(75, 75))
+// newGameFrom starts a game record from pos with the current players' names.
+func newGameFrom(pos handlers.Position) *game.Game {
+	g := game.NewFromPosition(pos)
+	g.Tags["White"], g.Tags["Black"] = playerName(true), playerName(false)
+	return g
+}
 
-	fromButton := widget.NewButton(" ", func() {
-		handlePieceClick(fromRow, fromCol)
-	})
-	toButton := widget.NewButton(" ", func() {
-		handlePieceClick(toRow, toCol)
-	})
-	fromButton.Importance = widget.LowImportance
-	toButton.Importance = widget.LowImportance
-	fromButton.Resize(fyne.NewSize(75, 75))
-	toButton.Resize(fyne.NewSize(75, 75))
-
-	fromCell.Objects = []fyne.CanvasObject{fromSquare, fromButton}
-
-	toCell.Objects = []fyne.CanvasObject{toSquare}
-	if piece := parsedBoard[toRow][toCol]; piece != 0 {
-		imagePath := filepath.Join(pieceDir, mpPieceToImage[piece])
-		pieceImage := canvas.NewImageFromFile(imagePath)
-		pieceImage.FillMode = canvas.ImageFillContain
-		pieceImage.Resize(fyne.NewSize(75, 75))
-		toCell.Objects = append(toCell.Objects, pieceImage)
+// startGame makes g the current game and shows its last position, leaving the board
+// editor and any game over the network, and asks the side to move for its move.
+func startGame(g *game.Game) {
+	if g != lanGame {
+		closeLANGame()
 	}
-	toCell.Objects = append(toCell.Objects, toButton)
+	stopPlay()
+	closeEditor()
+	currentGame = g
+	pieceSelected = false
 
-	fromCell.Refresh()
-	toCell.Refresh()
-}
-func isWhite(piece rune) bool {
-	return piece == 'P' || piece == 'N' || piece == 'B' || piece == 'R' || piece == 'Q' || piece == 'K'
-}
+	showPly(currentGame.Ply())
+	resetClock()
 
-func generateChessBoard() *fyne.Container {
-	board := container.NewGridWithColumns(boardSize)
-
-	for row := 0; row < boardSize; row++ {
-		for col := 0; col < boardSize; col++ {
-			squareColor := color.White
-			if (row+col)%2 == 1 {
-				squareColor = color.Black
-			}
-			square := canvas.NewRectangle(squareColor)
-			square.SetMinSize(fyne.NewSize(75, 75))
-
-			cell := container.NewStack(square)
-
-			rowCopy, colCopy := row, col
-			tapButton := widget.NewButton(" ", func() {
-				handlePieceClick(rowCopy, colCopy)
-			})
-			tapButton.Importance = widget.LowImportance
-			tapButton.Resize(fyne.NewSize(75, 75))
-
-			if piece := parsedBoard[row][col]; piece != 0 {
-				imagePath := filepath.Join(pieceDir, mpPieceToImage[piece])
-				pieceImage := canvas.NewImageFromFile(imagePath)
-				pieceImage.FillMode = canvas.ImageFillContain
-				pieceImage.Resize(fyne.NewSize(75, 75))
-				cell = container.NewStack(square, pieceImage, tapButton)
-			} else {
-				cell = container.NewStack(square, tapButton)
-			}
-
-			boardCells[row][col] = cell
-			board.Add(cell)
-		}
+	if currentGame.Over() {
+		showGameOverDialog()
+		return
 	}
-
-	return board
+	resumePlay()
 }
 
 func main() {
-	chessApp := app.New()
-	//a := app.New()
-	//w := a.NewWindow("Chess Game")
+	chessApp := app.NewWithID("io.github.prajanyasharma.chessgo")
+	loadAppearance(chessApp.Preferences())
+	loadEngines(chessApp.Preferences())
+	chessApp.Lifecycle().SetOnStopped(closeEngines)
 	window := chessApp.NewWindow("Chess Game")
-	window.Resize(fyne.NewSize(600, 600))
+	window.Resize(fyne.NewSize(900, 700))
+	chessWindow = window
 
-	parsedBoard = parseFEN(strings.Split(startFenNotation, " ")[0])
+	resignButton := widget.NewButton("Resign", func() {
+		if currentGame.Over() {
+			return
+		}
+		stopPlay()
+		if lanSession != nil {
+			lanSession.Resign()
+		}
+		currentGame.Resign(resigningSide())
+		showGameOverDialog()
+	})
 
-	boardContainer = container.NewVBox(
-		widget.NewLabel("Chess Game"),
+	newGameButton := widget.NewButton("New Game", showNewGameDialog)
+	timeControlButton := widget.NewButton("Time Control", showTimeControlDialog)
+	flipButton := widget.NewButtonWithIcon("Flip", theme.ViewRefreshIcon(), flipBoard)
+	appearanceButton := widget.NewButtonWithIcon("", theme.ColorPaletteIcon(), showAppearanceDialog)
+	editButton := widget.NewButtonWithIcon("Edit", theme.DocumentCreateIcon(), openEditor)
+	analysisCheck := widget.NewCheck("Analysis", setAnalysis)
+
+	whiteClockText, blackClockText = newClockText(), newClockText()
+	scoreBar = newEvalBar()
+	whiteCaptures, blackCaptures = newCapturesBox(), newCapturesBox()
+	whiteClockRow = newClockRow("White", whiteClockText, whiteCaptures)
+	blackClockRow = newClockRow("Black", blackClockText, blackCaptures)
+	topClockSlot, bottomClockSlot = container.NewStack(), container.NewStack()
+	boardContainer = container.NewBorder(
+		container.NewVBox(
+			container.NewHBox(widget.NewLabel("Chess Game"), newGameButton, resignButton, timeControlButton, editButton, flipButton, appearanceButton, analysisCheck),
+			topClockSlot,
+		),
+		bottomClockSlot, scoreBar, nil,
 		generateChessBoard(),
 	)
-
-	window.SetContent(boardContainer)
-	//showWinnerNotification(a, w, "White")
+	applyOrientation()
+	historyPanel = newHistoryPanel()
+	sidePanel = container.NewStack(historyPanel)
+	startNewGame()
+	runClockTicker()
+
+	window.Canvas().SetOnTypedKey(handleHistoryKey)
+	window.SetMainMenu(fyne.NewMainMenu(newGameMenu(window)))
+	window.SetContent(container.NewBorder(nil, nil, nil, sidePanel, boardContainer))
 	window.ShowAndRun()
 }
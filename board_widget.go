@@ -0,0 +1,495 @@
+package main
+
+import (
+	"chess-engine/game"
+	"chess-engine/handlers"
+	"image/color"
+	"math"
+	"strconv"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/widget"
+)
+
+// minSquareSize keeps the board usable when the window is made small.
+const minSquareSize = 40
+
+// boardArrow is an arrow drawn above the pieces from one square to another.
+type boardArrow struct {
+	fromRow, fromCol int
+	toRow, toCol     int
+	color            color.Color
+}
+
+// displayedArrows are the arrows the board widget currently draws.
+var displayedArrows []boardArrow
+
+// boardWidget draws displayedBoard, displayedMarks and displayedArrows, scaled to
+// fill the space it is given. Taps go to handlePieceClick, so click-click moves keep
+// working, and pieces of the side to move can be dragged to their target square. An
+// illegal drop leaves the board unchanged, which snaps the piece back. With the right
+// button the player circles squares and draws arrows.
+type boardWidget struct {
+	widget.BaseWidget
+
+	dragging                 bool
+	dragFromRow, dragFromCol int
+	dragPos                  fyne.Position
+	dragImage                *canvas.Image
+
+	// drawing is set while the right button is down, drawFrom is where it went down
+	drawing  bool
+	drawFrom handlers.Square
+
+	// styleVersion goes up when the board theme or piece set changes
+	styleVersion int
+}
+
+func newBoardWidget() *boardWidget {
+	b := &boardWidget{}
+	b.ExtendBaseWidget(b)
+	return b
+}
+
+// geometry returns the top-left corner of the board and the side of one square; the
+// board is the largest square that fits the widget, centered in it.
+func (b *boardWidget) geometry() (fyne.Position, float32) {
+	size := b.Size()
+	side := float32(math.Floor(float64(min(size.Width, size.Height) / boardSize)))
+	side = max(side, minSquareSize)
+	origin := fyne.NewPos((size.Width-side*boardSize)/2, (size.Height-side*boardSize)/2)
+	return fyne.NewPos(max(origin.X, 0), max(origin.Y, 0)), side
+}
+
+// squareAt maps a point on the widget to board coordinates, taking the orientation into account.
+func (b *boardWidget) squareAt(pos fyne.Position) (row, col int, ok bool) {
+	origin, side := b.geometry()
+	pos = pos.Subtract(origin)
+	if pos.X < 0 || pos.Y < 0 {
+		return 0, 0, false
+	}
+	row, col = int(pos.Y/side), int(pos.X/side)
+	if row >= boardSize || col >= boardSize {
+		return 0, 0, false
+	}
+	if boardFlipped {
+		row, col = boardSize-1-row, boardSize-1-col
+	}
+	return row, col, true
+}
+
+// squareOrigin is the top-left corner of a board square on the widget.
+func (b *boardWidget) squareOrigin(row, col int) fyne.Position {
+	origin, side := b.geometry()
+	if boardFlipped {
+		row, col = boardSize-1-row, boardSize-1-col
+	}
+	return origin.Add(fyne.NewPos(float32(col)*side, float32(row)*side))
+}
+
+// squareCenter is the middle of a board square on the widget.
+func (b *boardWidget) squareCenter(row, col int) fyne.Position {
+	_, side := b.geometry()
+	return b.squareOrigin(row, col).Add(fyne.NewPos(side/2, side/2))
+}
+
+func (b *boardWidget) Tapped(event *fyne.PointEvent) {
+	row, col, ok := b.squareAt(event.Position)
+	if !ok {
+		return
+	}
+	if editing {
+		editSquare(row, col)
+		return
+	}
+	handlePieceClick(row, col)
+}
+
+// Dragged picks up the piece under the pointer on the first event and then lets it follow the pointer.
+func (b *boardWidget) Dragged(event *fyne.DragEvent) {
+	if !b.dragging {
+		start := event.Position.Subtract(event.Dragged)
+		row, col, ok := b.squareAt(start)
+		if !ok || !canPickUp(row, col) {
+			return
+		}
+		b.dragging = true
+		b.dragFromRow, b.dragFromCol = row, col
+		b.dragImage = newPieceImage(displayedBoard[row][col])
+		selectedRow, selectedCol, pieceSelected = row, col, true
+		b.dragPos = event.Position
+		redrawBoard()
+		b.Refresh()
+		return
+	}
+
+	b.dragPos = event.Position
+	b.placeDragImage()
+	canvas.Refresh(b.dragImage)
+}
+
+// DragEnd drops the piece: on another square it tries the move, on its own square
+// or outside the board it stays selected where it was.
+func (b *boardWidget) DragEnd() {
+	if !b.dragging {
+		return
+	}
+	b.dragging = false
+	row, col, ok := b.squareAt(b.dragPos)
+	if ok && (row != b.dragFromRow || col != b.dragFromCol) {
+		movePiece(handlers.Square{Row: b.dragFromRow, Col: b.dragFromCol}, handlers.Square{Row: row, Col: col})
+	}
+	b.Refresh()
+}
+
+// MouseDown starts a shape when the right button goes down on a square.
+func (b *boardWidget) MouseDown(event *desktop.MouseEvent) {
+	b.drawing = false
+	if event.Button != desktop.MouseButtonSecondary || editing {
+		return
+	}
+	row, col, ok := b.squareAt(event.Position)
+	if !ok {
+		return
+	}
+	b.drawing, b.drawFrom = true, handlers.Square{Row: row, Col: col}
+}
+
+// MouseUp finishes the shape: released on the square it started on it circles the
+// square, on another square it draws an arrow.
+func (b *boardWidget) MouseUp(event *desktop.MouseEvent) {
+	if !b.drawing || event.Button != desktop.MouseButtonSecondary {
+		return
+	}
+	b.drawing = false
+	row, col, ok := b.squareAt(event.Position)
+	if !ok {
+		return
+	}
+	drawShape(game.Shape{Color: shapeColor(event.Modifier), From: b.drawFrom, To: handlers.Square{Row: row, Col: col}})
+}
+
+// restyle redraws every square after the board theme or piece set changed.
+func (b *boardWidget) restyle() {
+	b.styleVersion++
+	b.Refresh()
+}
+
+// placeDragImage centers the dragged piece on the pointer.
+func (b *boardWidget) placeDragImage() {
+	_, side := b.geometry()
+	b.dragImage.Resize(fyne.NewSize(side, side))
+	b.dragImage.Move(b.dragPos.Subtract(fyne.NewPos(side/2, side/2)))
+}
+
+func (b *boardWidget) MinSize() fyne.Size {
+	return fyne.NewSize(boardSize*minSquareSize, boardSize*minSquareSize)
+}
+
+func (b *boardWidget) CreateRenderer() fyne.WidgetRenderer {
+	r := &boardRenderer{board: b}
+	for i := 0; i < boardSize; i++ {
+		r.files[i] = canvas.NewText("", color.Black)
+		r.ranks[i] = canvas.NewText("", color.Black)
+	}
+	r.Refresh()
+	return r
+}
+
+// canPickUp reports whether the piece on a square may be dragged right now.
+func canPickUp(row, col int) bool {
+	piece := displayedBoard[row][col]
+	if piece == 0 || editing || currentGame.Over() {
+		return false
+	}
+	whiteTurn := currentGame.PositionAt(viewPly).WhiteTurn
+	return isWhite(piece) == whiteTurn && isHumanSide(whiteTurn)
+}
+
+func newPieceImage(piece rune) *canvas.Image {
+	image := canvas.NewImageFromResource(pieceResource(piece))
+	image.FillMode = canvas.ImageFillContain
+	return image
+}
+
+// squareCell holds the canvas objects of one square and what they were drawn for,
+// so a refresh only touches the squares whose piece or highlights changed.
+type squareCell struct {
+	drawn      bool
+	piece      rune
+	marks      squareMarks
+	background *canvas.Rectangle
+	below      []markOverlay
+	pieceImage *canvas.Image
+	above      []markOverlay
+}
+
+type boardRenderer struct {
+	board *boardWidget
+	cells [boardSize][boardSize]squareCell
+
+	// files and ranks are the coordinate labels along the bottom and left edge
+	files, ranks [boardSize]*canvas.Text
+
+	arrows      []boardArrow
+	arrowShapes []*canvas.Line
+
+	// flipped and styleVersion are the orientation and style the squares were last drawn with
+	flipped      bool
+	styleVersion int
+	objects      []fyne.CanvasObject
+}
+
+func (r *boardRenderer) Destroy() {}
+
+func (r *boardRenderer) MinSize() fyne.Size {
+	return r.board.MinSize()
+}
+
+func (r *boardRenderer) Objects() []fyne.CanvasObject {
+	return r.objects
+}
+
+func (r *boardRenderer) Layout(fyne.Size) {
+	for row := 0; row < boardSize; row++ {
+		for col := 0; col < boardSize; col++ {
+			r.layoutCell(row, col)
+		}
+	}
+	r.layoutCoordinates()
+	r.layoutArrows()
+	if r.board.dragging {
+		r.board.placeDragImage()
+	}
+}
+
+// layoutCoordinates labels the files in the bottom corner of the squares along the
+// bottom edge and the ranks in the top corner along the left edge, whichever side
+// of the board is at the bottom. Each label takes the color of the other squares.
+func (r *boardRenderer) layoutCoordinates() {
+	_, side := r.board.geometry()
+	style := fyne.TextStyle{Bold: true}
+	textSize := max(side*0.22, 10)
+	for i := 0; i < boardSize; i++ {
+		// i counts squares from the left along the bottom edge and from the top along the left edge
+		bottom := handlers.Square{Row: boardSize - 1, Col: i}
+		left := handlers.Square{Row: i, Col: 0}
+		if boardFlipped {
+			bottom = handlers.Square{Row: 0, Col: boardSize - 1 - i}
+			left = handlers.Square{Row: boardSize - 1 - i, Col: boardSize - 1}
+		}
+
+		file := r.files[i]
+		file.Text = string(bottom.File())
+		file.TextSize, file.TextStyle = textSize, style
+		file.Color = squareColor(bottom.Row, bottom.Col+1)
+		fileSize := fyne.MeasureText(file.Text, textSize, style)
+		file.Resize(fileSize)
+		file.Move(r.board.squareOrigin(bottom.Row, bottom.Col).Add(fyne.NewPos(side-fileSize.Width-2, side-fileSize.Height)))
+
+		rank := r.ranks[i]
+		rank.Text = strconv.Itoa(left.Rank())
+		rank.TextSize, rank.TextStyle = textSize, style
+		rank.Color = squareColor(left.Row, left.Col+1)
+		rank.Resize(fyne.MeasureText(rank.Text, textSize, style))
+		rank.Move(r.board.squareOrigin(left.Row, left.Col).Add(fyne.NewPos(2, 0)))
+	}
+}
+
+// layoutCell places the objects of one square; overlays smaller than the square are centered in it.
+func (r *boardRenderer) layoutCell(row, col int) {
+	cell := &r.cells[row][col]
+	origin := r.board.squareOrigin(row, col)
+	_, side := r.board.geometry()
+	square := fyne.NewSize(side, side)
+
+	cell.background.Move(origin)
+	cell.background.Resize(square)
+	place := func(overlays []markOverlay) {
+		for _, overlay := range overlays {
+			size := side * overlay.scale
+			overlay.object.Resize(fyne.NewSize(size, size))
+			overlay.object.Move(origin.Add(fyne.NewPos((side-size)/2, (side-size)/2)))
+		}
+	}
+	place(cell.below)
+	if cell.pieceImage != nil {
+		cell.pieceImage.Move(origin)
+		cell.pieceImage.Resize(square)
+	}
+	place(cell.above)
+}
+
+// layoutArrows draws each arrow as a shaft from the middle of its first square and a
+// two-stroke head ending near the middle of its last square.
+func (r *boardRenderer) layoutArrows() {
+	_, side := r.board.geometry()
+	for i, arrow := range r.arrows {
+		from := r.board.squareCenter(arrow.fromRow, arrow.fromCol)
+		to := r.board.squareCenter(arrow.toRow, arrow.toCol)
+		dx, dy := float64(to.X-from.X), float64(to.Y-from.Y)
+		length := math.Hypot(dx, dy)
+		if length == 0 {
+			continue
+		}
+		ux, uy := dx/length, dy/length
+		headLength := float64(side) * 0.35
+		tip := fyne.NewPos(to.X-float32(ux*float64(side)*0.15), to.Y-float32(uy*float64(side)*0.15))
+
+		shaft, left, right := r.arrowShapes[3*i], r.arrowShapes[3*i+1], r.arrowShapes[3*i+2]
+		shaft.StrokeWidth = side * 0.16
+		shaft.Position1 = from
+		shaft.Position2 = fyne.NewPos(tip.X-float32(ux*headLength*0.5), tip.Y-float32(uy*headLength*0.5))
+		for j, head := range []*canvas.Line{left, right} {
+			angle := math.Pi * 5 / 6
+			if j == 1 {
+				angle = -angle
+			}
+			sin, cos := math.Sincos(angle)
+			head.StrokeWidth = side * 0.12
+			head.Position1 = tip
+			head.Position2 = fyne.NewPos(
+				tip.X+float32((ux*cos-uy*sin)*headLength),
+				tip.Y+float32((ux*sin+uy*cos)*headLength),
+			)
+		}
+	}
+}
+
+// Refresh brings the squares and arrows in line with displayedBoard, displayedMarks
+// and displayedArrows, recreating and redrawing only what changed.
+func (r *boardRenderer) Refresh() {
+	var changed []fyne.CanvasObject
+	if r.styleVersion != r.board.styleVersion {
+		r.styleVersion = r.board.styleVersion
+		for row := 0; row < boardSize; row++ {
+			for col := 0; col < boardSize; col++ {
+				r.cells[row][col].drawn = false
+			}
+		}
+		r.layoutCoordinates()
+		for i := 0; i < boardSize; i++ {
+			changed = append(changed, r.files[i], r.ranks[i])
+		}
+	}
+	for row := 0; row < boardSize; row++ {
+		for col := 0; col < boardSize; col++ {
+			piece := displayedBoard[row][col]
+			if r.board.dragging && row == r.board.dragFromRow && col == r.board.dragFromCol {
+				piece = 0
+			}
+			cell := &r.cells[row][col]
+			if cell.drawn && cell.piece == piece && cell.marks == displayedMarks[row][col] {
+				continue
+			}
+			changed = append(changed, cell.objects()...)
+			r.updateCell(row, col, piece)
+			r.layoutCell(row, col)
+			changed = append(changed, cell.objects()...)
+		}
+	}
+
+	if !sameArrows(r.arrows, displayedArrows) {
+		for _, shape := range r.arrowShapes {
+			changed = append(changed, shape)
+		}
+		r.arrows = append(r.arrows[:0], displayedArrows...)
+		r.arrowShapes = r.arrowShapes[:0]
+		for _, arrow := range r.arrows {
+			for i := 0; i < 3; i++ {
+				shape := canvas.NewLine(arrow.color)
+				r.arrowShapes = append(r.arrowShapes, shape)
+				changed = append(changed, shape)
+			}
+		}
+		r.layoutArrows()
+	}
+	if r.board.dragImage != nil {
+		changed = append(changed, r.board.dragImage)
+	}
+
+	r.relist()
+	if r.flipped != boardFlipped {
+		r.flipped = boardFlipped
+		r.Layout(r.board.Size())
+		canvas.Refresh(r.board)
+		return
+	}
+	if r.board.dragging {
+		r.board.placeDragImage()
+	}
+	for _, object := range changed {
+		canvas.Refresh(object)
+	}
+}
+
+// objects lists what is drawn on the square, bottom to top.
+func (c *squareCell) objects() []fyne.CanvasObject {
+	if c.background == nil {
+		return nil
+	}
+	objects := []fyne.CanvasObject{c.background}
+	for _, overlay := range c.below {
+		objects = append(objects, overlay.object)
+	}
+	if c.pieceImage != nil {
+		objects = append(objects, c.pieceImage)
+	}
+	for _, overlay := range c.above {
+		objects = append(objects, overlay.object)
+	}
+	return objects
+}
+
+// updateCell recreates the piece and highlight objects of one square.
+func (r *boardRenderer) updateCell(row, col int, piece rune) {
+	cell := &r.cells[row][col]
+	if cell.background == nil {
+		cell.background = canvas.NewRectangle(color.Transparent)
+	}
+	cell.background.FillColor = squareColor(row, col)
+	if piece != cell.piece || !cell.drawn {
+		cell.pieceImage = nil
+		if piece != 0 {
+			cell.pieceImage = newPieceImage(piece)
+		}
+	}
+	cell.marks = displayedMarks[row][col]
+	cell.below, cell.above = markOverlays(cell.marks)
+	cell.piece = piece
+	cell.drawn = true
+}
+
+// relist orders the objects: each square with its highlights and piece, then the
+// coordinates and arrows, and the dragged piece last so it stays above everything else.
+func (r *boardRenderer) relist() {
+	r.objects = r.objects[:0]
+	for row := 0; row < boardSize; row++ {
+		for col := 0; col < boardSize; col++ {
+			r.objects = append(r.objects, r.cells[row][col].objects()...)
+		}
+	}
+	for i := 0; i < boardSize; i++ {
+		r.objects = append(r.objects, r.files[i], r.ranks[i])
+	}
+	for _, shape := range r.arrowShapes {
+		r.objects = append(r.objects, shape)
+	}
+	if r.board.dragging {
+		r.objects = append(r.objects, r.board.dragImage)
+	}
+}
+
+func sameArrows(a, b []boardArrow) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
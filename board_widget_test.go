@@ -0,0 +1,88 @@
+package main
+
+import (
+	"testing"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/test"
+)
+
+func TestBoardGeometry(t *testing.T) {
+	test.NewApp()
+	defer func() { boardFlipped = false }()
+
+	tests := []struct {
+		name   string
+		size   fyne.Size
+		origin fyne.Position
+		side   float32
+	}{
+		{"square", fyne.NewSize(400, 400), fyne.NewPos(0, 0), 50},
+		{"wide", fyne.NewSize(500, 400), fyne.NewPos(50, 0), 50},
+		{"tall", fyne.NewSize(400, 460), fyne.NewPos(0, 30), 50},
+		{"rounded down", fyne.NewSize(420, 420), fyne.NewPos(2, 2), 52},
+		{"too small", fyne.NewSize(100, 100), fyne.NewPos(0, 0), minSquareSize},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBoardWidget()
+			b.Resize(tt.size)
+			origin, side := b.geometry()
+			if origin != tt.origin || side != tt.side {
+				t.Errorf("geometry() = %v, %v, want %v, %v", origin, side, tt.origin, tt.side)
+			}
+		})
+	}
+}
+
+func TestSquareAt(t *testing.T) {
+	test.NewApp()
+	defer func() { boardFlipped = false }()
+	b := newBoardWidget()
+	b.Resize(fyne.NewSize(500, 400))
+
+	tests := []struct {
+		name     string
+		flipped  bool
+		pos      fyne.Position
+		row, col int
+		ok       bool
+	}{
+		{"a8", false, fyne.NewPos(51, 1), 0, 0, true},
+		{"h1", false, fyne.NewPos(449, 399), 7, 7, true},
+		{"e2", false, fyne.NewPos(275, 325), 6, 4, true},
+		{"left of the board", false, fyne.NewPos(49, 200), 0, 0, false},
+		{"right of the board", false, fyne.NewPos(451, 200), 0, 0, false},
+		{"flipped h1", true, fyne.NewPos(51, 1), 7, 7, true},
+		{"flipped e2", true, fyne.NewPos(225, 75), 6, 4, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			boardFlipped = tt.flipped
+			row, col, ok := b.squareAt(tt.pos)
+			if ok != tt.ok || (ok && (row != tt.row || col != tt.col)) {
+				t.Errorf("squareAt(%v) = %d, %d, %v, want %d, %d, %v", tt.pos, row, col, ok, tt.row, tt.col, tt.ok)
+			}
+		})
+	}
+}
+
+// TestSquareCenter checks that the center of every square maps back to it, in both orientations.
+func TestSquareCenter(t *testing.T) {
+	test.NewApp()
+	defer func() { boardFlipped = false }()
+	b := newBoardWidget()
+	b.Resize(fyne.NewSize(480, 520))
+
+	for _, flipped := range []bool{false, true} {
+		boardFlipped = flipped
+		for row := 0; row < boardSize; row++ {
+			for col := 0; col < boardSize; col++ {
+				r, c, ok := b.squareAt(b.squareCenter(row, col))
+				if !ok || r != row || c != col {
+					t.Errorf("flipped %v: squareAt(squareCenter(%d, %d)) = %d, %d, %v", flipped, row, col, r, c, ok)
+				}
+			}
+		}
+	}
+}
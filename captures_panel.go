@@ -0,0 +1,62 @@
+package main
+
+import (
+	"chess-engine/handlers"
+	"fmt"
+	"unicode"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/widget"
+)
+
+const captureIconSize = 22
+
+// whiteCaptures and blackCaptures show the pieces each side has taken, next to its clock.
+var whiteCaptures, blackCaptures *fyne.Container
+
+// refreshCaptures works out the captured pieces and the material difference from the
+// board on display, so they follow the history view and loaded positions as well.
+func refreshCaptures() {
+	if whiteCaptures == nil {
+		return
+	}
+	showCaptures(whiteCaptures, displayedBoard, true)
+	showCaptures(blackCaptures, displayedBoard, false)
+}
+
+// showCaptures fills box with the opponent pieces the given side has taken, most
+// valuable first and grouped by type, followed by "+N" when that side is ahead.
+func showCaptures(box *fyne.Container, board [8][8]rune, white bool) {
+	box.Objects = nil
+	missing := handlers.MissingPieces(board, !white)
+	for _, kind := range "QRBNP" {
+		if missing[kind] == 0 {
+			continue
+		}
+		piece := kind
+		if white {
+			piece = unicode.ToLower(kind)
+		}
+		// pieces of one type overlap, so each group reads as a stack
+		group := container.New(layout.NewCustomPaddedHBoxLayout(-captureIconSize / 2))
+		for i := 0; i < missing[kind]; i++ {
+			image := canvas.NewImageFromResource(pieceResource(piece))
+			image.FillMode = canvas.ImageFillContain
+			image.SetMinSize(fyne.NewSize(captureIconSize, captureIconSize))
+			group.Add(image)
+		}
+		box.Add(group)
+	}
+
+	if diff := handlers.Material(board, white) - handlers.Material(board, !white); diff > 0 {
+		box.Add(widget.NewLabel(fmt.Sprintf("+%d", diff)))
+	}
+	box.Refresh()
+}
+
+func newCapturesBox() *fyne.Container {
+	return container.New(layout.NewCustomPaddedHBoxLayout(6))
+}
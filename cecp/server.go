@@ -0,0 +1,379 @@
+// Package cecp speaks the Chess Engine Communication Protocol (version 2), the
+// protocol of XBoard, WinBoard and older tools. It drives the same engine as the
+// UCI front end and has no GUI dependencies.
+package cecp
+
+import (
+	"bufio"
+	"chess-engine/engine"
+	"chess-engine/game"
+	"chess-engine/handlers"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EngineName is how the engine introduces itself to the GUI.
+const EngineName = "chessgo"
+
+// features are sent in reply to protover: moves come as usermove in coordinate
+// notation, positions with setboard, and the clocks with time and otim.
+var features = []string{
+	`myname="` + EngineName + `"`,
+	"ping=1", "setboard=1", "usermove=1", "san=0", "time=1", "draw=0",
+	"sigint=0", "sigterm=0", "reuse=1", "analyze=0", "colors=0", "memory=1", "smp=1",
+	"done=1",
+}
+
+// Server answers the commands of an XBoard-compatible GUI for one engine. The
+// engine thinks in the background, so commands such as ?, force and quit are
+// handled while it searches.
+type Server struct {
+	engine *engine.Engine
+
+	outMu sync.Mutex
+	out   io.Writer
+
+	// mu guards the game state below, which the thinking goroutine updates when it moves
+	mu          sync.Mutex
+	game        *game.Game
+	force       bool // only record moves, never think
+	engineWhite bool // the side the engine plays
+	post        bool // print thinking output
+	ponder      bool // keep searching on the opponent's time (hard)
+	job         *thinking
+
+	// time control
+	movesPerSession          int // 0 when the base time is for the whole game
+	base, increment          time.Duration
+	moveTime                 time.Duration // st, exact time per move
+	depth                    int           // sd, 0 for no limit
+	engineClock, playerClock time.Duration
+}
+
+// thinking is a search in the background: the engine's own move, or pondering on the opponent's time.
+type thinking struct {
+	cancel    context.CancelFunc
+	done      chan struct{} // closed when the search returns
+	pondering bool
+}
+
+// NewServer returns a server for e that writes its replies to out.
+func NewServer(e *engine.Engine, out io.Writer) *Server {
+	s := &Server{engine: e, out: out, base: 5 * time.Minute}
+	s.reset(handlers.StartPosition())
+	return s
+}
+
+// Run reads commands from in until quit or the end of the input.
+func (s *Server) Run(in io.Reader) error {
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		if !s.Handle(scanner.Text()) {
+			return nil
+		}
+	}
+	s.mu.Lock()
+	s.abandon()
+	s.mu.Unlock()
+	return scanner.Err()
+}
+
+// Handle carries out one command line and reports whether to keep reading; it is
+// false after quit.
+func (s *Server) Handle(line string) bool {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return true
+	}
+	command, args := fields[0], fields[1:]
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch command {
+	case "xboard", "accepted", "rejected", "random", "computer", "name", "rating", "ics", "draw", "white", "black":
+		// nothing to do: draw offers are declined by not answering them
+	case "protover":
+		s.send("feature " + strings.Join(features, " "))
+	case "ping":
+		s.send("pong " + strings.Join(args, " "))
+	case "new":
+		s.abandon()
+		s.reset(handlers.StartPosition())
+		s.depth = 0
+	case "setboard":
+		s.setBoard(strings.Join(args, " "))
+	case "force":
+		s.abandon()
+		s.force = true
+	case "go":
+		s.abandon()
+		s.force = false
+		s.engineWhite = s.game.Position().WhiteTurn
+		s.think()
+	case "usermove":
+		if len(args) == 1 {
+			s.userMove(args[0])
+		}
+	case "?":
+		// move now: stop the search but keep its move
+		if s.job != nil && !s.job.pondering {
+			s.job.cancel()
+		}
+	case "undo":
+		s.takeBack(1)
+	case "remove":
+		s.takeBack(2)
+	case "result":
+		s.abandon()
+		s.force = true
+	case "level":
+		s.level(args)
+	case "st":
+		if seconds, err := strconv.ParseFloat(firstArg(args), 64); err == nil {
+			s.moveTime = time.Duration(seconds * float64(time.Second))
+		}
+	case "sd":
+		s.depth, _ = strconv.Atoi(firstArg(args))
+	case "time":
+		s.engineClock = centiseconds(firstArg(args))
+	case "otim":
+		s.playerClock = centiseconds(firstArg(args))
+	case "post":
+		s.post = true
+	case "nopost":
+		s.post = false
+	case "hard":
+		s.ponder = true
+	case "easy":
+		s.ponder = false
+		if s.job != nil && s.job.pondering {
+			s.abandon()
+		}
+	case "memory":
+		// the table and the threads are only changed between searches
+		if mb, err := strconv.Atoi(firstArg(args)); err == nil {
+			s.abandon()
+			s.engine.SetHash(mb)
+		}
+	case "cores":
+		if cores, err := strconv.Atoi(firstArg(args)); err == nil {
+			s.abandon()
+			s.engine.SetThreads(cores)
+		}
+	case "quit":
+		s.abandon()
+		return false
+	default:
+		s.send("Error (unknown command): " + command)
+	}
+	return true
+}
+
+func (s *Server) send(line string) {
+	s.outMu.Lock()
+	defer s.outMu.Unlock()
+	io.WriteString(s.out, line+"\n")
+}
+
+func firstArg(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+	return args[0]
+}
+
+func centiseconds(text string) time.Duration {
+	n, _ := strconv.Atoi(text)
+	return time.Duration(n) * 10 * time.Millisecond
+}
+
+// reset starts a new game from pos with the engine playing Black, as after new.
+func (s *Server) reset(pos handlers.Position) {
+	s.game = game.NewFromPosition(pos)
+	s.force = false
+	s.engineWhite = false
+	s.engineClock, s.playerClock = s.base, s.base
+}
+
+func (s *Server) setBoard(fen string) {
+	s.abandon()
+	pos, err := handlers.ParseFEN(fen)
+	if err == nil {
+		err = pos.Validate()
+	}
+	if err != nil {
+		s.send("tellusererror Illegal position: " + err.Error())
+		return
+	}
+	force := s.force
+	s.reset(pos)
+	s.force = force
+}
+
+// userMove plays the opponent's move and, unless in force mode, answers it.
+func (s *Server) userMove(text string) {
+	if s.job != nil && s.job.pondering {
+		s.abandon()
+	}
+	m, err := s.game.Position().ParseUCI(text)
+	if err == nil {
+		err = s.game.Play(m)
+	}
+	if err != nil {
+		s.send(fmt.Sprintf("Illegal move (%v): %s", err, text))
+		return
+	}
+	if s.reportResult() {
+		return
+	}
+	if !s.force && s.game.Position().WhiteTurn == s.engineWhite {
+		s.think()
+	}
+}
+
+// takeBack undoes plies half-moves; the GUI sends it in force mode, or with the
+// engine not thinking.
+func (s *Server) takeBack(plies int) {
+	s.abandon()
+	if s.game.Ply() >= plies {
+		s.game.Truncate(s.game.Ply() - plies)
+	}
+}
+
+// level handles "level MPS BASE INC": moves per session (0 for the whole game), the
+// base time in minutes or minutes:seconds, and the increment in seconds.
+func (s *Server) level(args []string) {
+	if len(args) < 3 {
+		s.send("Error (level needs three values): level")
+		return
+	}
+	s.movesPerSession, _ = strconv.Atoi(args[0])
+	minutes, seconds, _ := strings.Cut(args[1], ":")
+	m, _ := strconv.Atoi(minutes)
+	sec, _ := strconv.Atoi(seconds)
+	s.base = time.Duration(m)*time.Minute + time.Duration(sec)*time.Second
+	inc, _ := strconv.ParseFloat(args[2], 64)
+	s.increment = time.Duration(inc * float64(time.Second))
+	s.moveTime = 0
+	s.engineClock, s.playerClock = s.base, s.base
+}
+
+// limits turns the time control and clocks into search limits for the engine's move.
+func (s *Server) limits() engine.Limits {
+	limits := engine.Limits{Depth: s.depth, MoveTime: s.moveTime}
+	if s.moveTime > 0 {
+		return limits
+	}
+	engineClock := max(s.engineClock, time.Millisecond)
+	playerClock := max(s.playerClock, time.Millisecond)
+	limits.WhiteTime, limits.BlackTime = engineClock, playerClock
+	if !s.engineWhite {
+		limits.WhiteTime, limits.BlackTime = playerClock, engineClock
+	}
+	limits.WhiteInc, limits.BlackInc = s.increment, s.increment
+	if s.movesPerSession > 0 {
+		played := s.game.Position().FullMoveNumber - s.game.StartPosition().FullMoveNumber
+		limits.MovesToGo = s.movesPerSession - played%s.movesPerSession
+	}
+	return limits
+}
+
+// think starts the search for the engine's move. When it ends the move is played
+// and sent, unless the search was abandoned in the meantime.
+func (s *Server) think() {
+	if s.game.Over() {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &thinking{cancel: cancel, done: make(chan struct{})}
+	s.job = job
+	pos := s.game.Position()
+	limits := s.limits()
+	post := s.post
+	start := time.Now()
+
+	go func() {
+		defer cancel()
+		move, ok := s.engine.Search(ctx, pos, limits, func(info engine.Info) {
+			if post && info.MultiPV == 1 {
+				s.send(thinkingLine(pos, info))
+			}
+		})
+		close(job.done)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.job != job || !ok {
+			return
+		}
+		s.job = nil
+		if err := s.game.Play(move); err != nil {
+			return
+		}
+		s.engineClock -= time.Since(start)
+		s.send("move " + move.String())
+		if !s.reportResult() && s.ponder {
+			s.startPondering()
+		}
+	}()
+}
+
+// startPondering searches the position while the opponent thinks. Nothing is played
+// from it; it fills the engine's table with the likely replies, which the search for
+// the next move then finds first.
+func (s *Server) startPondering() {
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &thinking{cancel: cancel, done: make(chan struct{}), pondering: true}
+	s.job = job
+	pos := s.game.Position()
+	go func() {
+		defer cancel()
+		s.engine.Search(ctx, pos, engine.Limits{}, nil)
+		close(job.done)
+	}()
+}
+
+// abandon stops the running search, if any, without playing its move, and waits
+// for the engine to be idle.
+func (s *Server) abandon() {
+	if s.job != nil {
+		s.job.cancel()
+		<-s.job.done
+		s.job = nil
+	}
+}
+
+// thinkingLine formats a search report as XBoard thinking output: depth, score in
+// centipawns, time in centiseconds, nodes and the principal variation. A mate in n
+// moves scores 100000+n, a loss -100000-n.
+func thinkingLine(pos handlers.Position, info engine.Info) string {
+	score := info.Score
+	switch {
+	case info.Mate > 0:
+		score = 100000 + info.Mate
+	case info.Mate < 0:
+		score = -100000 + info.Mate
+	}
+	return fmt.Sprintf("%d %d %d %d %s", info.Depth, score, info.Time.Milliseconds()/10, info.Nodes, pos.SANLine(info.PV))
+}
+
+// reportResult sends the result once the game is over and reports whether it is.
+func (s *Server) reportResult() bool {
+	if !s.game.Over() {
+		return false
+	}
+	comment := string(s.game.Reason())
+	if s.game.Reason() == game.Checkmate {
+		comment = "White mates"
+		if s.game.Result() == game.BlackWins {
+			comment = "Black mates"
+		}
+	}
+	s.send(fmt.Sprintf("%s {%s}", s.game.Result(), comment))
+	return true
+}
@@ -0,0 +1,164 @@
+package cecp
+
+import (
+	"chess-engine/engine"
+	"chess-engine/handlers"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// output collects what the server sends; the thinking goroutine writes to it too.
+type output struct {
+	mu sync.Mutex
+	sb strings.Builder
+}
+
+func (o *output) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.sb.Write(p)
+}
+
+func (o *output) lines() []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return strings.Split(strings.TrimSuffix(o.sb.String(), "\n"), "\n")
+}
+
+// run starts a server and hands it the commands, one per line.
+func run(t *testing.T, commands ...string) (*Server, *output) {
+	t.Helper()
+	out := &output{}
+	s := NewServer(engine.New(), out)
+	for _, command := range commands {
+		if !s.Handle(command) {
+			break
+		}
+	}
+	t.Cleanup(func() { s.Handle("quit") })
+	return s, out
+}
+
+func TestHandle(t *testing.T) {
+	tests := []struct {
+		name     string
+		commands []string
+		out      []string // "" for no output at all, a line ending in "..." matches by prefix
+		fen      string   // the position afterwards, "" to skip the check
+	}{
+		{"protover", []string{"xboard", "protover 2"}, []string{"feature " + strings.Join(features, " ")}, handlers.StartFEN},
+		{"ping", []string{"ping 7"}, []string{"pong 7"}, ""},
+		{"unknown command", []string{"castle"}, []string{"Error (unknown command): castle"}, ""},
+		{"ignored commands", []string{"accepted done", "random", "computer", "name Someone", "rating 2000 1800", "post", "hard"}, []string{""}, handlers.StartFEN},
+		{"moves in force mode", []string{"force", "usermove e2e4", "usermove c7c5"}, []string{""},
+			"rnbqkbnr/pp1ppppp/8/2p5/4P3/8/PPPP1PPP/RNBQKBNR w KQkq c6 0 2"},
+		{"illegal move", []string{"force", "usermove e2e5"}, []string{"Illegal move (e2e5 is not a legal move: the piece does not move that way): e2e5"}, handlers.StartFEN},
+		{"garbled move", []string{"force", "usermove hello"}, []string{"Illegal move (hello is not a legal move): hello"}, handlers.StartFEN},
+		{"undo", []string{"force", "usermove e2e4", "usermove e7e5", "undo"}, []string{""},
+			"rnbqkbnr/pppppppp/8/8/4P3/8/PPPP1PPP/RNBQKBNR b KQkq e3 0 1"},
+		{"remove", []string{"force", "usermove e2e4", "usermove e7e5", "remove"}, []string{""}, handlers.StartFEN},
+		{"undo at the start", []string{"force", "undo"}, []string{""}, handlers.StartFEN},
+		{"setboard", []string{"force", "setboard 4k3/8/8/8/8/8/4P3/4K3 w - - 0 1"}, []string{""}, "4k3/8/8/8/8/8/4P3/4K3 w - - 0 1"},
+		{"setboard with an impossible position", []string{"setboard 8/8/8/8/8/8/8/8 w - - 0 1"},
+			[]string{"tellusererror Illegal position: ..."}, handlers.StartFEN},
+		{"setboard with a broken FEN", []string{"setboard rubbish"}, []string{"tellusererror Illegal position: ..."}, handlers.StartFEN},
+		{"new", []string{"force", "usermove d2d4", "new"}, []string{""}, handlers.StartFEN},
+		{"mate", []string{"force", "usermove f2f3", "usermove e7e5", "usermove g2g4", "usermove d8h4"}, []string{"0-1 {Black mates}"}, ""},
+		{"memory and cores while thinking", []string{"go", "memory 8", "cores 2"}, []string{""}, handlers.StartFEN},
+		{"level without values", []string{"level 40"}, []string{"Error (level needs three values): level"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, out := run(t, tt.commands...)
+			got := out.lines()
+			if len(got) != len(tt.out) {
+				t.Fatalf("output %q, want %q", got, tt.out)
+			}
+			for i, want := range tt.out {
+				if prefix, ok := strings.CutSuffix(want, "..."); ok && strings.HasPrefix(got[i], prefix) {
+					continue
+				}
+				if got[i] != want {
+					t.Errorf("output line %d = %q, want %q", i, got[i], want)
+				}
+			}
+			if tt.fen != "" {
+				s.mu.Lock()
+				fen := s.game.Position().FEN()
+				s.mu.Unlock()
+				if fen != tt.fen {
+					t.Errorf("position %s, want %s", fen, tt.fen)
+				}
+			}
+		})
+	}
+}
+
+func TestLimits(t *testing.T) {
+	tests := []struct {
+		name     string
+		commands []string
+		want     engine.Limits
+	}{
+		{"default clock", nil, engine.Limits{WhiteTime: 5 * time.Minute, BlackTime: 5 * time.Minute}},
+		{"incremental", []string{"level 0 2 12", "time 9000", "otim 8000"},
+			engine.Limits{WhiteTime: 80 * time.Second, BlackTime: 90 * time.Second, WhiteInc: 12 * time.Second, BlackInc: 12 * time.Second}},
+		{"minutes and seconds", []string{"level 0 1:30 0.5"},
+			engine.Limits{WhiteTime: 90 * time.Second, BlackTime: 90 * time.Second, WhiteInc: 500 * time.Millisecond, BlackInc: 500 * time.Millisecond}},
+		{"moves per session", []string{"level 40 5 0", "force", "usermove e2e4", "usermove e7e5", "usermove g1f3"},
+			engine.Limits{WhiteTime: 5 * time.Minute, BlackTime: 5 * time.Minute, MovesToGo: 39}},
+		{"fixed time per move", []string{"level 40 5 0", "st 3"}, engine.Limits{MoveTime: 3 * time.Second}},
+		{"depth", []string{"sd 4", "st 1"}, engine.Limits{Depth: 4, MoveTime: time.Second}},
+		{"flag down", []string{"time 0", "otim -5"},
+			engine.Limits{WhiteTime: time.Millisecond, BlackTime: time.Millisecond}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := run(t, tt.commands...)
+			s.mu.Lock()
+			got := s.limits()
+			s.mu.Unlock()
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("limits = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestThinkingLine(t *testing.T) {
+	start := handlers.StartPosition()
+	e4, _ := start.ParseUCI("e2e4")
+	e5, _ := start.Play(e4).ParseUCI("e7e5")
+	tests := []struct {
+		info engine.Info
+		want string
+	}{
+		{engine.Info{Depth: 6, Score: 34, Time: 1234 * time.Millisecond, Nodes: 5000, PV: []handlers.Move{e4, e5}}, "6 34 123 5000 1. e4 e5"},
+		{engine.Info{Depth: 9, Mate: 3, Time: 50 * time.Millisecond, Nodes: 70, PV: []handlers.Move{e4}}, "9 100003 5 70 1. e4"},
+		{engine.Info{Depth: 9, Mate: -2, Nodes: 70, PV: []handlers.Move{e4}}, "9 -100002 0 70 1. e4"},
+	}
+	for _, tt := range tests {
+		if got := thinkingLine(start, tt.info); got != tt.want {
+			t.Errorf("thinkingLine(%+v) = %q, want %q", tt.info, got, tt.want)
+		}
+	}
+}
+
+func TestEngineMoves(t *testing.T) {
+	_, out := run(t, "new", "sd 2", "go")
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		lines := out.lines()
+		if move, ok := strings.CutPrefix(lines[len(lines)-1], "move "); ok {
+			if _, err := handlers.StartPosition().ParseUCI(move); err != nil {
+				t.Errorf("the engine played %s: %v", move, err)
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Errorf("no move after go, output %q", out.lines())
+}
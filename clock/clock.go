@@ -0,0 +1,161 @@
+// Package clock implements chess clocks: Fischer increment, Bronstein and simple
+// delay, hourglass timing and multi-stage time controls such as 40/90+30.
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tracks the remaining time of both players. It is safe for concurrent use,
+// so the GUI can poll it from a ticker while moves are pressed on the UI thread.
+type Clock struct {
+	mu        sync.Mutex
+	control   TimeControl
+	remaining [2]time.Duration // indexed by side, 0 is White
+	moves     [2]int           // moves completed in the current stage
+	stage     [2]int
+	white     bool // side whose clock is running
+	running   bool
+	turnStart time.Time
+
+	// now is the time source; tests replace it to drive the clock without waiting.
+	now func() time.Time
+}
+
+// New returns a stopped clock with both sides given the first stage's time.
+func New(control TimeControl) *Clock {
+	c := &Clock{control: control, white: true, now: time.Now}
+	if len(control.Stages) > 0 {
+		c.remaining[0] = control.Stages[0].Time
+		c.remaining[1] = control.Stages[0].Time
+	}
+	return c
+}
+
+func side(white bool) int {
+	if white {
+		return 0
+	}
+	return 1
+}
+
+// Control returns the time control the clock was created with.
+func (c *Clock) Control() TimeControl {
+	return c.control
+}
+
+// Start runs the clock of the given side.
+func (c *Clock) Start(white bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.white = white
+	c.running = true
+	c.turnStart = c.now()
+}
+
+// Stop freezes both clocks, e.g. when the game ends.
+func (c *Clock) Stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.running {
+		return
+	}
+	c.remaining[side(c.white)] -= c.charge(c.now().Sub(c.turnStart))
+	c.running = false
+}
+
+// Running reports whether one of the clocks is running.
+func (c *Clock) Running() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.running
+}
+
+// Press ends the turn of the side whose clock is running: its time is charged,
+// the increment or delay refund and any stage bonus are added, and the opponent's
+// clock starts.
+func (c *Clock) Press() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.running {
+		return
+	}
+
+	mover := side(c.white)
+	now := c.now()
+	elapsed := now.Sub(c.turnStart)
+	c.remaining[mover] -= c.charge(elapsed)
+
+	if c.remaining[mover] > 0 {
+		stage := c.control.stage(c.stage[mover])
+		switch c.control.Mode {
+		case Increment:
+			c.remaining[mover] += stage.Bonus
+		case Bronstein:
+			c.remaining[mover] += min(elapsed, stage.Bonus)
+		case Hourglass:
+			c.remaining[1-mover] += elapsed
+		}
+
+		c.moves[mover]++
+		if stage.Moves > 0 && c.moves[mover] == stage.Moves {
+			c.moves[mover] = 0
+			c.stage[mover]++
+			c.remaining[mover] += c.control.stage(c.stage[mover]).Time
+		}
+	}
+
+	c.white = !c.white
+	c.turnStart = now
+}
+
+// charge returns how much of elapsed is taken off the mover's clock.
+func (c *Clock) charge(elapsed time.Duration) time.Duration {
+	if c.control.Mode == SimpleDelay {
+		delay := c.control.stage(c.stage[side(c.white)]).Bonus
+		return max(elapsed-delay, 0)
+	}
+	return elapsed
+}
+
+// Remaining returns the time left for the given side, counting the turn in progress.
+func (c *Clock) Remaining(white bool) time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	remaining := c.remaining[side(white)]
+	if c.running && c.white == white {
+		remaining -= c.charge(c.now().Sub(c.turnStart))
+	}
+	return max(remaining, 0)
+}
+
+// Bonus returns the increment or delay the given side currently gets per move.
+func (c *Clock) Bonus(white bool) time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.control.Mode == Hourglass {
+		return 0
+	}
+	return c.control.stage(c.stage[side(white)]).Bonus
+}
+
+// MovesToGo returns how many moves the given side has left until the next stage, or 0 if the
+// current stage lasts for the rest of the game.
+func (c *Clock) MovesToGo(white bool) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stage := c.control.stage(c.stage[side(white)])
+	if stage.Moves == 0 {
+		return 0
+	}
+	return stage.Moves - c.moves[side(white)]
+}
+
+// Flagged reports whether the side whose clock is running has run out of time.
+func (c *Clock) Flagged() (white bool, flagged bool) {
+	c.mu.Lock()
+	white = c.white
+	c.mu.Unlock()
+	return white, c.Remaining(white) <= 0
+}
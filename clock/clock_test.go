@@ -0,0 +1,170 @@
+package clock
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fakeTime is a time source the tests move forward by hand.
+type fakeTime struct {
+	t time.Time
+}
+
+func (f *fakeTime) now() time.Time {
+	return f.t
+}
+
+func (f *fakeTime) advance(d time.Duration) {
+	f.t = f.t.Add(d)
+}
+
+// newTestClock returns a clock for control, driven by the returned time source.
+func newTestClock(t *testing.T, control string, mode Mode) (*Clock, *fakeTime) {
+	t.Helper()
+	tc, err := Parse(control, mode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ft := &fakeTime{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	c := New(tc)
+	c.now = ft.now
+	return c, ft
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		text   string
+		stages []Stage
+	}{
+		{"5+3", []Stage{{Time: 5 * time.Minute, Bonus: 3 * time.Second}}},
+		{"0.5", []Stage{{Time: 30 * time.Second}}},
+		{"40/90+30,30+30", []Stage{
+			{Moves: 40, Time: 90 * time.Minute, Bonus: 30 * time.Second},
+			{Time: 30 * time.Minute, Bonus: 30 * time.Second},
+		}},
+		{"40/120,20/60,30", []Stage{
+			{Moves: 40, Time: 120 * time.Minute},
+			{Moves: 20, Time: 60 * time.Minute},
+			{Time: 30 * time.Minute},
+		}},
+	}
+	for _, tt := range tests {
+		tc, err := Parse(tt.text, Increment)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.text, err)
+			continue
+		}
+		if !reflect.DeepEqual(tc.Stages, tt.stages) {
+			t.Errorf("Parse(%q) = %+v, want %+v", tt.text, tc.Stages, tt.stages)
+		}
+		if got := tc.String(); got != tt.text {
+			t.Errorf("Parse(%q).String() = %q", tt.text, got)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, text := range []string{"", "x", "0", "5+", "5+-1", "0/5", "40/90,", "-1+2"} {
+		if _, err := Parse(text, Increment); err == nil {
+			t.Errorf("Parse(%q) succeeded", text)
+		}
+	}
+}
+
+func TestModes(t *testing.T) {
+	tests := []struct {
+		name    string
+		control string
+		mode    Mode
+		thinks  []time.Duration // time used per move, White first
+		white   time.Duration
+		black   time.Duration
+	}{
+		{"increment", "1+2", Increment, []time.Duration{10 * time.Second, 5 * time.Second, 3 * time.Second}, 51 * time.Second, 57 * time.Second},
+		{"bronstein", "1+5", Bronstein, []time.Duration{10 * time.Second, 3 * time.Second}, 55 * time.Second, 60 * time.Second},
+		{"simple delay", "1+5", SimpleDelay, []time.Duration{10 * time.Second, 3 * time.Second}, 55 * time.Second, 60 * time.Second},
+		{"hourglass", "1", Hourglass, []time.Duration{10 * time.Second, 5 * time.Second}, 55 * time.Second, 65 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, ft := newTestClock(t, tt.control, tt.mode)
+			c.Start(true)
+			for _, d := range tt.thinks {
+				ft.advance(d)
+				c.Press()
+			}
+			if got := c.Remaining(true); got != tt.white {
+				t.Errorf("White has %v, want %v", got, tt.white)
+			}
+			if got := c.Remaining(false); got != tt.black {
+				t.Errorf("Black has %v, want %v", got, tt.black)
+			}
+		})
+	}
+}
+
+func TestStages(t *testing.T) {
+	c, ft := newTestClock(t, "2/1,1+10", Increment)
+	c.Start(true)
+	for i := 0; i < 3; i++ {
+		ft.advance(10 * time.Second)
+		c.Press()
+	}
+	// White made the two moves of the first stage and got the second stage's minute
+	if got, want := c.Remaining(true), 100*time.Second; got != want {
+		t.Errorf("White has %v, want %v", got, want)
+	}
+	if got := c.MovesToGo(true); got != 0 {
+		t.Errorf("White has %d moves to go in the last stage", got)
+	}
+	if got, want := c.Bonus(true), 10*time.Second; got != want {
+		t.Errorf("White's bonus is %v, want %v", got, want)
+	}
+	if got := c.MovesToGo(false); got != 1 {
+		t.Errorf("Black has %d moves to go, want 1", got)
+	}
+	if got := c.Bonus(false); got != 0 {
+		t.Errorf("Black's bonus is %v in the first stage", got)
+	}
+}
+
+func TestFlag(t *testing.T) {
+	c, ft := newTestClock(t, "1+5", Increment)
+	c.Start(true)
+	ft.advance(59 * time.Second)
+	if _, flagged := c.Flagged(); flagged {
+		t.Fatal("flagged with a second left")
+	}
+	ft.advance(2 * time.Second)
+	white, flagged := c.Flagged()
+	if !white || !flagged {
+		t.Fatalf("Flagged() = %v, %v, want White flagged", white, flagged)
+	}
+	if got := c.Remaining(true); got != 0 {
+		t.Errorf("White has %v after the flag fell", got)
+	}
+	// a move made after the flag fell earns no increment
+	c.Press()
+	if got := c.Remaining(true); got != 0 {
+		t.Errorf("White has %v after pressing a fallen flag", got)
+	}
+}
+
+func TestStop(t *testing.T) {
+	c, ft := newTestClock(t, "1", Increment)
+	c.Start(true)
+	ft.advance(10 * time.Second)
+	c.Stop()
+	ft.advance(time.Minute)
+	if c.Running() {
+		t.Error("the clock runs after Stop")
+	}
+	if got, want := c.Remaining(true), 50*time.Second; got != want {
+		t.Errorf("White has %v, want %v", got, want)
+	}
+	c.Press()
+	if got, want := c.Remaining(false), time.Minute; got != want {
+		t.Errorf("pressing a stopped clock charged Black: %v, want %v", got, want)
+	}
+}
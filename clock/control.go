@@ -0,0 +1,134 @@
+package clock
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Mode selects how the per-move bonus of a stage is applied.
+type Mode int
+
+const (
+	// Increment adds the bonus after every move (Fischer).
+	Increment Mode = iota
+	// Bronstein refunds the time used for a move, up to the bonus.
+	Bronstein
+	// SimpleDelay waits for the bonus to pass before the clock starts counting down.
+	SimpleDelay
+	// Hourglass moves the time one side uses onto the opponent's clock.
+	Hourglass
+)
+
+var modeNames = []string{"Fischer increment", "Bronstein delay", "Simple delay", "Hourglass"}
+
+func (m Mode) String() string {
+	if int(m) < len(modeNames) {
+		return modeNames[m]
+	}
+	return fmt.Sprintf("Mode(%d)", int(m))
+}
+
+// Modes returns every mode in display order.
+func Modes() []Mode {
+	return []Mode{Increment, Bronstein, SimpleDelay, Hourglass}
+}
+
+// Stage is one period of a time control.
+type Stage struct {
+	Moves int           // moves to make in this stage, 0 for the rest of the game
+	Time  time.Duration // added to the clock when the stage starts
+	Bonus time.Duration // increment or delay per move
+}
+
+// TimeControl is a sequence of stages. If the last stage has a move count it repeats.
+type TimeControl struct {
+	Mode   Mode
+	Stages []Stage
+}
+
+// stage returns stage i, repeating the last one once the list is exhausted.
+func (tc TimeControl) stage(i int) Stage {
+	if len(tc.Stages) == 0 {
+		return Stage{}
+	}
+	if i >= len(tc.Stages) {
+		return tc.Stages[len(tc.Stages)-1]
+	}
+	return tc.Stages[i]
+}
+
+// Parse reads a time control written as comma separated stages of the form
+// [moves/]minutes[+seconds], e.g. "5+3", "40/90+30,30+30" or "40/120,20/60,30".
+func Parse(s string, mode Mode) (TimeControl, error) {
+	tc := TimeControl{Mode: mode}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			return TimeControl{}, errors.New("empty time control stage")
+		}
+		var stage Stage
+
+		if moves, rest, ok := strings.Cut(part, "/"); ok {
+			n, err := strconv.Atoi(moves)
+			if err != nil || n <= 0 {
+				return TimeControl{}, fmt.Errorf("invalid move count %q", moves)
+			}
+			stage.Moves = n
+			part = rest
+		}
+
+		minutes, bonus, hasBonus := strings.Cut(part, "+")
+		m, err := strconv.ParseFloat(minutes, 64)
+		if err != nil || m < 0 {
+			return TimeControl{}, fmt.Errorf("invalid minutes %q", minutes)
+		}
+		stage.Time = time.Duration(m * float64(time.Minute))
+		if hasBonus {
+			b, err := strconv.ParseFloat(bonus, 64)
+			if err != nil || b < 0 {
+				return TimeControl{}, fmt.Errorf("invalid bonus seconds %q", bonus)
+			}
+			stage.Bonus = time.Duration(b * float64(time.Second))
+		}
+		tc.Stages = append(tc.Stages, stage)
+	}
+	if tc.Stages[0].Time <= 0 {
+		return TimeControl{}, errors.New("the first stage needs a base time")
+	}
+	return tc, nil
+}
+
+// String returns the control in the form accepted by Parse.
+func (tc TimeControl) String() string {
+	var parts []string
+	for _, stage := range tc.Stages {
+		part := strconv.FormatFloat(stage.Time.Minutes(), 'f', -1, 64)
+		if stage.Moves > 0 {
+			part = fmt.Sprintf("%d/%s", stage.Moves, part)
+		}
+		if stage.Bonus > 0 {
+			part += "+" + strconv.FormatFloat(stage.Bonus.Seconds(), 'f', -1, 64)
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, ",")
+}
+
+// PGN returns the control as a PGN TimeControl tag value ("40/5400+30:1800+30").
+func (tc TimeControl) PGN() string {
+	var parts []string
+	for _, stage := range tc.Stages {
+		part := strconv.Itoa(int(stage.Time.Seconds()))
+		if stage.Moves > 0 {
+			part = fmt.Sprintf("%d/%s", stage.Moves, part)
+		}
+		if stage.Bonus > 0 && tc.Mode != Hourglass {
+			part += "+" + strconv.Itoa(int(stage.Bonus.Seconds()))
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, ":")
+}
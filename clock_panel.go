@@ -0,0 +1,147 @@
+package main
+
+import (
+	"chess-engine/clock"
+	"fmt"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// untimedMoveTime is how long the AI thinks per move when the game has no clock.
+const untimedMoveTime = time.Second
+
+// timeControl is used for every new game; nil plays without clocks.
+var timeControl *clock.TimeControl
+
+// gameClock is the clock of the current game, nil when the game is untimed.
+var gameClock *clock.Clock
+
+var whiteClockText, blackClockText *canvas.Text
+
+func newClockText() *canvas.Text {
+	text := canvas.NewText("--:--", theme.Color(theme.ColorNameForeground))
+	text.TextSize = 24
+	text.TextStyle = fyne.TextStyle{Monospace: true, Bold: true}
+	return text
+}
+
+// newClockRow returns a "<side> <time> <captures>" row for one side's clock.
+func newClockRow(side string, text *canvas.Text, captures *fyne.Container) fyne.CanvasObject {
+	return container.NewHBox(widget.NewLabel(side), text, captures)
+}
+
+// formatClock shows minutes and seconds, and tenths once less than ten seconds are left.
+func formatClock(d time.Duration) string {
+	if d < 10*time.Second {
+		return fmt.Sprintf("0:%04.1f", d.Seconds())
+	}
+	d = d.Round(time.Second)
+	if d >= time.Hour {
+		return fmt.Sprintf("%d:%02d:%02d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
+	}
+	return fmt.Sprintf("%d:%02d", int(d.Minutes()), int(d.Seconds())%60)
+}
+
+// resetClock creates the clock for a new game and starts it for the side to move.
+// Games over the network are played without clocks.
+func resetClock() {
+	gameClock = nil
+	delete(currentGame.Tags, "TimeControl")
+	if timeControl != nil && lanSession == nil {
+		gameClock = clock.New(*timeControl)
+		gameClock.Start(currentGame.Position().WhiteTurn)
+		currentGame.Tags["TimeControl"] = timeControl.PGN()
+	}
+	refreshClocks()
+}
+
+func stopClock() {
+	if gameClock != nil {
+		gameClock.Stop()
+	}
+	refreshClocks()
+}
+
+func refreshClocks() {
+	if whiteClockText == nil {
+		return
+	}
+	c := gameClock
+	if c == nil {
+		whiteClockText.Text, blackClockText.Text = "--:--", "--:--"
+	} else {
+		whiteClockText.Text = formatClock(c.Remaining(true))
+		blackClockText.Text = formatClock(c.Remaining(false))
+	}
+	whiteClockText.Refresh()
+	blackClockText.Refresh()
+}
+
+// runClockTicker redraws the clocks; the controller ends the game when a flag falls.
+func runClockTicker() {
+	go func() {
+		ticker := time.NewTicker(100 * time.Millisecond)
+		defer ticker.Stop()
+		for range ticker.C {
+			fyne.Do(refreshClocks)
+		}
+	}()
+}
+
+// showTimeControlDialog lets the player pick the clock for the next game.
+func showTimeControlDialog() {
+	var modeNames []string
+	for _, mode := range clock.Modes() {
+		modeNames = append(modeNames, mode.String())
+	}
+	modeSelect := widget.NewSelect(modeNames, nil)
+	modeSelect.SetSelectedIndex(0)
+
+	controlEntry := widget.NewEntry()
+	controlEntry.SetPlaceHolder("5+3 or 40/90+30,30+30")
+	untimedCheck := widget.NewCheck("Untimed", func(checked bool) {
+		if checked {
+			modeSelect.Disable()
+			controlEntry.Disable()
+		} else {
+			modeSelect.Enable()
+			controlEntry.Enable()
+		}
+	})
+
+	if timeControl != nil {
+		modeSelect.SetSelectedIndex(int(timeControl.Mode))
+		controlEntry.SetText(timeControl.String())
+	} else {
+		controlEntry.SetText("5+3")
+		untimedCheck.SetChecked(true)
+	}
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("", untimedCheck),
+		widget.NewFormItem("Mode", modeSelect),
+		widget.NewFormItem("Time control", controlEntry),
+	}
+	dialog.ShowForm("Time Control", "New Game", "Cancel", items, func(confirmed bool) {
+		if !confirmed {
+			return
+		}
+		if untimedCheck.Checked {
+			timeControl = nil
+		} else {
+			tc, err := clock.Parse(controlEntry.Text, clock.Modes()[modeSelect.SelectedIndex()])
+			if err != nil {
+				dialog.ShowError(err, chessWindow)
+				return
+			}
+			timeControl = &tc
+		}
+		startNewGame()
+	}, chessWindow)
+}
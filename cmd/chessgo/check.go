@@ -0,0 +1,222 @@
+package main
+
+import (
+	"chess-engine/game"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+type perftResult struct {
+	FEN    string           `json:"fen"`
+	Depth  int              `json:"depth"`
+	Nodes  int64            `json:"nodes"`
+	TimeMS int64            `json:"time_ms"`
+	NPS    int64            `json:"nps"`
+	Divide map[string]int64 `json:"divide,omitempty"`
+}
+
+// runPerft counts the positions of the legal move tree, to check the move generator.
+func runPerft(args []string) error {
+	fs, jsonOut := newFlags("perft [--fen FEN] [--divide] DEPTH")
+	fen := fs.String("fen", "startpos", "position to count from")
+	divide := fs.Bool("divide", false, "count each first move separately")
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	var depth int
+	if len(args) != 1 {
+		return badUsage(fs, "perft takes one depth")
+	}
+	if _, err := fmt.Sscan(args[0], &depth); err != nil || depth < 1 {
+		return badUsage(fs, "invalid depth %q", args[0])
+	}
+	pos, err := parsePosition(*fen)
+	if err != nil {
+		return err
+	}
+
+	result := perftResult{FEN: pos.FEN(), Depth: depth}
+	start := time.Now()
+	if *divide {
+		result.Divide = map[string]int64{}
+		for m, nodes := range pos.Divide(depth) {
+			result.Divide[m.String()] = nodes
+			result.Nodes += nodes
+		}
+	} else {
+		result.Nodes = pos.Perft(depth)
+	}
+	elapsed := time.Since(start)
+	result.TimeMS = elapsed.Milliseconds()
+	if elapsed > 0 {
+		result.NPS = int64(float64(result.Nodes) / elapsed.Seconds())
+	}
+
+	if *jsonOut {
+		return printJSON(result)
+	}
+	moves := make([]string, 0, len(result.Divide))
+	for move := range result.Divide {
+		moves = append(moves, move)
+	}
+	sort.Strings(moves)
+	for _, move := range moves {
+		fmt.Printf("%s: %d\n", move, result.Divide[move])
+	}
+	if len(moves) > 0 {
+		fmt.Println()
+	}
+	fmt.Printf("Nodes: %d\nTime: %s\nNPS: %d\n", result.Nodes, elapsed.Round(time.Millisecond), result.NPS)
+	return nil
+}
+
+type fenResult struct {
+	FEN   string `json:"fen"`
+	Valid bool   `json:"valid"`
+	Error string `json:"error,omitempty"`
+}
+
+// runFEN checks that a FEN describes a position that can be played.
+func runFEN(args []string) error {
+	fs, jsonOut := newFlags("fen validate FEN")
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) < 1 || args[0] != "validate" {
+		return badUsage(fs, "unknown fen command")
+	}
+	if len(args) < 2 {
+		return badUsage(fs, "fen validate takes a FEN")
+	}
+	// an unquoted FEN arrives as its six fields
+	fen := strings.Join(args[1:], " ")
+
+	result := fenResult{FEN: fen, Valid: true}
+	if _, err := parsePosition(fen); err != nil {
+		result.Valid, result.Error = false, err.Error()
+	}
+	if *jsonOut {
+		if err := printJSON(result); err != nil {
+			return err
+		}
+	} else if result.Valid {
+		fmt.Println("valid")
+	} else {
+		fmt.Println("invalid:", result.Error)
+	}
+	if !result.Valid {
+		return errInvalid
+	}
+	return nil
+}
+
+type pgnResult struct {
+	File   string `json:"file"`
+	Game   int    `json:"game"`
+	Valid  bool   `json:"valid"`
+	White  string `json:"white,omitempty"`
+	Black  string `json:"black,omitempty"`
+	Plies  int    `json:"plies"`
+	Result string `json:"result,omitempty"`
+	Reason string `json:"reason,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// runPGN replays every game of the PGN files, "-" for standard input, and reports
+// the ones that do not parse or contain an illegal move.
+func runPGN(args []string) error {
+	fs, jsonOut := newFlags("pgn check FILE...")
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) < 1 || args[0] != "check" {
+		return badUsage(fs, "unknown pgn command")
+	}
+	if len(args) < 2 {
+		return badUsage(fs, "pgn check takes one or more files")
+	}
+
+	var results []pgnResult
+	for _, name := range args[1:] {
+		var data []byte
+		if name == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(name)
+		}
+		if err != nil {
+			return err
+		}
+		for i, text := range splitPGN(string(data)) {
+			result := pgnResult{File: name, Game: i + 1, Valid: true}
+			g, err := game.ParsePGN(text)
+			if g != nil {
+				// for an invalid game, the half-moves played before the error
+				result.White, result.Black, result.Plies = g.Tags["White"], g.Tags["Black"], g.Ply()
+			}
+			if err != nil {
+				result.Valid, result.Error = false, err.Error()
+			} else {
+				result.Result, result.Reason = string(g.Result()), string(g.Reason())
+			}
+			results = append(results, result)
+		}
+	}
+
+	invalid := 0
+	for _, result := range results {
+		if !result.Valid {
+			invalid++
+		}
+	}
+	if *jsonOut {
+		if err := printJSON(results); err != nil {
+			return err
+		}
+	} else {
+		for _, r := range results {
+			if r.Valid {
+				fmt.Printf("%s: game %d: ok, %s - %s, %d half-moves, %s\n", r.File, r.Game, r.White, r.Black, r.Plies, r.Result)
+			} else {
+				fmt.Printf("%s: game %d: %s\n", r.File, r.Game, r.Error)
+			}
+		}
+		fmt.Printf("%d games, %d invalid\n", len(results), invalid)
+	}
+	if invalid > 0 {
+		return errInvalid
+	}
+	return nil
+}
+
+// splitPGN splits a PGN file into its games: a tag line after movetext starts the next one.
+func splitPGN(text string) []string {
+	var games []string
+	var current strings.Builder
+	inMovetext := false
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		isTag := strings.HasPrefix(trimmed, "[")
+		if isTag && inMovetext {
+			games = append(games, current.String())
+			current.Reset()
+			inMovetext = false
+		}
+		if trimmed != "" && !isTag {
+			inMovetext = true
+		}
+		current.WriteString(line)
+		current.WriteByte('\n')
+	}
+	if strings.TrimSpace(current.String()) != "" {
+		games = append(games, current.String())
+	}
+	return games
+}
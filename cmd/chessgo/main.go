@@ -0,0 +1,152 @@
+// Command chessgo is the chess library on the command line, without a display:
+//
+//	chessgo play [--fen FEN] [--color white|black|random] [--movetime D]
+//	chessgo tui [--fen FEN] [--white human|engine] [--black human|engine] [--tc TC] [--eval] [--plain]
+//	chessgo perft [--fen FEN] [--divide] DEPTH
+//	chessgo fen validate FEN
+//	chessgo pgn check FILE...
+//	chessgo analyze FEN [--depth N] [--movetime D] [--multipv N]
+//	chessgo bestmove [FEN] [--depth N] [--movetime D]
+//
+// Every command but tui prints plain text, or JSON with --json. A FEN argument may be
+// "startpos" for the initial position. The exit status is 1 when a command fails
+// or finds something invalid, and 2 on a usage error.
+//
+// Log messages go to standard error, warnings only unless CHESSGO_LOG sets the
+// levels, e.g. CHESSGO_LOG=debug. CHESSGO_TRACE=FILE writes why moves were rejected
+// and what the engine searched to FILE as JSON lines.
+package main
+
+import (
+	"chess-engine/handlers"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// errInvalid is returned by a command that ran but found its input invalid; the
+// details have been printed already.
+var errInvalid = errors.New("invalid")
+
+// errUsage is returned for bad arguments; the usage has been printed already.
+var errUsage = errors.New("usage")
+
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) error
+}
+
+var commands = []command{
+	{"play", "play [--fen FEN] [--color white|black|random] [--movetime D]", runPlay},
+	{"tui", "tui [--fen FEN] [--white human|engine] [--black human|engine] [--tc TC] [--eval] [--plain]", runTUI},
+	{"perft", "perft [--fen FEN] [--divide] DEPTH", runPerft},
+	{"fen", "fen validate FEN", runFEN},
+	{"pgn", "pgn check FILE...", runPGN},
+	{"analyze", "analyze FEN [--depth N] [--movetime D] [--multipv N]", runAnalyze},
+	{"bestmove", "bestmove [FEN] [--depth N] [--movetime D]", runBestMove},
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: chessgo COMMAND [ARGS] [--json]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	for _, c := range commands {
+		fmt.Fprintln(w, "  chessgo "+c.usage)
+	}
+}
+
+func main() {
+	if len(os.Args) < 2 || os.Args[1] == "help" || os.Args[1] == "-h" || os.Args[1] == "--help" {
+		usage(os.Stdout)
+		if len(os.Args) < 2 {
+			os.Exit(2)
+		}
+		return
+	}
+	for _, c := range commands {
+		if c.name != os.Args[1] {
+			continue
+		}
+		err := c.run(os.Args[2:])
+		switch {
+		case err == nil:
+		case errors.Is(err, errUsage):
+			os.Exit(2)
+		case errors.Is(err, errInvalid):
+			os.Exit(1)
+		default:
+			fmt.Fprintln(os.Stderr, "chessgo:", err)
+			os.Exit(1)
+		}
+		return
+	}
+	fmt.Fprintf(os.Stderr, "chessgo: unknown command %q\n\n", os.Args[1])
+	usage(os.Stderr)
+	os.Exit(2)
+}
+
+// newFlags returns the flag set of a command, with the --json flag every command has.
+func newFlags(usage string) (*flag.FlagSet, *bool) {
+	fs := flag.NewFlagSet(usage, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: chessgo "+usage+" [--json]")
+		fs.PrintDefaults()
+	}
+	jsonOut := fs.Bool("json", false, "print JSON instead of text")
+	return fs, jsonOut
+}
+
+// parseArgs parses flags given before, between or after the positional arguments,
+// so "analyze FEN --depth 8" works as well as "analyze --depth 8 FEN".
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, errUsage
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// badUsage prints the usage of a command after a mistake in its arguments.
+func badUsage(fs *flag.FlagSet, format string, a ...any) error {
+	fmt.Fprintf(fs.Output(), "chessgo: "+format+"\n", a...)
+	fs.Usage()
+	return errUsage
+}
+
+// parsePosition reads a FEN, or "startpos", and checks the position can be played.
+func parsePosition(fen string) (handlers.Position, error) {
+	fen = strings.TrimSpace(fen)
+	if fen == "" || fen == "startpos" {
+		return handlers.StartPosition(), nil
+	}
+	pos, err := handlers.ParseFEN(fen)
+	if err != nil {
+		return pos, err
+	}
+	return pos, pos.Validate()
+}
+
+func printJSON(v any) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+func colorName(white bool) string {
+	if white {
+		return "white"
+	}
+	return "black"
+}
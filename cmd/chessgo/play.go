@@ -0,0 +1,254 @@
+package main
+
+import (
+	"bufio"
+	"chess-engine/engine"
+	"chess-engine/game"
+	"chess-engine/handlers"
+	"chess-engine/player"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+	"sync"
+)
+
+// terminalGame is a game against the engine in the terminal.
+type terminalGame struct {
+	game       *game.Game
+	human      *player.Human
+	humanWhite bool
+	jsonOut    bool
+	yourTurn   chan struct{} // signalled when the engine has moved
+
+	mu sync.Mutex // serializes the output
+}
+
+// event is a line of the JSON output of play.
+type event struct {
+	Type   string `json:"type"` // "start", "move", "error" or "end"
+	Side   string `json:"side,omitempty"`
+	Move   string `json:"move,omitempty"`
+	SAN    string `json:"san,omitempty"`
+	FEN    string `json:"fen,omitempty"`
+	Result string `json:"result,omitempty"`
+	Reason string `json:"reason,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// runPlay plays a game against the built-in engine, reading the player's moves in
+// SAN or UCI notation from standard input.
+func runPlay(args []string) error {
+	fs, jsonOut := newFlags("play [--fen FEN] [--color white|black|random] [--movetime D]")
+	fen := fs.String("fen", "startpos", "position to start from")
+	color := fs.String("color", "white", "side to play: white, black or random")
+	moveTime := fs.Duration("movetime", player.DefaultMoveTime, "engine thinking time per move")
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) > 0 {
+		return badUsage(fs, "unexpected argument %q", args[0])
+	}
+	var humanWhite bool
+	switch *color {
+	case "white", "black":
+		humanWhite = *color == "white"
+	case "random":
+		humanWhite = rand.Intn(2) == 0
+	default:
+		return badUsage(fs, "invalid color %q", *color)
+	}
+	start, err := parsePosition(*fen)
+	if err != nil {
+		return err
+	}
+
+	t := &terminalGame{
+		game:       game.NewFromPosition(start),
+		human:      player.NewHuman("Player"),
+		humanWhite: humanWhite,
+		jsonOut:    *jsonOut,
+		yourTurn:   make(chan struct{}, 1),
+	}
+	computer := player.NewEngine("chessgo", engine.New())
+	white, black := player.Player(t.human), player.Player(computer)
+	if !humanWhite {
+		white, black = black, white
+	}
+	t.game.Tags["White"], t.game.Tags["Black"] = white.Name(), black.Name()
+
+	ended := make(chan struct{})
+	c := player.NewController(t.game, white, black, nil)
+	c.UntimedMoveTime = *moveTime
+	c.OnMove = t.moved
+	c.OnEnd = func() { close(ended) }
+	c.OnError = func(white bool, err error) {
+		t.print(event{Type: "error", Side: colorName(white), Error: err.Error()}, err.Error())
+		close(ended)
+	}
+
+	t.print(event{Type: "start", Side: colorName(humanWhite), FEN: start.FEN()}, fmt.Sprintf("You play %s. Enter moves like e4 or e2e4, or resign or quit.", colorName(humanWhite)))
+	t.showBoard()
+	c.Start()
+	if start.WhiteTurn == humanWhite {
+		t.prompt()
+	}
+
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+	for {
+		select {
+		case <-ended:
+			t.end()
+			return nil
+		case text, ok := <-lines:
+			text = strings.TrimSpace(text)
+			switch {
+			case !ok, text == "quit", text == "exit":
+				c.Stop()
+				t.end()
+				return nil
+			case text == "resign":
+				c.Stop()
+				t.game.Resign(t.humanWhite)
+				t.end()
+				return nil
+			}
+			if text != "" && text != "board" && !t.human.Waiting() {
+				// a move typed ahead, or piped in, waits for the engine's reply
+				select {
+				case <-t.yourTurn:
+				case <-ended:
+					t.end()
+					return nil
+				}
+			}
+			t.command(text)
+		}
+	}
+}
+
+// command carries out a line of input other than quit and resign: a move, or
+// board to show the board again.
+func (t *terminalGame) command(text string) {
+	switch text {
+	case "":
+		return
+	case "board":
+		t.showBoard()
+		if t.human.Waiting() {
+			t.prompt()
+		}
+		return
+	}
+
+	pos := t.game.Position()
+	m, err := pos.ParseMove(text)
+	if err == nil {
+		select {
+		case <-t.yourTurn:
+		default:
+		}
+		err = t.human.Submit(m)
+	}
+	if err != nil {
+		t.print(event{Type: "error", Move: text, Error: err.Error()}, fmt.Sprintf("Illegal move %s: %v", text, err))
+		t.prompt()
+	}
+}
+
+// moved shows a move either side played.
+func (t *terminalGame) moved(m handlers.Move) {
+	before := t.game.PositionAt(t.game.Ply() - 1)
+	after := t.game.Position()
+	mover := "You play"
+	if before.WhiteTurn != t.humanWhite {
+		mover = "chessgo plays"
+	}
+	t.print(event{Type: "move", Side: colorName(before.WhiteTurn), Move: m.String(), SAN: before.SAN(m), FEN: after.FEN()},
+		fmt.Sprintf("%s %s", mover, before.SAN(m)))
+	if before.WhiteTurn != t.humanWhite {
+		t.showBoard()
+		if !t.game.Over() {
+			t.prompt()
+			select {
+			case t.yourTurn <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+func (t *terminalGame) end() {
+	if !t.game.Over() {
+		return
+	}
+	t.print(event{Type: "end", Result: string(t.game.Result()), Reason: string(t.game.Reason())},
+		fmt.Sprintf("Game over: %s (%s)\n\n%s", t.game.Result(), t.game.Reason(), t.game.PGN()))
+}
+
+// print writes an event as a JSON line, or its text.
+func (t *terminalGame) print(e event, text string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.jsonOut {
+		data, _ := json.Marshal(e)
+		fmt.Println(string(data))
+		return
+	}
+	fmt.Println(text)
+}
+
+func (t *terminalGame) prompt() {
+	if !t.jsonOut {
+		fmt.Print("Your move: ")
+	}
+}
+
+// showBoard prints the position as text with the player's side at the bottom.
+func (t *terminalGame) showBoard() {
+	if t.jsonOut {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	fmt.Print(boardText(t.game.Position(), !t.humanWhite))
+}
+
+func boardText(pos handlers.Position, flipped bool) string {
+	var b strings.Builder
+	files := "  a b c d e f g h\n"
+	if flipped {
+		files = "  h g f e d c b a\n"
+	}
+	b.WriteString(files)
+	for i := 0; i < 8; i++ {
+		row := i
+		if flipped {
+			row = 7 - i
+		}
+		fmt.Fprintf(&b, "%d ", 8-row)
+		for j := 0; j < 8; j++ {
+			col := j
+			if flipped {
+				col = 7 - j
+			}
+			piece := pos.Board[row][col]
+			if piece == 0 {
+				piece = '.'
+			}
+			fmt.Fprintf(&b, "%c ", piece)
+		}
+		fmt.Fprintf(&b, "%d\n", 8-row)
+	}
+	b.WriteString(files)
+	return b.String()
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"chess-engine/engine"
+	"chess-engine/handlers"
+	"chess-engine/player"
+	"context"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// line is an engine line as printed. Scores are from White's point of view.
+type line struct {
+	MultiPV int      `json:"multipv"`
+	Depth   int      `json:"depth"`
+	Score   int      `json:"score_cp"`
+	Mate    int      `json:"mate,omitempty"`
+	Nodes   int64    `json:"nodes"`
+	TimeMS  int64    `json:"time_ms"`
+	PV      []string `json:"pv"`
+	PVSAN   string   `json:"pv_san"`
+}
+
+func newLine(pos handlers.Position, info engine.Info) line {
+	l := line{
+		MultiPV: max(info.MultiPV, 1),
+		Depth:   info.Depth,
+		Score:   info.Score,
+		Mate:    info.Mate,
+		Nodes:   info.Nodes,
+		TimeMS:  info.Time.Milliseconds(),
+		PV:      []string{},
+		PVSAN:   pos.SANLine(info.PV),
+	}
+	if l.Mate != 0 {
+		l.Score = 0
+	}
+	if !pos.WhiteTurn {
+		l.Score, l.Mate = -l.Score, -l.Mate
+	}
+	for _, m := range info.PV {
+		l.PV = append(l.PV, m.String())
+	}
+	return l
+}
+
+func (l line) score() string {
+	if l.Mate != 0 {
+		return "#" + strconv.Itoa(l.Mate)
+	}
+	return fmt.Sprintf("%+.2f", float64(l.Score)/100)
+}
+
+func (l line) String() string {
+	return fmt.Sprintf("depth %d  multipv %d  score %s  nodes %d  time %dms  pv %s", l.Depth, l.MultiPV, l.score(), l.Nodes, l.TimeMS, l.PVSAN)
+}
+
+// searchFlags adds the flags that bound a search.
+func searchFlags(fs *flag.FlagSet) (depth *int, moveTime *time.Duration) {
+	depth = fs.Int("depth", 0, "search this many half-moves deep")
+	moveTime = fs.Duration("movetime", 0, "search this long (default 1s when no depth is given)")
+	return depth, moveTime
+}
+
+func searchLimits(depth int, moveTime time.Duration) engine.Limits {
+	if depth <= 0 && moveTime <= 0 {
+		moveTime = player.DefaultMoveTime
+	}
+	return engine.Limits{Depth: depth, MoveTime: moveTime}
+}
+
+type analysis struct {
+	FEN      string `json:"fen"`
+	BestMove string `json:"bestmove,omitempty"`
+	BestSAN  string `json:"bestmove_san,omitempty"`
+	Lines    []line `json:"lines"`
+}
+
+// runAnalyze searches a position and prints the engine's lines as they deepen.
+func runAnalyze(args []string) error {
+	fs, jsonOut := newFlags("analyze FEN [--depth N] [--movetime D] [--multipv N]")
+	depth, moveTime := searchFlags(fs)
+	multiPV := fs.Int("multipv", 1, "number of best lines to show")
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return badUsage(fs, "analyze takes a FEN")
+	}
+	pos, err := parsePosition(strings.Join(args, " "))
+	if err != nil {
+		return err
+	}
+
+	limits := searchLimits(*depth, *moveTime)
+	limits.MultiPV = max(*multiPV, 1)
+	result := analysis{FEN: pos.FEN(), Lines: []line{}}
+	last := map[int]line{}
+	move, ok := engine.New().Search(context.Background(), pos, limits, func(info engine.Info) {
+		l := newLine(pos, info)
+		last[l.MultiPV] = l
+		if !*jsonOut {
+			fmt.Println(l)
+		}
+	})
+	for i := 1; i <= limits.MultiPV; i++ {
+		if l, found := last[i]; found {
+			result.Lines = append(result.Lines, l)
+		}
+	}
+	if ok {
+		result.BestMove, result.BestSAN = move.String(), pos.SAN(move)
+	}
+
+	if *jsonOut {
+		return printJSON(result)
+	}
+	if ok {
+		fmt.Printf("bestmove %s (%s)\n", result.BestMove, result.BestSAN)
+	} else {
+		fmt.Println("no legal moves")
+	}
+	return nil
+}
+
+type bestMove struct {
+	FEN   string `json:"fen"`
+	Move  string `json:"move,omitempty"`
+	SAN   string `json:"san,omitempty"`
+	Score int    `json:"score_cp"`
+	Mate  int    `json:"mate,omitempty"`
+	Depth int    `json:"depth"`
+}
+
+// runBestMove prints the move the engine would play.
+func runBestMove(args []string) error {
+	fs, jsonOut := newFlags("bestmove [FEN] [--depth N] [--movetime D]")
+	depth, moveTime := searchFlags(fs)
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	pos, err := parsePosition(strings.Join(args, " "))
+	if err != nil {
+		return err
+	}
+
+	result := bestMove{FEN: pos.FEN()}
+	move, ok := engine.New().Search(context.Background(), pos, searchLimits(*depth, *moveTime), func(info engine.Info) {
+		if info.MultiPV <= 1 {
+			l := newLine(pos, info)
+			result.Score, result.Mate, result.Depth = l.Score, l.Mate, l.Depth
+		}
+	})
+	if ok {
+		result.Move, result.SAN = move.String(), pos.SAN(move)
+	}
+
+	if *jsonOut {
+		if err := printJSON(result); err != nil {
+			return err
+		}
+	} else if ok {
+		fmt.Printf("%s (%s)\n", result.Move, result.SAN)
+	}
+	if !ok {
+		if !*jsonOut {
+			fmt.Println("no legal moves")
+		}
+		return errInvalid
+	}
+	return nil
+}
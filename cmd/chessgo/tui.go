@@ -0,0 +1,58 @@
+package main
+
+import (
+	"chess-engine/clock"
+	"chess-engine/player"
+	"chess-engine/tui"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// runTUI plays in the terminal with a Unicode board, clocks and the evaluation.
+func runTUI(args []string) error {
+	usage := "tui [--fen FEN] [--white human|engine] [--black human|engine] [--tc TC] [--eval] [--plain]"
+	fs := flag.NewFlagSet(usage, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: chessgo "+usage)
+		fs.PrintDefaults()
+	}
+	fen := fs.String("fen", "startpos", "position to start from")
+	white := fs.String("white", "human", "who plays White: human or engine")
+	black := fs.String("black", "engine", "who plays Black: human or engine")
+	control := fs.String("tc", "", "time control, e.g. 5+3 or 40/90+30,30+30; untimed if empty")
+	moveTime := fs.Duration("movetime", player.DefaultMoveTime, "engine thinking time per move in an untimed game")
+	eval := fs.Bool("eval", false, "show the engine's evaluation")
+	plain := fs.Bool("plain", false, "draw without colors")
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) > 0 {
+		return badUsage(fs, "unexpected argument %q", args[0])
+	}
+
+	opts := tui.Options{MoveTime: *moveTime, Eval: *eval, Plain: *plain}
+	for _, side := range []struct {
+		kind     string
+		isEngine *bool
+	}{{*white, &opts.WhiteEngine}, {*black, &opts.BlackEngine}} {
+		switch side.kind {
+		case "human", "engine":
+			*side.isEngine = side.kind == "engine"
+		default:
+			return badUsage(fs, "invalid player %q, want human or engine", side.kind)
+		}
+	}
+	if *control != "" {
+		tc, err := clock.Parse(*control, clock.Increment)
+		if err != nil {
+			return err
+		}
+		opts.Control = &tc
+	}
+	if opts.Start, err = parsePosition(*fen); err != nil {
+		return err
+	}
+	return tui.Run(os.Stdin, os.Stdout, opts)
+}
@@ -0,0 +1,39 @@
+// Command server serves games over HTTP: the JSON REST API of package api, and the
+// live WebSocket games of package live under /live/. It does not need a display.
+package main
+
+import (
+	"chess-engine/api"
+	"chess-engine/engine"
+	"chess-engine/live"
+	"chess-engine/logging"
+	"flag"
+	"net/http"
+	"os"
+	"time"
+)
+
+func main() {
+	addr := flag.String("addr", ":8080", "address to listen on")
+	hash := flag.Int("hash", engine.DefaultHash, "engine hash table size in megabytes")
+	flag.Parse()
+	// a server reports what it is doing unless CHESSGO_LOG asks otherwise
+	if os.Getenv("CHESSGO_LOG") == "" {
+		logging.Configure("info", os.Stderr)
+	}
+	logger := logging.For(logging.Server)
+
+	e := engine.New()
+	e.SetHash(*hash)
+	mux := http.NewServeMux()
+	mux.Handle("/", api.NewServer(e))
+	mux.Handle("/live/", http.StripPrefix("/live", live.NewServer()))
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	logger.Info("serving the chess API", "addr", *addr)
+	logger.Error("server stopped", "err", server.ListenAndServe())
+	os.Exit(1)
+}
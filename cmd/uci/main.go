@@ -0,0 +1,17 @@
+// Command uci runs the engine as a UCI engine on standard input and output, for
+// chess GUIs and testing tools. It does not need a display.
+package main
+
+import (
+	"chess-engine/engine"
+	"chess-engine/uci"
+	"fmt"
+	"os"
+)
+
+func main() {
+	if err := uci.NewServer(engine.New(), os.Stdout).Run(os.Stdin); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,18 @@
+// Command xboard runs the engine with the Chess Engine Communication Protocol on
+// standard input and output, for XBoard, WinBoard and other CECP tools. It does not
+// need a display.
+package main
+
+import (
+	"chess-engine/cecp"
+	"chess-engine/engine"
+	"fmt"
+	"os"
+)
+
+func main() {
+	if err := cecp.NewServer(engine.New(), os.Stdout).Run(os.Stdin); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"chess-engine/handlers"
+	"image/color"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// editing is true while the board editor is open; the game is paused meanwhile.
+var editing bool
+
+// editPosition is the position being set up in the board editor.
+var editPosition handlers.Position
+
+// editPiece is the palette piece a tap puts on the board, 0 for the eraser.
+var editPiece rune = 'P'
+
+const paletteOrder = "KQRBNPkqrbnp"
+
+const (
+	sideWhite = "White"
+	sideBlack = "Black"
+)
+
+// openEditor pauses the game and opens the board editor on the shown position.
+func openEditor() {
+	if editing {
+		return
+	}
+	stopPlay()
+	if gameClock != nil {
+		gameClock.Stop()
+	}
+	refreshClocks()
+
+	editPosition = shownPosition()
+	editing = true
+	pieceSelected = false
+	sidePanel.Objects = []fyne.CanvasObject{newEditorPanel()}
+	sidePanel.Refresh()
+	redrawBoard()
+}
+
+// closeEditor puts the score sheet back next to the board and shows the game again.
+func closeEditor() {
+	if !editing {
+		return
+	}
+	editing = false
+	if sidePanel != nil {
+		sidePanel.Objects = []fyne.CanvasObject{historyPanel}
+		sidePanel.Refresh()
+	}
+	redrawBoard()
+}
+
+// cancelEditing leaves the editor without changes and resumes the paused game.
+func cancelEditing() {
+	closeEditor()
+	if currentGame.Over() {
+		return
+	}
+	whiteTurn := currentGame.Position().WhiteTurn
+	if gameClock != nil {
+		gameClock.Start(whiteTurn)
+	}
+	resumePlay()
+}
+
+// editSquare puts the palette piece on a square, or empties it when the square
+// already holds that piece.
+func editSquare(row, col int) {
+	if editPosition.Board[row][col] == editPiece {
+		editPosition.Board[row][col] = 0
+	} else {
+		editPosition.Board[row][col] = editPiece
+	}
+	redrawBoard()
+}
+
+// playEditedPosition starts a new game from the edited position once it passes validation.
+func playEditedPosition() {
+	pos := editPosition
+	pos.HalfMoveClock = 0
+	if pos.FullMoveNumber < 1 {
+		pos.FullMoveNumber = 1
+	}
+	if err := pos.Validate(); err != nil {
+		dialog.ShowError(err, chessWindow)
+		return
+	}
+	startGame(newGameFrom(pos))
+}
+
+// newEditorPanel builds the editor controls: the piece palette, clear and reset, the
+// side to move, the castling rights and the en passant file.
+func newEditorPanel() fyne.CanvasObject {
+	var paletteButtons []*widget.Button
+	selectPiece := func(piece rune) {
+		editPiece = piece
+		for i, button := range paletteButtons {
+			button.Importance = widget.LowImportance
+			if (i < len(paletteOrder) && rune(paletteOrder[i]) == piece) || (i == len(paletteOrder) && piece == 0) {
+				button.Importance = widget.HighImportance
+			}
+			button.Refresh()
+		}
+	}
+	// the piece images are drawn over plain buttons, button icons would be too small
+	palette := container.NewGridWrap(fyne.NewSize(40, 40))
+	for _, piece := range paletteOrder + "x" {
+		button := widget.NewButton("", func() {
+			if piece == 'x' {
+				selectPiece(0)
+				return
+			}
+			selectPiece(piece)
+		})
+		paletteButtons = append(paletteButtons, button)
+		if piece == 'x' {
+			button.SetIcon(theme.ContentClearIcon())
+			palette.Add(button)
+			continue
+		}
+		image := canvas.NewImageFromResource(pieceResource(piece))
+		image.FillMode = canvas.ImageFillContain
+		palette.Add(container.NewStack(button, container.NewPadded(image)))
+	}
+	selectPiece(editPiece)
+
+	sideToMove := widget.NewRadioGroup([]string{sideWhite, sideBlack}, nil)
+	sideToMove.Horizontal = true
+	sideToMove.Required = true
+
+	castlingChecks := []*widget.Check{
+		widget.NewCheck("O-O", func(on bool) { editPosition.Castling.WhiteKingSide = on }),
+		widget.NewCheck("O-O-O", func(on bool) { editPosition.Castling.WhiteQueenSide = on }),
+		widget.NewCheck("O-O", func(on bool) { editPosition.Castling.BlackKingSide = on }),
+		widget.NewCheck("O-O-O", func(on bool) { editPosition.Castling.BlackQueenSide = on }),
+	}
+
+	// the en passant square follows from its file and the side to move
+	enPassantFile := widget.NewSelect([]string{"-", "a", "b", "c", "d", "e", "f", "g", "h"}, func(file string) {
+		editPosition.EnPassant = handlers.NoSquare
+		if len(file) == 1 && file != "-" {
+			editPosition.EnPassant = handlers.Square{Row: 5, Col: int(file[0] - 'a')}
+			if editPosition.WhiteTurn {
+				editPosition.EnPassant.Row = 2
+			}
+		}
+	})
+	sideToMove.OnChanged = func(side string) {
+		editPosition.WhiteTurn = side == sideWhite
+		enPassantFile.OnChanged(enPassantFile.Selected)
+	}
+
+	// showControls sets the controls from editPosition after it was replaced
+	showControls := func() {
+		castling := editPosition.Castling
+		side := sideBlack
+		if editPosition.WhiteTurn {
+			side = sideWhite
+		}
+		file := "-"
+		if editPosition.EnPassant.Valid() {
+			file = string(editPosition.EnPassant.File())
+		}
+		sideToMove.SetSelected(side)
+		castlingChecks[0].SetChecked(castling.WhiteKingSide)
+		castlingChecks[1].SetChecked(castling.WhiteQueenSide)
+		castlingChecks[2].SetChecked(castling.BlackKingSide)
+		castlingChecks[3].SetChecked(castling.BlackQueenSide)
+		enPassantFile.SetSelected(file)
+	}
+	showControls()
+
+	clearButton := widget.NewButtonWithIcon("Clear", theme.DeleteIcon(), func() {
+		editPosition = handlers.Position{WhiteTurn: true, EnPassant: handlers.NoSquare, FullMoveNumber: 1}
+		showControls()
+		redrawBoard()
+	})
+	resetButton := widget.NewButtonWithIcon("Start position", theme.HomeIcon(), func() {
+		editPosition = handlers.StartPosition()
+		showControls()
+		redrawBoard()
+	})
+	playButton := widget.NewButtonWithIcon("Play", theme.MediaPlayIcon(), playEditedPosition)
+	playButton.Importance = widget.HighImportance
+	cancelButton := widget.NewButtonWithIcon("Cancel", theme.CancelIcon(), cancelEditing)
+
+	form := widget.NewForm(
+		widget.NewFormItem("To move", sideToMove),
+		widget.NewFormItem("White", container.NewHBox(castlingChecks[0], castlingChecks[1])),
+		widget.NewFormItem("Black", container.NewHBox(castlingChecks[2], castlingChecks[3])),
+		widget.NewFormItem("En passant", enPassantFile),
+	)
+
+	// the spacer gives the panel the width of the score sheet it replaces
+	spacer := canvas.NewRectangle(color.Transparent)
+	spacer.SetMinSize(fyne.NewSize(historyPanelWidth, 0))
+	return container.NewStack(spacer, container.NewVBox(
+		widget.NewLabel("Set up position"),
+		palette,
+		container.NewGridWithColumns(2, clearButton, resetButton),
+		form,
+		container.NewGridWithColumns(2, cancelButton, playButton),
+	))
+}
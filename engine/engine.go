@@ -0,0 +1,404 @@
+// Package engine searches chess positions for the best move. It works on
+// handlers.Position and has no GUI dependencies.
+package engine
+
+import (
+	"chess-engine/handlers"
+	"chess-engine/logging"
+	"context"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	infinity = 1_000_000
+	// MateScore is the score of mate on the board; a mate n plies away scores MateScore-n.
+	MateScore = 100_000
+	maxPly    = 64
+)
+
+// Info reports one line of a completed search depth. With Limits.MultiPV above one
+// every depth reports that many lines, best first.
+type Info struct {
+	Depth   int
+	MultiPV int // 1 for the best line, 2 for the second best, ...
+	Score   int // centipawns from the side to move's point of view
+	Mate    int // moves until mate, negative when the side to move gets mated, 0 if no mate was found
+	Nodes   int64
+	Time    time.Duration
+	PV      []handlers.Move
+}
+
+// Searcher finds the best move in a position. Engine is one; an external engine
+// driven over a protocol such as UCI is another.
+type Searcher interface {
+	Search(ctx context.Context, pos handlers.Position, limits Limits, onInfo func(Info)) (move handlers.Move, ok bool)
+}
+
+// MaxThreads is the most search threads an engine runs.
+const MaxThreads = 64
+
+// Engine is an iterative deepening alpha-beta searcher. Its searches share a
+// transposition table, so an engine may run several searches at once, but the
+// table size and thread count are only changed between searches.
+type Engine struct {
+	table   *table
+	threads int
+}
+
+// New returns an engine ready to search, with a DefaultHash table and one thread.
+func New() *Engine {
+	return &Engine{table: newTable(DefaultHash), threads: 1}
+}
+
+// SetHash replaces the transposition table with an empty one of about mb megabytes.
+func (e *Engine) SetHash(mb int) {
+	e.table = newTable(min(max(mb, 1), MaxHash))
+}
+
+// SetThreads sets how many threads a search uses. The extra threads search the
+// same position and help the main one through the shared table.
+func (e *Engine) SetThreads(n int) {
+	e.threads = min(max(n, 1), MaxThreads)
+}
+
+// Clear forgets everything learned in earlier searches, for a new game.
+func (e *Engine) Clear() {
+	e.table.clear()
+}
+
+// search holds the state of a single call to Search, or of one of its helper threads.
+type search struct {
+	ctx      context.Context
+	limits   Limits
+	table    *table
+	start    time.Time
+	deadline time.Time
+	nodes    int64
+	aborted  bool
+
+	// helperNodes is where helper threads count their nodes, reported the ones already added
+	helperNodes *atomic.Int64
+	reported    int64
+
+	// trace records every root move in the trace; only the main thread sets it
+	trace bool
+}
+
+// Search returns the best move found within limits, reporting every completed depth
+// to onInfo (which may be nil). Cancelling ctx stops the search early; the best move
+// of the last completed depth is still returned. ok is false when the side to move
+// has no legal move.
+func (e *Engine) Search(ctx context.Context, pos handlers.Position, limits Limits, onInfo func(Info)) (move handlers.Move, ok bool) {
+	s := &search{ctx: ctx, limits: limits, table: e.table, start: time.Now(), trace: logging.Tracing()}
+	budget := limits.Budget(pos.WhiteTurn)
+	if budget > 0 {
+		s.deadline = s.start.Add(budget)
+	}
+
+	moves := pos.LegalMoves()
+	if len(moves) == 0 {
+		return handlers.Move{}, false
+	}
+	orderMoves(pos, moves)
+	best := moves[0]
+
+	maxDepth := limits.Depth
+	if maxDepth <= 0 || maxDepth > maxPly {
+		maxDepth = maxPly
+	}
+	multiPV := min(max(limits.MultiPV, 1), len(moves))
+	if s.trace {
+		logging.Trace(logging.Engine, "search started", slog.String("fen", pos.FEN()),
+			slog.Int("depth", limits.Depth), slog.Int64("nodes", limits.Nodes), slog.Int64("budget_ms", budget.Milliseconds()),
+			slog.Int("multipv", multiPV), slog.Int("threads", e.threads), slog.Int("moves", len(moves)))
+	}
+
+	// a node limit keeps the search on one thread so it can be repeated exactly
+	helperCtx, stopHelpers := context.WithCancel(ctx)
+	var helpers sync.WaitGroup
+	var helperNodes atomic.Int64
+	for id := 1; id < e.threads && limits.Nodes == 0; id++ {
+		helpers.Add(1)
+		go func() {
+			defer helpers.Done()
+			e.help(helperCtx, pos, id, &helperNodes)
+		}()
+	}
+
+	completed, reason := 0, "depth limit"
+	for depth := 1; depth <= maxDepth; depth++ {
+		lines := s.root(pos, moves, depth, multiPV)
+		if s.aborted {
+			// an interrupted depth is only trusted up to the moves it finished
+			reason = "stopped"
+			break
+		}
+		completed = depth
+		if s.trace {
+			logging.Trace(logging.Engine, "iteration", slog.Int("depth", depth),
+				slog.String("best", lines[0].pv[0].String()), slog.Int("score", lines[0].score),
+				slog.Int64("nodes", s.nodes+helperNodes.Load()), slog.Int64("time_ms", time.Since(s.start).Milliseconds()),
+				slog.String("pv", pvString(lines[0].pv)))
+		}
+		best = lines[0].pv[0]
+		// the next depth searches the best lines first, in order
+		for i := len(lines) - 1; i >= 0; i-- {
+			promote(moves, lines[i].pv[0])
+		}
+
+		if onInfo != nil {
+			for i, l := range lines {
+				onInfo(Info{Depth: depth, MultiPV: i + 1, Score: l.score, Mate: mateIn(l.score),
+					Nodes: s.nodes + helperNodes.Load(), Time: time.Since(s.start), PV: l.pv})
+			}
+		}
+		if multiPV == 1 && mateIn(lines[0].score) != 0 {
+			reason = "mate found"
+			break
+		}
+		// another depth takes several times longer than this one did
+		if budget > 0 && time.Since(s.start) > budget/2 {
+			reason = "time"
+			break
+		}
+	}
+	stopHelpers()
+	helpers.Wait()
+	if s.trace {
+		logging.Trace(logging.Engine, "search finished", slog.String("move", best.String()),
+			slog.Int("depth", completed), slog.Int64("nodes", s.nodes+helperNodes.Load()),
+			slog.Int64("time_ms", time.Since(s.start).Milliseconds()), slog.String("reason", reason))
+	}
+	return best, true
+}
+
+// help searches pos alongside the main thread until ctx is cancelled. Odd helpers
+// start a depth ahead so the threads spread over different depths; what they find
+// reaches the main thread through the best moves they store in the table.
+func (e *Engine) help(ctx context.Context, pos handlers.Position, id int, nodes *atomic.Int64) {
+	s := &search{ctx: ctx, table: e.table, start: time.Now(), helperNodes: nodes}
+	moves := pos.LegalMoves()
+	orderMoves(pos, moves)
+	for depth := 1 + id%2; depth <= maxPly; depth++ {
+		lines := s.root(pos, moves, depth, 1)
+		if s.aborted {
+			break
+		}
+		promote(moves, lines[0].pv[0])
+	}
+	s.reportNodes()
+}
+
+// line is a root move with its score and principal variation.
+type line struct {
+	score int
+	pv    []handlers.Move
+}
+
+// root searches every root move at the given depth and returns the best count lines,
+// best first. A move only gets an exact score when it beats the worst line kept so far.
+func (s *search) root(pos handlers.Position, moves []handlers.Move, depth, count int) []line {
+	var lines []line
+	for _, m := range moves {
+		alpha := -infinity
+		if len(lines) == count {
+			alpha = lines[count-1].score
+		}
+		score, pv := s.alphaBeta(pos.Play(m), depth-1, 1, -infinity, -alpha)
+		score = -score
+		if s.aborted {
+			return nil
+		}
+		if s.trace {
+			bound := "upper"
+			if len(lines) < count || score > alpha {
+				bound = "exact"
+			}
+			logging.Trace(logging.Engine, "root move", slog.Int("depth", depth), slog.String("move", m.String()),
+				slog.Int("score", score), slog.String("bound", bound), slog.Int64("nodes", s.nodes))
+		}
+		if len(lines) < count || score > alpha {
+			i := sort.Search(len(lines), func(i int) bool { return lines[i].score < score })
+			lines = append(lines, line{})
+			copy(lines[i+1:], lines[i:])
+			lines[i] = line{score: score, pv: append([]handlers.Move{m}, pv...)}
+			if len(lines) > count {
+				lines = lines[:count]
+			}
+		}
+	}
+	return lines
+}
+
+func (s *search) alphaBeta(pos handlers.Position, depth, ply, alpha, beta int) (int, []handlers.Move) {
+	if s.shouldAbort() {
+		return 0, nil
+	}
+	s.nodes++
+
+	inCheck := pos.InCheck()
+	if ply >= maxPly {
+		return Evaluate(pos), nil
+	}
+	// checks are searched one ply further so the horizon never hides a mate
+	if depth <= 0 && !inCheck {
+		return s.quiesce(pos, ply, alpha, beta), nil
+	}
+
+	moves := pos.LegalMoves()
+	if len(moves) == 0 {
+		if inCheck {
+			return -MateScore + ply, nil
+		}
+		return 0, nil
+	}
+	if pos.HalfMoveClock >= 100 {
+		return 0, nil
+	}
+	orderMoves(pos, moves)
+	key := hashKey(pos)
+	if m, ok := s.table.probe(key); ok {
+		promote(moves, m)
+	}
+
+	var bestPV []handlers.Move
+	for _, m := range moves {
+		score, pv := s.alphaBeta(pos.Play(m), depth-1, ply+1, -beta, -alpha)
+		score = -score
+		if s.aborted {
+			return 0, nil
+		}
+		if score > alpha {
+			alpha = score
+			bestPV = append([]handlers.Move{m}, pv...)
+			if alpha >= beta {
+				break
+			}
+		}
+	}
+	if bestPV != nil {
+		s.table.store(key, depth, bestPV[0])
+	}
+	return alpha, bestPV
+}
+
+// quiesce only follows captures and promotions so the static evaluation is taken in a quiet position.
+func (s *search) quiesce(pos handlers.Position, ply, alpha, beta int) int {
+	if s.shouldAbort() {
+		return 0
+	}
+	s.nodes++
+
+	standPat := Evaluate(pos)
+	if standPat >= beta || ply >= maxPly {
+		return standPat
+	}
+	if standPat > alpha {
+		alpha = standPat
+	}
+
+	moves := pos.LegalMoves()
+	orderMoves(pos, moves)
+	for _, m := range moves {
+		if !isTactical(pos, m) {
+			// ordering puts every capture and promotion first
+			break
+		}
+		score := -s.quiesce(pos.Play(m), ply+1, -beta, -alpha)
+		if s.aborted {
+			return 0
+		}
+		if score > alpha {
+			alpha = score
+			if alpha >= beta {
+				break
+			}
+		}
+	}
+	return alpha
+}
+
+// shouldAbort checks the stop conditions every few thousand nodes.
+func (s *search) shouldAbort() bool {
+	if s.aborted {
+		return true
+	}
+	if s.nodes&2047 != 0 {
+		return false
+	}
+	s.reportNodes()
+	if s.ctx.Err() != nil ||
+		(s.limits.Nodes > 0 && s.nodes >= s.limits.Nodes) ||
+		(!s.deadline.IsZero() && time.Now().After(s.deadline)) {
+		s.aborted = true
+	}
+	return s.aborted
+}
+
+// reportNodes adds a helper thread's new nodes to the count the main thread reports.
+func (s *search) reportNodes() {
+	if s.helperNodes != nil {
+		s.helperNodes.Add(s.nodes - s.reported)
+		s.reported = s.nodes
+	}
+}
+
+func isTactical(pos handlers.Position, m handlers.Move) bool {
+	return m.Promotion != 0 || pos.PieceAt(m.To) != 0 ||
+		(m.From.Col != m.To.Col && (pos.PieceAt(m.From) == 'P' || pos.PieceAt(m.From) == 'p'))
+}
+
+// orderMoves sorts captures first, most valuable victim and least valuable attacker first, then promotions.
+func orderMoves(pos handlers.Position, moves []handlers.Move) {
+	score := func(m handlers.Move) int {
+		value := 0
+		if victim := pos.PieceAt(m.To); victim != 0 {
+			value += 10*pieceValue(victim) - pieceValue(pos.PieceAt(m.From)) + 10000
+		} else if isTactical(pos, m) && m.Promotion == 0 {
+			value += 10*pieceValue('P') - pieceValue('P') + 10000 // en passant
+		}
+		if m.Promotion != 0 {
+			value += pieceValue(m.Promotion) + 10000
+		}
+		return value
+	}
+	sort.SliceStable(moves, func(i, j int) bool {
+		return score(moves[i]) > score(moves[j])
+	})
+}
+
+// promote moves m to the front of moves so the next depth searches it first.
+func promote(moves []handlers.Move, m handlers.Move) {
+	for i := range moves {
+		if moves[i] == m {
+			copy(moves[1:i+1], moves[:i])
+			moves[0] = m
+			return
+		}
+	}
+}
+
+// mateIn converts a mate score into moves to mate, 0 for normal scores.
+func mateIn(score int) int {
+	switch {
+	case score >= MateScore-maxPly:
+		return (MateScore - score + 1) / 2
+	case score <= -MateScore+maxPly:
+		return -(MateScore + score + 1) / 2
+	}
+	return 0
+}
+
+// pvString writes a line of moves in coordinate notation, separated by spaces.
+func pvString(pv []handlers.Move) string {
+	text := make([]string, len(pv))
+	for i, m := range pv {
+		text[i] = m.String()
+	}
+	return strings.Join(text, " ")
+}
@@ -0,0 +1,147 @@
+package engine
+
+import (
+	"chess-engine/handlers"
+	"math/rand"
+	"strings"
+	"sync/atomic"
+)
+
+// Hash table sizes in megabytes, as offered through the UCI Hash option.
+const (
+	DefaultHash = 16
+	MaxHash     = 1024
+)
+
+// entrySize is the memory taken by one table entry: its checked key and its data.
+const entrySize = 16
+
+// table is a transposition table that remembers the best move found in a position,
+// which is searched first when the position comes up again, in a later iteration or
+// through another move order. It is shared by every search thread without locks:
+// an entry stores its key xor'ed with its data, so a torn write fails the key check.
+type table struct {
+	entries []tableEntry
+	mask    uint64
+}
+
+type tableEntry struct {
+	check atomic.Uint64 // key ^ data
+	data  atomic.Uint64
+}
+
+// newTable makes a table of at most mb megabytes; its size is a power of two so an
+// index is a mask of the key.
+func newTable(mb int) *table {
+	size := uint64(1)
+	for size*2*entrySize <= uint64(mb)<<20 {
+		size *= 2
+	}
+	return &table{entries: make([]tableEntry, size), mask: size - 1}
+}
+
+// probe returns the best move stored for a position.
+func (t *table) probe(key uint64) (handlers.Move, bool) {
+	e := &t.entries[key&t.mask]
+	data := e.data.Load()
+	if e.check.Load()^data != key || data == 0 {
+		return handlers.Move{}, false
+	}
+	return unpackMove(data), true
+}
+
+// store keeps the best move of a position searched to depth, unless the slot holds
+// a deeper search of the same position. Checks searched past the horizon come with a
+// negative depth, which is stored as 0 so it does not wrap around to a huge one.
+func (t *table) store(key uint64, depth int, m handlers.Move) {
+	depth = max(depth, 0)
+	e := &t.entries[key&t.mask]
+	old := e.data.Load()
+	if e.check.Load()^old == key && int(old>>24) > depth {
+		return
+	}
+	data := packMove(m) | uint64(depth)<<24
+	e.data.Store(data)
+	e.check.Store(key ^ data)
+}
+
+func (t *table) clear() {
+	for i := range t.entries {
+		t.entries[i].data.Store(0)
+		t.entries[i].check.Store(0)
+	}
+}
+
+// promotionCodes numbers the promotion pieces so a move fits in 16 bits.
+const promotionCodes = " QRBNqrbn"
+
+// packMove stores a move as from square, to square and promotion code, the low 16
+// bits; a stored move is never 0 because the two squares differ.
+func packMove(m handlers.Move) uint64 {
+	from := m.From.Row*8 + m.From.Col
+	to := m.To.Row*8 + m.To.Col
+	promotion := max(strings.IndexRune(promotionCodes, m.Promotion), 0)
+	return uint64(from | to<<6 | promotion<<12)
+}
+
+func unpackMove(data uint64) handlers.Move {
+	from, to := int(data&63), int(data>>6&63)
+	m := handlers.NewMove(handlers.Square{Row: from / 8, Col: from % 8}, handlers.Square{Row: to / 8, Col: to % 8}, 0)
+	if code := int(data >> 12 & 15); code > 0 {
+		m.Promotion = rune(promotionCodes[code])
+	}
+	return m
+}
+
+// Zobrist keys: one random number per piece on each square, per castling right and
+// en passant file, and for Black to move. A position's key xors the ones that apply.
+var (
+	pieceKeys     map[rune]*[64]uint64
+	castlingKeys  [4]uint64 // K, Q, k, q
+	enPassantKeys [8]uint64
+	blackToMove   uint64
+)
+
+func init() {
+	random := rand.New(rand.NewSource(2024))
+	pieceKeys = map[rune]*[64]uint64{}
+	for _, piece := range "PNBRQKpnbrqk" {
+		keys := new([64]uint64)
+		for i := range keys {
+			keys[i] = random.Uint64()
+		}
+		pieceKeys[piece] = keys
+	}
+	for i := range castlingKeys {
+		castlingKeys[i] = random.Uint64()
+	}
+	for i := range enPassantKeys {
+		enPassantKeys[i] = random.Uint64()
+	}
+	blackToMove = random.Uint64()
+}
+
+// hashKey returns the Zobrist key of a position.
+func hashKey(pos handlers.Position) uint64 {
+	var key uint64
+	for row := 0; row < 8; row++ {
+		for col := 0; col < 8; col++ {
+			if piece := pos.Board[row][col]; piece != 0 {
+				key ^= pieceKeys[piece][row*8+col]
+			}
+		}
+	}
+	c := pos.Castling
+	for i, right := range []bool{c.WhiteKingSide, c.WhiteQueenSide, c.BlackKingSide, c.BlackQueenSide} {
+		if right {
+			key ^= castlingKeys[i]
+		}
+	}
+	if pos.EnPassant.Valid() {
+		key ^= enPassantKeys[pos.EnPassant.Col]
+	}
+	if !pos.WhiteTurn {
+		key ^= blackToMove
+	}
+	return key
+}
@@ -0,0 +1,52 @@
+package engine
+
+import (
+	"chess-engine/handlers"
+	"testing"
+)
+
+func TestPackMove(t *testing.T) {
+	pos, err := handlers.ParseFEN("r3k2r/1P6/8/8/8/8/8/R3K2R w KQkq - 0 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range pos.LegalMoves() {
+		if got := unpackMove(packMove(m)); got != m {
+			t.Errorf("unpackMove(packMove(%s)) = %s", m, got)
+		}
+	}
+}
+
+func TestTableStore(t *testing.T) {
+	e2e4 := handlers.NewMove(handlers.Square{Row: 6, Col: 4}, handlers.Square{Row: 4, Col: 4}, 0)
+	d2d4 := handlers.NewMove(handlers.Square{Row: 6, Col: 3}, handlers.Square{Row: 4, Col: 3}, 0)
+	tests := []struct {
+		name   string
+		first  int // depth of the e2e4 entry
+		second int // depth of the d2d4 entry stored after it
+		want   handlers.Move
+	}{
+		{"deeper replaces", 3, 5, d2d4},
+		{"equal replaces", 4, 4, d2d4},
+		{"shallower is dropped", 5, 3, e2e4},
+		{"negative depth is kept as 0", -2, 0, d2d4},
+		{"negative depth does not beat a real one", 1, -3, e2e4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tab := newTable(1)
+			const key = 0x9e3779b97f4a7c15
+			tab.store(key, tt.first, e2e4)
+			if m, ok := tab.probe(key); !ok || m != e2e4 {
+				t.Fatalf("probe after the first store = %s, %v", m, ok)
+			}
+			tab.store(key, tt.second, d2d4)
+			if m, ok := tab.probe(key); !ok || m != tt.want {
+				t.Errorf("probe = %s, %v, want %s", m, ok, tt.want)
+			}
+			if _, ok := tab.probe(key + 1); ok {
+				t.Errorf("probe of another key found a move")
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package engine
+
+import "time"
+
+// Limits bound a search. Zero values mean "no limit"; with no limits at all the
+// search runs until Stop is called.
+type Limits struct {
+	Depth    int
+	Nodes    int64
+	MoveTime time.Duration
+
+	// MultiPV is the number of best lines to report, one when zero.
+	MultiPV int
+
+	// Clock state, used by the time manager to plan the move.
+	WhiteTime, BlackTime time.Duration
+	WhiteInc, BlackInc   time.Duration
+	MovesToGo            int
+}
+
+// defaultMovesToGo is the number of moves assumed to be left in a game with no time control stages.
+const defaultMovesToGo = 30
+
+// Budget is the time manager: it returns how long the side to move may think,
+// or 0 when the limits put no bound on time.
+func (l Limits) Budget(whiteToMove bool) time.Duration {
+	if l.MoveTime > 0 {
+		return l.MoveTime
+	}
+
+	remaining, inc := l.WhiteTime, l.WhiteInc
+	if !whiteToMove {
+		remaining, inc = l.BlackTime, l.BlackInc
+	}
+	if remaining <= 0 {
+		return 0
+	}
+
+	movesToGo := l.MovesToGo
+	if movesToGo <= 0 {
+		movesToGo = defaultMovesToGo
+	}
+	budget := remaining/time.Duration(movesToGo) + inc*3/4
+
+	// keep a reserve so a slow iteration never loses on time
+	if budget > remaining/3 {
+		budget = remaining / 3
+	}
+	return max(budget-10*time.Millisecond, 5*time.Millisecond)
+}
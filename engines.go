@@ -0,0 +1,177 @@
+package main
+
+import (
+	"chess-engine/engine"
+	"chess-engine/player"
+	"chess-engine/uci"
+	"fmt"
+	"path/filepath"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// engineSlot is a job an engine does in the app, playing the computer's moves or
+// analysing. Either job can be handed to an external UCI engine; each slot starts
+// its own process, so the same binary can do both.
+type engineSlot struct {
+	title      string
+	preference string // where the engine's path is kept between runs
+	client     *uci.Client
+	path       string
+}
+
+var (
+	opponentEngine = &engineSlot{title: "Opponent", preference: "opponentEngine"}
+	analysisEngine = &engineSlot{title: "Analysis", preference: "analysisEngine"}
+)
+
+// searcher returns the engine doing the job: the external one, or the built-in one.
+func (s *engineSlot) searcher() engine.Searcher {
+	if s.client != nil {
+		return s.client
+	}
+	return aiEngine
+}
+
+// player returns the engine doing the job as a player of the game.
+func (s *engineSlot) player() player.Player {
+	if s.client != nil {
+		return player.NewUCI(s.client)
+	}
+	return player.NewEngine("Computer", aiEngine)
+}
+
+func (s *engineSlot) name() string {
+	switch {
+	case s.client == nil:
+		return "Built-in"
+	case s.client.Name != "":
+		return s.client.Name
+	}
+	return filepath.Base(s.path)
+}
+
+// use starts the UCI engine at path for the job, or goes back to the built-in engine
+// when path is empty. The engine it replaces is shut down.
+func (s *engineSlot) use(path string) error {
+	client, err := startEngine(path)
+	if err != nil {
+		return err
+	}
+	s.set(client, path)
+	return nil
+}
+
+// startEngine starts the UCI engine at path, or returns nil for the built-in engine.
+func startEngine(path string) (*uci.Client, error) {
+	if path == "" {
+		return nil, nil
+	}
+	return uci.Start(path)
+}
+
+// set hands the job to client, started from path, and shuts down the engine it replaces.
+func (s *engineSlot) set(client *uci.Client, path string) {
+	old := s.client
+	s.client, s.path = client, path
+	fyne.CurrentApp().Preferences().SetString(s.preference, path)
+	if old != nil {
+		go old.Close()
+	}
+}
+
+// checkFailed reports whether the external engine stopped working, crashed or
+// hung, and if so tells the player and goes back to the built-in engine.
+func (s *engineSlot) checkFailed() bool {
+	if s.client == nil || s.client.Err() == nil {
+		return false
+	}
+	err := fmt.Errorf("%s stopped working, the built-in engine takes over: %w", s.name(), s.client.Err())
+	s.set(nil, "")
+	dialog.ShowError(err, chessWindow)
+	return true
+}
+
+// loadEngines starts the external engines saved in the preferences; one that no
+// longer starts is replaced by the built-in engine.
+func loadEngines(prefs fyne.Preferences) {
+	for _, slot := range []*engineSlot{opponentEngine, analysisEngine} {
+		path := prefs.String(slot.preference)
+		if path == "" {
+			continue
+		}
+		if err := slot.use(path); err != nil {
+			logger.Warn("using the built-in engine", "path", path, "err", err)
+			prefs.SetString(slot.preference, "")
+		}
+	}
+}
+
+func closeEngines() {
+	for _, slot := range []*engineSlot{opponentEngine, analysisEngine} {
+		if slot.client != nil {
+			slot.client.Close()
+		}
+	}
+}
+
+// restart picks the job up again with a newly chosen engine: the new engine takes
+// over the computer's side, and the analysis starts over.
+func (s *engineSlot) restart() {
+	if s == analysisEngine {
+		restartAnalysis()
+		return
+	}
+	resumePlay()
+}
+
+func restartAnalysis() {
+	analyzedFEN = ""
+	updateAnalysis()
+}
+
+// showEnginesDialog lets the player choose the engine for each job.
+func showEnginesDialog() {
+	form := widget.NewForm()
+	for _, slot := range []*engineSlot{opponentEngine, analysisEngine} {
+		nameLabel := widget.NewLabel(slot.name())
+		nameLabel.Wrapping = fyne.TextWrapBreak
+		apply := func(path string) {
+			nameLabel.SetText("Starting...")
+			// the handshake can take a while, the slot itself only changes on the UI thread
+			go func() {
+				client, err := startEngine(path)
+				fyne.Do(func() {
+					if err != nil {
+						dialog.ShowError(err, chessWindow)
+					} else {
+						slot.set(client, path)
+						slot.restart()
+					}
+					nameLabel.SetText(slot.name())
+				})
+			}()
+		}
+		builtinButton := widget.NewButton("Built-in", func() { apply("") })
+		loadButton := widget.NewButton("Load UCI engine...", func() {
+			dialog.ShowFileOpen(func(reader fyne.URIReadCloser, err error) {
+				if err != nil {
+					dialog.ShowError(err, chessWindow)
+					return
+				}
+				if reader == nil {
+					return
+				}
+				reader.Close()
+				apply(reader.URI().Path())
+			}, chessWindow)
+		})
+		form.Append(slot.title, container.NewVBox(nameLabel, container.NewHBox(builtinButton, loadButton)))
+	}
+	enginesDialog := dialog.NewCustom("Engines", "Close", form, chessWindow)
+	enginesDialog.Resize(fyne.NewSize(420, 0))
+	enginesDialog.Show()
+}
@@ -0,0 +1,223 @@
+// Package game keeps the record of a single chess game: the moves played, the
+// positions they lead to and how the game ended. It has no GUI dependencies.
+package game
+
+import (
+	"chess-engine/handlers"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Result is the outcome of a game in PGN notation.
+type Result string
+
+const (
+	Ongoing   Result = "*"
+	WhiteWins Result = "1-0"
+	BlackWins Result = "0-1"
+	Draw      Result = "1/2-1/2"
+)
+
+// Reason explains how a finished game ended.
+type Reason string
+
+const (
+	Checkmate   Reason = "checkmate"
+	Stalemate   Reason = "stalemate"
+	Repetition  Reason = "threefold repetition"
+	Resignation Reason = "resignation"
+	Timeout     Reason = "timeout"
+	Agreement   Reason = "agreement"
+	// TimeoutVsInsufficientMaterial is a draw: the flag fell but the opponent could not have mated.
+	TimeoutVsInsufficientMaterial Reason = "timeout vs insufficient material"
+	// Recorded is given to a result read from a PGN file that the moves alone do not explain.
+	Recorded Reason = "recorded result"
+)
+
+var (
+	ErrGameOver    = errors.New("the game is over")
+	ErrIllegalMove = errors.New("illegal move")
+)
+
+// Game is a sequence of moves from a starting position.
+type Game struct {
+	// Tags are the PGN header tags. Result is kept in sync when the PGN is written.
+	Tags map[string]string
+
+	positions []handlers.Position // positions[i] is the position after i plies
+	moves     []handlers.Move
+	san       []string
+	result    Result
+	reason    Reason
+
+	shapes map[int][]Shape // arrows and squares drawn on positions[ply]
+}
+
+// New starts a game from the standard initial position.
+func New() *Game {
+	return NewFromPosition(handlers.StartPosition())
+}
+
+// NewFromPosition starts a game from an arbitrary position.
+func NewFromPosition(start handlers.Position) *Game {
+	g := &Game{
+		Tags: map[string]string{
+			"Event": "Casual game",
+			"Site":  "chessgo",
+			"Date":  time.Now().Format("2006.01.02"),
+			"Round": "-",
+			"White": "?",
+			"Black": "?",
+		},
+		positions: []handlers.Position{start},
+		result:    Ongoing,
+	}
+	if start.FEN() != handlers.StartFEN {
+		g.Tags["SetUp"] = "1"
+		g.Tags["FEN"] = start.FEN()
+	}
+	g.updateResult()
+	return g
+}
+
+// Position returns the current position.
+func (g *Game) Position() handlers.Position {
+	return g.positions[len(g.positions)-1]
+}
+
+// StartPosition returns the position the game started from.
+func (g *Game) StartPosition() handlers.Position {
+	return g.positions[0]
+}
+
+// PositionAt returns the position after the given number of half-moves, 0 being the start.
+func (g *Game) PositionAt(ply int) handlers.Position {
+	return g.positions[ply]
+}
+
+// Ply returns the number of half-moves played.
+func (g *Game) Ply() int {
+	return len(g.moves)
+}
+
+// Moves returns the moves played so far.
+func (g *Game) Moves() []handlers.Move {
+	return append([]handlers.Move(nil), g.moves...)
+}
+
+// SAN returns the moves played so far in Standard Algebraic Notation.
+func (g *Game) SAN() []string {
+	return append([]string(nil), g.san...)
+}
+
+// Result returns the game result, Ongoing while it is still being played.
+func (g *Game) Result() Result {
+	return g.result
+}
+
+// Reason returns why the game ended, or "" while it is still being played.
+func (g *Game) Reason() Reason {
+	return g.reason
+}
+
+// Over reports whether the game has finished.
+func (g *Game) Over() bool {
+	return g.result != Ongoing
+}
+
+// Play validates m, appends it to the game and checks whether it ended the game.
+func (g *Game) Play(m handlers.Move) error {
+	if g.Over() {
+		return ErrGameOver
+	}
+	pos := g.Position()
+	if err := pos.CheckMove(m); err != nil {
+		return fmt.Errorf("%w: %w", ErrIllegalMove, err)
+	}
+
+	g.san = append(g.san, pos.SAN(m))
+	g.moves = append(g.moves, m)
+	g.positions = append(g.positions, pos.Play(m))
+	g.updateResult()
+	return nil
+}
+
+// Truncate drops every move after the given ply so play can continue from an
+// earlier position. A finished game is reopened unless the position itself ends it.
+func (g *Game) Truncate(ply int) {
+	if ply < 0 || ply >= len(g.moves) {
+		return
+	}
+	g.moves = g.moves[:ply]
+	g.san = g.san[:ply]
+	g.positions = g.positions[:ply+1]
+	for drawn := range g.shapes {
+		if drawn > ply {
+			delete(g.shapes, drawn)
+		}
+	}
+	g.result, g.reason = Ongoing, ""
+	g.updateResult()
+}
+
+// Resign ends the game with a loss for the given side.
+func (g *Game) Resign(white bool) {
+	if g.Over() {
+		return
+	}
+	g.end(!white, Resignation)
+}
+
+// AgreeDraw ends the game in a draw the players agreed on.
+func (g *Game) AgreeDraw() {
+	if g.Over() {
+		return
+	}
+	g.result, g.reason = Draw, Agreement
+}
+
+// Flag ends the game because the given side ran out of time. It loses, unless
+// the opponent has no mating material left, in which case the game is drawn.
+func (g *Game) Flag(white bool) {
+	if g.Over() {
+		return
+	}
+	if !handlers.HasMatingMaterial(g.Position().Board, !white) {
+		g.result, g.reason = Draw, TimeoutVsInsufficientMaterial
+		return
+	}
+	g.end(!white, Timeout)
+}
+
+// end finishes the game with a win for the given side.
+func (g *Game) end(whiteWins bool, reason Reason) {
+	g.result = BlackWins
+	if whiteWins {
+		g.result = WhiteWins
+	}
+	g.reason = reason
+}
+
+func (g *Game) updateResult() {
+	pos := g.Position()
+	if len(pos.LegalMoves()) == 0 {
+		if pos.InCheck() {
+			g.end(!pos.WhiteTurn, Checkmate)
+		} else {
+			g.result, g.reason = Draw, Stalemate
+		}
+		return
+	}
+
+	key := pos.Key()
+	seen := 0
+	for _, earlier := range g.positions {
+		if earlier.Key() == key {
+			seen++
+		}
+	}
+	if seen >= 3 {
+		g.result, g.reason = Draw, Repetition
+	}
+}
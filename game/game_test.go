@@ -0,0 +1,127 @@
+package game
+
+import (
+	"chess-engine/handlers"
+	"errors"
+	"testing"
+)
+
+// play plays moves in SAN from fen, or from the initial position when fen is empty.
+func play(t *testing.T, fen string, moves ...string) *Game {
+	t.Helper()
+	g := New()
+	if fen != "" {
+		start, err := handlers.ParseFEN(fen)
+		if err != nil {
+			t.Fatal(err)
+		}
+		g = NewFromPosition(start)
+	}
+	for _, san := range moves {
+		m, err := g.Position().ParseSAN(san)
+		if err != nil {
+			t.Fatalf("%s: %v", san, err)
+		}
+		if err := g.Play(m); err != nil {
+			t.Fatalf("%s: %v", san, err)
+		}
+	}
+	return g
+}
+
+func TestGameEnd(t *testing.T) {
+	tests := []struct {
+		name   string
+		fen    string
+		moves  []string
+		result Result
+		reason Reason
+	}{
+		{"ongoing", "", []string{"e4", "e5"}, Ongoing, ""},
+		{"fool's mate", "", []string{"f3", "e5", "g4", "Qh4#"}, BlackWins, Checkmate},
+		{"scholar's mate", "", []string{"e4", "e5", "Bc4", "Nc6", "Qh5", "Nf6", "Qxf7#"}, WhiteWins, Checkmate},
+		{"stalemate", "k7/8/2Q5/8/8/8/8/7K w - - 0 1", []string{"Qb6"}, Draw, Stalemate},
+		{"threefold repetition", "", []string{"Nf3", "Nf6", "Ng1", "Ng8", "Nf3", "Nf6", "Ng1", "Ng8"}, Draw, Repetition},
+		{"twofold repetition", "", []string{"Nf3", "Nf6", "Ng1", "Ng8"}, Ongoing, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := play(t, tt.fen, tt.moves...)
+			if g.Result() != tt.result || g.Reason() != tt.reason {
+				t.Errorf("result %s (%s), want %s (%s)", g.Result(), g.Reason(), tt.result, tt.reason)
+			}
+			if g.Over() != (tt.result != Ongoing) {
+				t.Errorf("Over() = %v with result %s", g.Over(), g.Result())
+			}
+		})
+	}
+}
+
+// TestRepetitionAfterDoubleStep repeats the position after 1. e4: no pawn can take
+// on e3, so its first occurrence counts as well.
+func TestRepetitionAfterDoubleStep(t *testing.T) {
+	g, err := ParsePGN("1. e4 Nf6 2. Nf3 Ng8 3. Ng1 Nf6 4. Nf3 Ng8 5. Ng1 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Result() != Draw || g.Reason() != Repetition {
+		t.Errorf("result %s (%s), want a draw by repetition", g.Result(), g.Reason())
+	}
+}
+
+func TestResign(t *testing.T) {
+	g := play(t, "", "e4")
+	g.Resign(false)
+	if g.Result() != WhiteWins || g.Reason() != Resignation {
+		t.Errorf("result %s (%s) after Black resigned", g.Result(), g.Reason())
+	}
+	g.Resign(true)
+	if g.Result() != WhiteWins {
+		t.Errorf("resigning a finished game changed the result to %s", g.Result())
+	}
+}
+
+func TestPlayErrors(t *testing.T) {
+	g := New()
+	e2, _ := handlers.ParseSquare("e2")
+	e5, _ := handlers.ParseSquare("e5")
+	if err := g.Play(handlers.NewMove(e2, e5, 0)); !errors.Is(err, ErrIllegalMove) {
+		t.Errorf("Play(e2e5) = %v, want ErrIllegalMove", err)
+	}
+	if g.Ply() != 0 {
+		t.Errorf("an illegal move was recorded")
+	}
+
+	g = play(t, "", "f3", "e5", "g4", "Qh4#")
+	a2, _ := handlers.ParseSquare("a2")
+	a3, _ := handlers.ParseSquare("a3")
+	if err := g.Play(handlers.NewMove(a2, a3, 0)); !errors.Is(err, ErrGameOver) {
+		t.Errorf("Play after mate = %v, want ErrGameOver", err)
+	}
+}
+
+func TestFlag(t *testing.T) {
+	tests := []struct {
+		name   string
+		fen    string
+		white  bool
+		result Result
+		reason Reason
+	}{
+		{"white flags", handlers.StartFEN, true, BlackWins, Timeout},
+		{"black flags", handlers.StartFEN, false, WhiteWins, Timeout},
+		{"lone king cannot win", "4k3/8/8/8/8/8/8/3QK3 w - - 0 1", true, Draw, TimeoutVsInsufficientMaterial},
+		{"king and knight cannot win", "4k3/8/8/8/8/8/8/3NK3 w - - 0 1", false, Draw, TimeoutVsInsufficientMaterial},
+		{"king, bishop and knight can win", "4k3/8/8/8/8/8/8/2BNK3 w - - 0 1", false, WhiteWins, Timeout},
+		{"a pawn can win", "4k3/8/8/8/8/8/4P3/4K3 w - - 0 1", false, WhiteWins, Timeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := play(t, tt.fen)
+			g.Flag(tt.white)
+			if g.Result() != tt.result || g.Reason() != tt.reason {
+				t.Errorf("result %s (%s), want %s (%s)", g.Result(), g.Reason(), tt.result, tt.reason)
+			}
+		})
+	}
+}
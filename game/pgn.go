@@ -0,0 +1,218 @@
+package game
+
+import (
+	"chess-engine/handlers"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// sevenTagRoster is the order PGN requires for the mandatory tags.
+var sevenTagRoster = []string{"Event", "Site", "Date", "Round", "White", "Black", "Result"}
+
+// PGN returns the game in Portable Game Notation.
+func (g *Game) PGN() string {
+	g.Tags["Result"] = string(g.result)
+
+	var sb strings.Builder
+	for _, name := range sevenTagRoster {
+		fmt.Fprintf(&sb, "[%s %q]\n", name, g.Tags[name])
+	}
+	var extra []string
+	for name := range g.Tags {
+		if !isRosterTag(name) {
+			extra = append(extra, name)
+		}
+	}
+	sort.Strings(extra)
+	for _, name := range extra {
+		fmt.Fprintf(&sb, "[%s %q]\n", name, g.Tags[name])
+	}
+	sb.WriteByte('\n')
+
+	var tokens []string
+	if comment := shapeComment(g.shapes[0]); comment != "" {
+		tokens = append(tokens, comment)
+	}
+	for i, san := range g.san {
+		pos := g.positions[i]
+		if pos.WhiteTurn {
+			tokens = append(tokens, fmt.Sprintf("%d.", pos.FullMoveNumber))
+		} else if i == 0 || g.shapes[i] != nil {
+			// a comment interrupts the move pair, so Black's move gets its number again
+			tokens = append(tokens, fmt.Sprintf("%d...", pos.FullMoveNumber))
+		}
+		tokens = append(tokens, san)
+		if comment := shapeComment(g.shapes[i+1]); comment != "" {
+			tokens = append(tokens, comment)
+		}
+	}
+	tokens = append(tokens, string(g.result))
+
+	// PGN export format keeps lines under 80 characters
+	line := 0
+	for i, token := range tokens {
+		if i > 0 {
+			if line+1+len(token) > 79 {
+				sb.WriteByte('\n')
+				line = 0
+			} else {
+				sb.WriteByte(' ')
+				line++
+			}
+		}
+		sb.WriteString(token)
+		line += len(token)
+	}
+	sb.WriteByte('\n')
+	return sb.String()
+}
+
+func isRosterTag(name string) bool {
+	for _, tag := range sevenTagRoster {
+		if tag == name {
+			return true
+		}
+	}
+	return false
+}
+
+var tagPattern = regexp.MustCompile(`^\[(\w+)\s+"((?:[^"\\]|\\.)*)"\]$`)
+
+// moveNumberPattern matches the move number in front of a move, e.g. "12." or "12...".
+var moveNumberPattern = regexp.MustCompile(`^\d+\.+`)
+
+// ParsePGN reads the first game of a PGN text. A game starting from a FEN tag is
+// validated first, and a result token that the moves do not reach on their own, such
+// as a resignation, is kept as the game's result. When a move cannot be played, the
+// game up to it is returned along with the error.
+func ParsePGN(text string) (*Game, error) {
+	tags := map[string]string{}
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	i := 0
+	for ; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "[") {
+			break
+		}
+		match := tagPattern.FindStringSubmatch(line)
+		if match == nil {
+			return nil, fmt.Errorf("invalid PGN tag %s", line)
+		}
+		tags[match[1]] = strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(match[2])
+	}
+
+	start := handlers.StartPosition()
+	if fen, ok := tags["FEN"]; ok {
+		pos, err := handlers.ParseFEN(fen)
+		if err != nil {
+			return nil, fmt.Errorf("FEN tag: %w", err)
+		}
+		if err := pos.Validate(); err != nil {
+			return nil, fmt.Errorf("FEN tag: %w", err)
+		}
+		start = pos
+	}
+	g := NewFromPosition(start)
+	for name, value := range tags {
+		g.Tags[name] = value
+	}
+
+	result := Ongoing
+	for _, token := range movetextTokens(strings.Join(lines[i:], "\n")) {
+		if strings.HasPrefix(token, "{") {
+			if shapes := commentShapes(token); len(shapes) > 0 {
+				g.SetShapes(g.Ply(), append(g.Shapes(g.Ply()), shapes...))
+			}
+			continue
+		}
+		token = moveNumberPattern.ReplaceAllString(token, "")
+		switch Result(token) {
+		case "":
+			continue
+		case WhiteWins, BlackWins, Draw, Ongoing:
+			result = Result(token)
+			continue
+		}
+		if g.Over() {
+			// a repetition only ends the game when it is claimed, these players played on
+			if g.reason != Repetition {
+				return g, fmt.Errorf("move %s after the game ended by %s", token, g.reason)
+			}
+			g.result, g.reason = Ongoing, ""
+		}
+		m, err := g.Position().ParseSAN(token)
+		if err != nil {
+			return g, fmt.Errorf("move %d: %w", g.Position().FullMoveNumber, err)
+		}
+		if err := g.Play(m); err != nil {
+			return g, err
+		}
+	}
+
+	if !g.Over() && result != Ongoing {
+		g.result, g.reason = result, Recorded
+	}
+	return g, nil
+}
+
+// movetextTokens splits movetext into moves, move numbers, results and the comments
+// on the main line, each comment as one token in braces. Comments on their own line
+// after a semicolon, variations and numeric annotation glyphs are left out.
+func movetextTokens(movetext string) []string {
+	var tokens []string
+	var current strings.Builder
+	flush := func() {
+		if current.Len() > 0 {
+			tokens = append(tokens, current.String())
+			current.Reset()
+		}
+	}
+
+	depth := 0 // nesting of variations
+	for i := 0; i < len(movetext); i++ {
+		c := movetext[i]
+		switch {
+		case c == '{':
+			flush()
+			end := strings.IndexByte(movetext[i:], '}')
+			if end < 0 {
+				end = len(movetext) - i - 1
+			}
+			if depth == 0 {
+				tokens = append(tokens, movetext[i:i+end+1])
+			}
+			i += end
+		case c == ';':
+			flush()
+			if end := strings.IndexByte(movetext[i:], '\n'); end >= 0 {
+				i += end
+			} else {
+				i = len(movetext)
+			}
+		case c == '(':
+			flush()
+			depth++
+		case c == ')':
+			flush()
+			depth = max(depth-1, 0)
+		case depth > 0:
+			// moves inside a variation are not part of the game
+		case c == '$':
+			flush()
+			for i+1 < len(movetext) && movetext[i+1] >= '0' && movetext[i+1] <= '9' {
+				i++
+			}
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			flush()
+		default:
+			current.WriteByte(c)
+		}
+	}
+	flush()
+	return tokens
+}
@@ -0,0 +1,162 @@
+package game
+
+import (
+	"chess-engine/handlers"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPGNRoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		fen   string
+		moves []string
+	}{
+		{"empty", "", nil},
+		{"opening", "", []string{"e4", "e5", "Nf3", "Nc6", "Bb5", "a6", "Ba4", "Nf6", "O-O"}},
+		{"checkmate", "", []string{"f3", "e5", "g4", "Qh4#"}},
+		{"castling and promotion", "r3k2r/1P6/8/8/8/8/8/R3K2R w KQkq - 0 1", []string{"O-O-O", "O-O", "bxa8=Q+", "Kg7"}},
+		{"black to move", "4k3/8/8/8/8/8/4P3/4K3 b - - 3 20", []string{"Kd7", "e4", "Ke6"}},
+		{"long game", "", []string{
+			"d4", "d5", "c4", "e6", "Nc3", "Nf6", "Bg5", "Be7", "e3", "O-O", "Nf3", "h6", "Bh4", "b6",
+			"cxd5", "Nxd5", "Bxe7", "Qxe7", "Nxd5", "exd5", "Rc1", "Be6", "Qa4", "c5", "Qa3", "Rc8",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := play(t, tt.fen, tt.moves...)
+			text := g.PGN()
+			for _, line := range strings.Split(text, "\n") {
+				if len(line) >= 80 {
+					t.Errorf("line longer than 79 characters: %q", line)
+				}
+			}
+
+			back, err := ParsePGN(text)
+			if err != nil {
+				t.Fatalf("ParsePGN: %v\n%s", err, text)
+			}
+			if !reflect.DeepEqual(back.SAN(), g.SAN()) {
+				t.Errorf("moves = %v, want %v", back.SAN(), g.SAN())
+			}
+			if back.Position().FEN() != g.Position().FEN() {
+				t.Errorf("position = %s, want %s", back.Position().FEN(), g.Position().FEN())
+			}
+			if back.Result() != g.Result() || back.Reason() != g.Reason() {
+				t.Errorf("result = %s (%s), want %s (%s)", back.Result(), back.Reason(), g.Result(), g.Reason())
+			}
+			if back.PGN() != text {
+				t.Errorf("PGN changed on the round trip:\n%s\nwant\n%s", back.PGN(), text)
+			}
+		})
+	}
+}
+
+func TestPGNShapes(t *testing.T) {
+	g := play(t, "", "e4", "e5", "Nf3")
+	e2, _ := handlers.ParseSquare("e2")
+	e4, _ := handlers.ParseSquare("e4")
+	d5, _ := handlers.ParseSquare("d5")
+	g.SetShapes(0, []Shape{{Color: 'G', From: e2, To: e4}})
+	g.SetShapes(2, []Shape{{Color: 'Y', From: d5, To: d5}, {Color: 'R', From: e2, To: d5}})
+
+	back, err := ParsePGN(g.PGN())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for ply := 0; ply <= g.Ply(); ply++ {
+		if !reflect.DeepEqual(back.Shapes(ply), g.Shapes(ply)) {
+			t.Errorf("shapes at ply %d = %v, want %v", ply, back.Shapes(ply), g.Shapes(ply))
+		}
+	}
+}
+
+func TestParsePGN(t *testing.T) {
+	tests := []struct {
+		name   string
+		pgn    string
+		moves  []string
+		result Result
+		reason Reason
+	}{
+		{
+			"comments, variations and glyphs",
+			"1. e4 {best by test} e5 (1... c5 2. Nf3) 2. Nf3 $1 Nc6 ; a comment\n3. Bb5 *",
+			[]string{"e4", "e5", "Nf3", "Nc6", "Bb5"}, Ongoing, "",
+		},
+		{
+			"resignation",
+			"[White \"A\"]\n[Black \"B\"]\n\n1. e4 e5 2. Qh5 Nc6 1-0",
+			[]string{"e4", "e5", "Qh5", "Nc6"}, WhiteWins, Recorded,
+		},
+		{
+			"mate ends the game",
+			"1. f3 e5 2. g4 Qh4# 0-1",
+			[]string{"f3", "e5", "g4", "Qh4#"}, BlackWins, Checkmate,
+		},
+		{
+			"set up position",
+			"[FEN \"4k3/8/8/8/8/8/4P3/4K3 b - - 0 1\"]\n\n1... Kd7 2. e4 1/2-1/2",
+			[]string{"Kd7", "e4"}, Draw, Recorded,
+		},
+		{
+			"repetition",
+			"1. Nf3 Nf6 2. Ng1 Ng8 3. Nf3 Nf6 4. Ng1 Ng8 1/2-1/2",
+			[]string{"Nf3", "Nf6", "Ng1", "Ng8", "Nf3", "Nf6", "Ng1", "Ng8"}, Draw, Repetition,
+		},
+		{
+			"play on after a repetition",
+			"1. Nf3 Nf6 2. Ng1 Ng8 3. Nf3 Nf6 4. Ng1 Ng8 5. e4 *",
+			[]string{"Nf3", "Nf6", "Ng1", "Ng8", "Nf3", "Nf6", "Ng1", "Ng8", "e4"}, Ongoing, "",
+		},
+		{
+			"move numbers without spaces",
+			"1.d4 d5 2.c4 *",
+			[]string{"d4", "d5", "c4"}, Ongoing, "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := ParsePGN(tt.pgn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(g.SAN(), tt.moves) {
+				t.Errorf("moves = %v, want %v", g.SAN(), tt.moves)
+			}
+			if g.Result() != tt.result || g.Reason() != tt.reason {
+				t.Errorf("result = %s (%s), want %s (%s)", g.Result(), g.Reason(), tt.result, tt.reason)
+			}
+		})
+	}
+}
+
+func TestParsePGNErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		pgn   string
+		plies int // the half-moves of the game returned with the error, -1 for no game
+	}{
+		{"bad tag", "[Event]\n\n1. e4 *", -1},
+		{"illegal move", "1. e4 e5 2. Ke3 *", 2},
+		{"move after mate", "1. f3 e5 2. g4 Qh4# 3. a3 0-1", 4},
+		{"invalid FEN", "[FEN \"8/8/8 w - - 0 1\"]\n\n*", -1},
+		{"impossible FEN", "[FEN \"8/8/8/8/8/8/8/4K3 w - - 0 1\"]\n\n*", -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := ParsePGN(tt.pgn)
+			if err == nil {
+				t.Fatalf("ParsePGN succeeded")
+			}
+			plies := -1
+			if g != nil {
+				plies = g.Ply()
+			}
+			if plies != tt.plies {
+				t.Errorf("game returned with %d half-moves, want %d", plies, tt.plies)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package game
+
+import (
+	"chess-engine/handlers"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Shape is an arrow or a highlighted square drawn on the board. In PGN they are kept
+// in comments, arrows as [%cal Ge2e4,Rd1d8] and squares as [%csl Yd4].
+type Shape struct {
+	// Color is one of the PGN color letters: G green, R red, Y yellow or B blue.
+	Color    byte
+	From, To handlers.Square // equal for a highlighted square
+}
+
+// ShapeColors are the colors a shape can have.
+const ShapeColors = "GRYB"
+
+// IsArrow tells an arrow from a highlighted square.
+func (s Shape) IsArrow() bool {
+	return s.From != s.To
+}
+
+// String returns the shape as it is written in a PGN command, e.g. "Ge2e4" or "Rd4".
+func (s Shape) String() string {
+	if s.IsArrow() {
+		return string(s.Color) + s.From.String() + s.To.String()
+	}
+	return string(s.Color) + s.From.String()
+}
+
+// ParseShape reads one entry of a [%cal] or [%csl] command.
+func ParseShape(text string) (Shape, error) {
+	if len(text) != 3 && len(text) != 5 || !strings.ContainsRune(ShapeColors, rune(text[0])) {
+		return Shape{}, fmt.Errorf("invalid shape %q", text)
+	}
+	from, err := handlers.ParseSquare(text[1:3])
+	if err != nil {
+		return Shape{}, err
+	}
+	to := from
+	if len(text) == 5 {
+		if to, err = handlers.ParseSquare(text[3:5]); err != nil {
+			return Shape{}, err
+		}
+	}
+	return Shape{Color: text[0], From: from, To: to}, nil
+}
+
+// Shapes returns the shapes drawn on the position after the given number of half-moves.
+func (g *Game) Shapes(ply int) []Shape {
+	return append([]Shape(nil), g.shapes[ply]...)
+}
+
+// SetShapes replaces the shapes drawn on the position after the given number of half-moves.
+func (g *Game) SetShapes(ply int, shapes []Shape) {
+	if len(shapes) == 0 {
+		delete(g.shapes, ply)
+		return
+	}
+	if g.shapes == nil {
+		g.shapes = map[int][]Shape{}
+	}
+	g.shapes[ply] = append([]Shape(nil), shapes...)
+}
+
+// ToggleShape adds s to the position after the given number of half-moves, or removes
+// it if it is already there. A shape in another color on the same squares is replaced.
+func (g *Game) ToggleShape(ply int, s Shape) {
+	shapes := g.Shapes(ply)
+	for i, existing := range shapes {
+		if existing.From == s.From && existing.To == s.To {
+			shapes = append(shapes[:i], shapes[i+1:]...)
+			if existing.Color != s.Color {
+				shapes = append(shapes, s)
+			}
+			g.SetShapes(ply, shapes)
+			return
+		}
+	}
+	g.SetShapes(ply, append(shapes, s))
+}
+
+// shapeComment writes shapes as a PGN comment, or returns "" when there are none.
+func shapeComment(shapes []Shape) string {
+	var squares, arrows []string
+	for _, s := range shapes {
+		if s.IsArrow() {
+			arrows = append(arrows, s.String())
+		} else {
+			squares = append(squares, s.String())
+		}
+	}
+	var sb strings.Builder
+	if len(squares) > 0 {
+		sb.WriteString("[%csl " + strings.Join(squares, ",") + "]")
+	}
+	if len(arrows) > 0 {
+		sb.WriteString("[%cal " + strings.Join(arrows, ",") + "]")
+	}
+	if sb.Len() == 0 {
+		return ""
+	}
+	return "{" + sb.String() + "}"
+}
+
+var shapeCommandPattern = regexp.MustCompile(`\[%(?:cal|csl)\s+([^\]]*)\]`)
+
+// commentShapes reads the [%cal] and [%csl] commands of a comment. Entries that
+// cannot be read are left out, as the rest of a comment is free text.
+func commentShapes(comment string) []Shape {
+	var shapes []Shape
+	for _, match := range shapeCommandPattern.FindAllStringSubmatch(comment, -1) {
+		for _, entry := range strings.Split(match[1], ",") {
+			if s, err := ParseShape(strings.TrimSpace(entry)); err == nil {
+				shapes = append(shapes, s)
+			}
+		}
+	}
+	return shapes
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"chess-engine/game"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// gameOverText describes the result of the finished game, e.g. "White wins" and "by checkmate".
+func gameOverText(g *game.Game) (string, string) {
+	result := "Draw"
+	switch g.Result() {
+	case game.WhiteWins:
+		result = "White wins"
+	case game.BlackWins:
+		result = "Black wins"
+	}
+	return result, "by " + string(g.Reason())
+}
+
+// showGameOverDialog reports the result of the finished game and offers a new game,
+// a rematch with colors swapped, or saving the game as PGN. The board stays locked
+// until a new game is started.
+func showGameOverDialog() {
+	stopClock()
+	result, reason := gameOverText(currentGame)
+	logger.Info("game over", "result", currentGame.Result(), "reason", currentGame.Reason())
+
+	content := container.NewVBox(
+		widget.NewLabelWithStyle(result, fyne.TextAlignCenter, fyne.TextStyle{Bold: true}),
+		widget.NewLabelWithStyle(reason, fyne.TextAlignCenter, fyne.TextStyle{}),
+	)
+	gameOverDialog := dialog.NewCustomWithoutButtons("Game Over", content, chessWindow)
+
+	newGameButton := widget.NewButton("New Game", func() {
+		gameOverDialog.Hide()
+		startNewGame()
+	})
+	newGameButton.Importance = widget.HighImportance
+	rematchButton := widget.NewButton("Rematch", func() {
+		gameOverDialog.Hide()
+		swapPlayers()
+		startNewGame()
+	})
+	savePGNButton := widget.NewButton("Save PGN", func() {
+		savePGN(currentGame)
+	})
+	closeButton := widget.NewButton("Close", gameOverDialog.Hide)
+
+	gameOverDialog.SetButtons([]fyne.CanvasObject{newGameButton, rematchButton, savePGNButton, closeButton})
+	gameOverDialog.Show()
+}
+
+// savePGN asks for a file name and writes g to it.
+func savePGN(g *game.Game) {
+	saveDialog := dialog.NewFileSave(func(writer fyne.URIWriteCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, chessWindow)
+			return
+		}
+		if writer == nil {
+			return
+		}
+		defer writer.Close()
+		if _, err := writer.Write([]byte(g.PGN())); err != nil {
+			dialog.ShowError(err, chessWindow)
+		}
+	}, chessWindow)
+	saveDialog.SetFileName("game.pgn")
+	saveDialog.SetFilter(pgnFilter)
+	saveDialog.Show()
+}
@@ -1,7 +1,5 @@
 package handlers
 
-import "fmt"
-
 var PieceValues = map[rune]int{
 	'p': 10,
 	'P': 10,
@@ -17,24 +15,7 @@ var PieceValues = map[rune]int{
 	'K': 900,
 }
 
-func bestMove(board [8][8]rune) {
-	fmt.Println("best possible move")
-	for i := 0; i < 8; i++ {
-		for j := 0; j < 8; j++ {
-			if board[i][j] != 0 {
-				fmt.Println("Piece found")
-				if isWhite(board[i][j]) {
-					fmt.Println("White piece found")
-					fmt.Println(GetValue(board[i][j]))
-				}
-			}
-		}
-	}
-
-}
-
 // GetValue returns the value of a given piece
 func GetValue(piece rune) int {
-	fmt.Println("GetValue")
 	return PieceValues[piece]
 }
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"chess-engine/logging"
+	"errors"
+	"log/slog"
+)
+
+// Reasons CheckMove gives for an illegal move.
+var (
+	ErrOffBoard      = errors.New("the move leaves the board")
+	ErrNoPiece       = errors.New("there is no piece on the square")
+	ErrOpponentPiece = errors.New("the piece belongs to the opponent")
+	ErrOwnPiece      = errors.New("a piece of the same color stands on the target square")
+	ErrPromotion     = errors.New("only a pawn reaching the last rank promotes, to a queen, rook, bishop or knight of its color")
+	ErrMustPromote   = errors.New("a pawn reaching the last rank must promote")
+	ErrMovement      = errors.New("the piece does not move that way")
+	ErrBlocked       = errors.New("another piece is in the way")
+	ErrSelfCheck     = errors.New("the move leaves the king in check")
+
+	ErrNoCastlingRight  = errors.New("the king or rook has moved, castling on that side is no longer allowed")
+	ErrCastleInCheck    = errors.New("the king cannot castle out of check")
+	ErrCastleNoRook     = errors.New("the rook is not on its square")
+	ErrCastleBlocked    = errors.New("pieces stand between the king and the rook")
+	ErrCastleUnderCheck = errors.New("the king cannot pass through or land on an attacked square")
+)
+
+// CheckMove returns nil if m is legal, or why it is not. Rejections are recorded
+// in the trace.
+func (p Position) CheckMove(m Move) error {
+	if p.IsLegal(m) {
+		return nil
+	}
+	err := p.rejection(m)
+	traceRejection(p, m.String(), err)
+	return err
+}
+
+func traceRejection(p Position, move string, reason error) {
+	if logging.Tracing() {
+		logging.Trace(logging.Rules, "move rejected",
+			slog.String("fen", p.FEN()), slog.String("move", move), slog.String("reason", reason.Error()))
+	}
+}
+
+// rejection finds why the illegal move m is rejected, going through the rules in
+// the order IsLegal applies them.
+func (p Position) rejection(m Move) error {
+	if !m.From.Valid() || !m.To.Valid() {
+		return ErrOffBoard
+	}
+	piece := p.PieceAt(m.From)
+	switch {
+	case piece == 0:
+		return ErrNoPiece
+	case isWhite(piece) != p.WhiteTurn:
+		return ErrOpponentPiece
+	}
+	if target := p.PieceAt(m.To); target != 0 && isWhite(target) == p.WhiteTurn {
+		return ErrOwnPiece
+	}
+
+	isPawn := piece == 'P' || piece == 'p'
+	lastRank := 7
+	if piece == 'P' {
+		lastRank = 0
+	}
+	if m.Promotion != 0 && (!isPawn || m.To.Row != lastRank || isWhite(m.Promotion) != p.WhiteTurn) {
+		return ErrPromotion
+	}
+
+	if (piece == 'K' || piece == 'k') && m.From.Row == m.To.Row && abs(m.To.Col-m.From.Col) == 2 {
+		if err := castlingError(p.Board, m.From, m.To, &p.Castling); err != nil {
+			return err
+		}
+	} else if !p.isEnPassant(m) {
+		// the movement is checked as if a pawn reaching the last rank became a queen,
+		// and the promotion piece after it
+		queen := 'Q'
+		if !IsValidMove(p.Board, piece, m.From, m.To, &queen, nil) {
+			if slides(piece) && inLine(m) {
+				return ErrBlocked
+			}
+			return ErrMovement
+		}
+		if isPawn && m.To.Row == lastRank {
+			if m.Promotion == 0 {
+				return ErrMustPromote
+			}
+			if !IsValidMove(p.Board, piece, m.From, m.To, &m.Promotion, nil) {
+				return ErrPromotion
+			}
+		}
+	}
+	return ErrSelfCheck
+}
+
+func slides(piece rune) bool {
+	switch piece {
+	case 'R', 'r', 'B', 'b', 'Q', 'q':
+		return true
+	}
+	return false
+}
+
+// inLine reports whether the move runs along a rank, file or diagonal, as a
+// queen's would; a rook or bishop that can only be blocked then has the right direction.
+func inLine(m Move) bool {
+	return m.From.Row == m.To.Row || m.From.Col == m.To.Col || abs(m.From.Row-m.To.Row) == abs(m.From.Col-m.To.Col)
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import "unicode"
+
+// HasMatingMaterial reports whether the given side has enough pieces to ever deliver mate.
+// A lone king, or a king with a single bishop or knight, cannot.
+func HasMatingMaterial(board [8][8]rune, white bool) bool {
+	minors := 0
+	for i := 0; i < 8; i++ {
+		for j := 0; j < 8; j++ {
+			piece := board[i][j]
+			if piece == 0 || isWhite(piece) != white {
+				continue
+			}
+			switch piece {
+			case 'K', 'k':
+			case 'B', 'b', 'N', 'n':
+				minors++
+			default:
+				return true
+			}
+		}
+	}
+	return minors > 1
+}
+
+// initialCount is how many pieces of each kind a side starts with.
+var initialCount = map[rune]int{'Q': 1, 'R': 2, 'B': 2, 'N': 2, 'P': 8}
+
+// Material returns the value of the given side's pieces in pawns, kings left out.
+func Material(board [8][8]rune, white bool) int {
+	total := 0
+	for i := 0; i < 8; i++ {
+		for j := 0; j < 8; j++ {
+			piece := board[i][j]
+			if piece != 0 && isWhite(piece) == white && piece != 'K' && piece != 'k' {
+				total += PieceValues[piece] / PieceValues['P']
+			}
+		}
+	}
+	return total
+}
+
+// MissingPieces returns how many pieces of each kind ('Q', 'R', 'B', 'N', 'P') the
+// given side has lost compared to the initial set. A piece beyond the initial number
+// came from a promotion and counts against the pawns instead.
+func MissingPieces(board [8][8]rune, white bool) map[rune]int {
+	count := map[rune]int{}
+	for i := 0; i < 8; i++ {
+		for j := 0; j < 8; j++ {
+			if piece := board[i][j]; piece != 0 && isWhite(piece) == white {
+				count[unicode.ToUpper(piece)]++
+			}
+		}
+	}
+
+	missing := map[rune]int{}
+	promoted := 0
+	for kind, initial := range initialCount {
+		if kind == 'P' {
+			continue
+		}
+		missing[kind] = max(initial-count[kind], 0)
+		promoted += max(count[kind]-initial, 0)
+	}
+	missing['P'] = max(initialCount['P']-count['P']-promoted, 0)
+	return missing
+}
@@ -1,6 +1,6 @@
 package handlers
 
-import "fmt"
+import "strings"
 
 type CastlingRights struct {
 	WhiteKingSide  bool
@@ -9,17 +9,56 @@ type CastlingRights struct {
 	BlackQueenSide bool
 }
 
-var initialPositions = map[string]bool{
-	"e1": true, // White King
-	"e8": true, // Black King
-	"a1": true, // White Queen Rook
-	"h1": true, // White King Rook
-	"a8": true, // Black Queen Rook
-	"h8": true, // Black King Rook
+// NewCastlingRights parses the castling field of a FEN string ("KQkq", "Kq", "-", ...).
+func NewCastlingRights(field string) CastlingRights {
+	return CastlingRights{
+		WhiteKingSide:  strings.ContainsRune(field, 'K'),
+		WhiteQueenSide: strings.ContainsRune(field, 'Q'),
+		BlackKingSide:  strings.ContainsRune(field, 'k'),
+		BlackQueenSide: strings.ContainsRune(field, 'q'),
+	}
+}
+
+// String returns the castling rights in FEN notation.
+func (c CastlingRights) String() string {
+	field := ""
+	if c.WhiteKingSide {
+		field += "K"
+	}
+	if c.WhiteQueenSide {
+		field += "Q"
+	}
+	if c.BlackKingSide {
+		field += "k"
+	}
+	if c.BlackQueenSide {
+		field += "q"
+	}
+	if field == "" {
+		return "-"
+	}
+	return field
+}
+
+// initialPositions are the squares the kings and rooks start on. Castling rights
+// depend on them: a right is lost once its king or rook leaves or is captured there.
+var initialPositions = struct {
+	whiteKing, whiteKingRook, whiteQueenRook Square
+	blackKing, blackKingRook, blackQueenRook Square
+}{
+	whiteKing: Square{Row: 7, Col: 4}, whiteKingRook: Square{Row: 7, Col: 7}, whiteQueenRook: Square{Row: 7, Col: 0},
+	blackKing: Square{Row: 0, Col: 4}, blackKingRook: Square{Row: 0, Col: 7}, blackQueenRook: Square{Row: 0, Col: 0},
 }
 
-func UpdateCastlingRights(board [8][8]rune, fromRow, fromCol int, castlingRights *CastlingRights) {
-	piece := board[fromRow][fromCol]
+// UpdateCastlingRights must be called before the move is applied to the board.
+// Moving the king or a rook, or capturing a rook on its starting square, removes the matching rights.
+func UpdateCastlingRights(board [8][8]rune, from, to Square, castlingRights *CastlingRights) {
+	piece := board[from.Row][from.Col]
+
+	// a rook captured on its starting square takes the matching right with it
+	if target := board[to.Row][to.Col]; target == 'R' || target == 'r' {
+		clearRookRight(to, castlingRights)
+	}
 
 	switch piece {
 	case 'K':
@@ -28,30 +67,44 @@ func UpdateCastlingRights(board [8][8]rune, fromRow, fromCol int, castlingRights
 	case 'k':
 		castlingRights.BlackKingSide = false
 		castlingRights.BlackQueenSide = false
-	case 'R':
-		if fromRow == 7 && fromCol == 0 {
-			castlingRights.WhiteQueenSide = false
-		} else if fromRow == 7 && fromCol == 7 {
-			castlingRights.WhiteKingSide = false
-		}
-	case 'r':
-		if fromRow == 0 && fromCol == 0 {
-			castlingRights.BlackQueenSide = false
-		} else if fromRow == 0 && fromCol == 7 {
-			castlingRights.BlackKingSide = false
-		}
+	case 'R', 'r':
+		clearRookRight(from, castlingRights)
+	}
+}
+
+func clearRookRight(square Square, castlingRights *CastlingRights) {
+	switch square {
+	case initialPositions.whiteQueenRook:
+		castlingRights.WhiteQueenSide = false
+	case initialPositions.whiteKingRook:
+		castlingRights.WhiteKingSide = false
+	case initialPositions.blackQueenRook:
+		castlingRights.BlackQueenSide = false
+	case initialPositions.blackKingRook:
+		castlingRights.BlackKingSide = false
 	}
 }
 
 // IsSquareUnderAttack checks if a square is attacked by any opposing piece
-func IsSquareUnderAttack(board [8][8]rune, row, col int, isWhitePiece bool) bool {
+func IsSquareUnderAttack(board [8][8]rune, square Square, isWhitePiece bool) bool {
 	for i := 0; i < 8; i++ {
 		for j := 0; j < 8; j++ {
 			piece := board[i][j]
 			if piece == 0 || isWhite(piece) == isWhitePiece {
 				continue
 			}
-			if IsValidMove(board, piece, i, j, row, col, nil) {
+			// pawns attack diagonally whether or not the square is occupied, which IsValidMove can't express
+			if piece == 'P' || piece == 'p' {
+				dir := 1
+				if piece == 'P' {
+					dir = -1
+				}
+				if square.Row == i+dir && abs(square.Col-j) == 1 {
+					return true
+				}
+				continue
+			}
+			if IsValidMove(board, piece, Square{Row: i, Col: j}, square, nil, nil) {
 				return true
 			}
 		}
@@ -59,99 +112,135 @@ func IsSquareUnderAttack(board [8][8]rune, row, col int, isWhitePiece bool) bool
 	return false
 }
 
-func IsInCheck(board [8][8]rune, isWhiteKing bool, kingRow, kingCol int) bool {
-	return IsSquareUnderAttack(board, kingRow, kingCol, isWhiteKing)
+func IsInCheck(board [8][8]rune, isWhiteKing bool, king Square) bool {
+	return IsSquareUnderAttack(board, king, isWhiteKing)
+}
+
+// IsCastleable reports whether the king on from may castle to to.
+// A nil castlingRights never allows castling, which keeps attack checks from recursing into it.
+func IsCastleable(board [8][8]rune, from, to Square, castlingRights *CastlingRights) bool {
+	return castlingError(board, from, to, castlingRights) == nil
 }
 
-func IsCastleable(board [8][8]rune, fromRow, fromCol, toRow, toCol int) bool {
-	piece := board[fromRow][fromCol]
+// castlingError returns why the king on from may not castle to to, or nil.
+func castlingError(board [8][8]rune, from, to Square, castlingRights *CastlingRights) error {
+	piece := board[from.Row][from.Col]
 
-	if (piece != 'K' && piece != 'k') || abs(fromCol-toCol) != 2 || fromRow != toRow {
-		return false
+	if castlingRights == nil || (piece != 'K' && piece != 'k') || abs(from.Col-to.Col) != 2 || from.Row != to.Row {
+		return ErrMovement
 	}
 
-	isKingSide := toCol > fromCol
-	row := fromRow
+	isKingSide := to.Col > from.Col
+	row := from.Row
 	isWhiteKing := piece == 'K'
 
-	if IsInCheck(board, isWhiteKing, row, fromCol) {
-		return false
+	king, kingRook, queenRook := initialPositions.whiteKing, initialPositions.whiteKingRook, initialPositions.whiteQueenRook
+	if !isWhiteKing {
+		king, kingRook, queenRook = initialPositions.blackKing, initialPositions.blackKingRook, initialPositions.blackQueenRook
+	}
+	if from != king {
+		return ErrMovement
+	}
+
+	switch {
+	case isWhiteKing && isKingSide && !castlingRights.WhiteKingSide,
+		isWhiteKing && !isKingSide && !castlingRights.WhiteQueenSide,
+		!isWhiteKing && isKingSide && !castlingRights.BlackKingSide,
+		!isWhiteKing && !isKingSide && !castlingRights.BlackQueenSide:
+		return ErrNoCastlingRight
+	}
+
+	if IsInCheck(board, isWhiteKing, from) {
+		return ErrCastleInCheck
 	}
 
+	rookPiece := 'R'
+	if !isWhiteKing {
+		rookPiece = 'r'
+	}
 	if isKingSide {
-		rookCol := 7
-		if (isWhiteKing && board[7][rookCol] != 'R') || (!isWhiteKing && board[0][rookCol] != 'r') {
-			return false
+		if board[kingRook.Row][kingRook.Col] != rookPiece {
+			return ErrCastleNoRook
 		}
-		for col := fromCol + 1; col < rookCol; col++ {
-			if board[row][col] != 0 || IsSquareUnderAttack(board, row, col, isWhiteKing) {
-				return false
+		for col := from.Col + 1; col < kingRook.Col; col++ {
+			if board[row][col] != 0 {
+				return ErrCastleBlocked
+			}
+			if IsSquareUnderAttack(board, Square{Row: row, Col: col}, isWhiteKing) {
+				return ErrCastleUnderCheck
 			}
 		}
 	} else {
-		rookCol := 0
-		if (isWhiteKing && board[7][rookCol] != 'R') || (!isWhiteKing && board[0][rookCol] != 'r') {
-			return false
+		if board[queenRook.Row][queenRook.Col] != rookPiece {
+			return ErrCastleNoRook
 		}
-		for col := fromCol - 1; col > rookCol; col-- {
-			if board[row][col] != 0 || IsSquareUnderAttack(board, row, col, isWhiteKing) {
-				return false
+		for col := from.Col - 1; col > queenRook.Col; col-- {
+			if board[row][col] != 0 {
+				return ErrCastleBlocked
+			}
+		}
+		// the b-file square only has to be empty, the king never crosses it
+		for col := from.Col - 1; col >= to.Col; col-- {
+			if IsSquareUnderAttack(board, Square{Row: row, Col: col}, isWhiteKing) {
+				return ErrCastleUnderCheck
 			}
 		}
 	}
-	fmt.Println("Castleable")
-	return true
+	return nil
 }
 
-func IsValidMove(board [8][8]rune, piece rune, fromRow, fromCol, toRow, toCol int, promotionPiece *rune) bool {
-	if toRow < 0 || toRow >= 8 || toCol < 0 || toCol >= 8 {
+// IsValidMove checks the movement rules of piece. Castling is only considered when castlingRights is not nil.
+func IsValidMove(board [8][8]rune, piece rune, from, to Square, promotionPiece *rune, castlingRights *CastlingRights) bool {
+	if !to.Valid() {
 		return false
 	}
 
-	if board[toRow][toCol] != 0 {
-		if isWhite(piece) == isWhite(board[toRow][toCol]) {
+	target := board[to.Row][to.Col]
+	if target != 0 {
+		if isWhite(piece) == isWhite(target) {
 			return false
 		}
 	}
 
+	rows, cols := abs(from.Row-to.Row), abs(from.Col-to.Col)
 	switch piece {
 	case 'P':
-		if fromCol == toCol && board[toRow][toCol] == 0 {
-			if toRow == fromRow-1 || (fromRow == 6 && toRow == 4 && board[5][toCol] == 0) {
-				return handlePawnPromotion(toRow, promotionPiece, true)
+		if from.Col == to.Col && target == 0 {
+			if to.Row == from.Row-1 || (from.Row == 6 && to.Row == 4 && board[5][to.Col] == 0) {
+				return handlePawnPromotion(to, promotionPiece, true)
 			}
-		} else if abs(fromCol-toCol) == 1 && toRow == fromRow-1 && !isWhite(board[toRow][toCol]) {
-			return handlePawnPromotion(toRow, promotionPiece, true)
+		} else if cols == 1 && to.Row == from.Row-1 && target != 0 && !isWhite(target) {
+			return handlePawnPromotion(to, promotionPiece, true)
 		}
 	case 'p':
-		if fromCol == toCol && board[toRow][toCol] == 0 {
-			if toRow == fromRow+1 || (fromRow == 1 && toRow == 3 && board[2][toCol] == 0) {
-				return handlePawnPromotion(toRow, promotionPiece, false)
+		if from.Col == to.Col && target == 0 {
+			if to.Row == from.Row+1 || (from.Row == 1 && to.Row == 3 && board[2][to.Col] == 0) {
+				return handlePawnPromotion(to, promotionPiece, false)
 			}
-		} else if abs(fromCol-toCol) == 1 && toRow == fromRow+1 && isWhite(board[toRow][toCol]) {
-			return handlePawnPromotion(toRow, promotionPiece, false)
+		} else if cols == 1 && to.Row == from.Row+1 && isWhite(target) {
+			return handlePawnPromotion(to, promotionPiece, false)
 		}
 	case 'R', 'r':
-		if fromRow == toRow || fromCol == toCol {
-			return clearPath(board, fromRow, fromCol, toRow, toCol)
+		if from.Row == to.Row || from.Col == to.Col {
+			return clearPath(board, from, to)
 		}
 	case 'N', 'n':
-		if (abs(fromRow-toRow) == 2 && abs(fromCol-toCol) == 1) || (abs(fromRow-toRow) == 1 && abs(fromCol-toCol) == 2) {
+		if (rows == 2 && cols == 1) || (rows == 1 && cols == 2) {
 			return true
 		}
 	case 'B', 'b':
-		if abs(fromRow-toRow) == abs(fromCol-toCol) {
-			return clearPath(board, fromRow, fromCol, toRow, toCol)
+		if rows == cols {
+			return clearPath(board, from, to)
 		}
 	case 'Q', 'q':
-		if fromRow == toRow || fromCol == toCol || abs(fromRow-toRow) == abs(fromCol-toCol) {
-			return clearPath(board, fromRow, fromCol, toRow, toCol)
+		if from.Row == to.Row || from.Col == to.Col || rows == cols {
+			return clearPath(board, from, to)
 		}
 	case 'K', 'k':
-		if abs(fromRow-toRow) <= 1 && abs(fromCol-toCol) <= 1 {
+		if rows <= 1 && cols <= 1 {
 			return true
 		}
-		if IsCastleable(board, fromRow, fromCol, toRow, toCol) {
+		if IsCastleable(board, from, to, castlingRights) {
 			return true
 		}
 
@@ -160,24 +249,22 @@ func IsValidMove(board [8][8]rune, piece rune, fromRow, fromCol, toRow, toCol in
 	return false
 }
 
-func handlePawnPromotion(toRow int, promotionPiece *rune, isWhite bool) bool {
-	if (isWhite && toRow == 0) || (!isWhite && toRow == 7) {
+func handlePawnPromotion(to Square, promotionPiece *rune, isWhite bool) bool {
+	if (isWhite && to.Row == 0) || (!isWhite && to.Row == 7) {
 		if promotionPiece != nil && (*promotionPiece == 'Q' || *promotionPiece == 'R' || *promotionPiece == 'B' || *promotionPiece == 'N' || *promotionPiece == 'q' || *promotionPiece == 'r' || *promotionPiece == 'b' || *promotionPiece == 'n') {
-			fmt.Println("Pawn promotion")
 			return true
 		}
-		fmt.Println("Invalid or missing promotion piece.")
 		return false
 	}
 	return true
 }
 
-func clearPath(board [8][8]rune, fromRow, fromCol, toRow, toCol int) bool {
-	rowStep := sign(toRow - fromRow)
-	colStep := sign(toCol - fromCol)
+func clearPath(board [8][8]rune, from, to Square) bool {
+	rowStep := sign(to.Row - from.Row)
+	colStep := sign(to.Col - from.Col)
 
-	row, col := fromRow+rowStep, fromCol+colStep
-	for row != toRow || col != toCol {
+	row, col := from.Row+rowStep, from.Col+colStep
+	for row != to.Row || col != to.Col {
 		if board[row][col] != 0 {
 			return false
 		}
@@ -0,0 +1,27 @@
+package handlers
+
+// Perft counts the leaf positions of the legal move tree depth half-moves deep,
+// the standard check of a move generator against known totals.
+func (p Position) Perft(depth int) int64 {
+	if depth <= 0 {
+		return 1
+	}
+	moves := p.LegalMoves()
+	if depth == 1 {
+		return int64(len(moves))
+	}
+	var nodes int64
+	for _, m := range moves {
+		nodes += p.Play(m).Perft(depth - 1)
+	}
+	return nodes
+}
+
+// Divide is Perft split by the first move, for finding where a count goes wrong.
+func (p Position) Divide(depth int) map[Move]int64 {
+	counts := map[Move]int64{}
+	for _, m := range p.LegalMoves() {
+		counts[m] = p.Play(m).Perft(depth - 1)
+	}
+	return counts
+}
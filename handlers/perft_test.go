@@ -0,0 +1,45 @@
+package handlers
+
+import "testing"
+
+func TestPerft(t *testing.T) {
+	tests := []struct {
+		name  string
+		fen   string
+		nodes []int64 // by depth, from 1
+	}{
+		{"start", StartFEN, []int64{20, 400, 8902, 197281}},
+		{"kiwipete", "r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1", []int64{48, 2039, 97862}},
+		{"rook endgame", "8/2p5/3p4/KP5r/1R3p1k/8/4P1P1/8 w - - 0 1", []int64{14, 191, 2812, 43238}},
+		{"promotions", "r3k2r/Pppp1ppp/1b3nbN/nP6/BBP1P3/q4N2/Pp1P2PP/R2Q1RK1 w kq - 0 1", []int64{6, 264, 9467}},
+		{"discovered checks", "rnbq1k1r/pp1Pbppp/2p5/8/2B5/8/PPP1NnPP/RNBQK2R w KQ - 1 8", []int64{44, 1486, 62379}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pos, err := ParseFEN(tt.fen)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, want := range tt.nodes {
+				if got := pos.Perft(i + 1); got != want {
+					t.Errorf("Perft(%d) = %d, want %d", i+1, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDivide(t *testing.T) {
+	pos, _ := ParseFEN("r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1")
+	counts := pos.Divide(2)
+	if len(counts) != 48 {
+		t.Errorf("Divide(2) has %d moves, want 48", len(counts))
+	}
+	var total int64
+	for _, n := range counts {
+		total += n
+	}
+	if total != 2039 {
+		t.Errorf("Divide(2) adds up to %d, want 2039", total)
+	}
+}
@@ -0,0 +1,373 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Move is a single move from one square to another.
+// Promotion holds the piece a pawn turns into, in the mover's case ('Q' or 'q'), and is 0 otherwise.
+type Move struct {
+	From, To  Square
+	Promotion rune
+}
+
+// Position is the full game state described by a FEN string.
+type Position struct {
+	Board          [8][8]rune
+	WhiteTurn      bool
+	Castling       CastlingRights
+	EnPassant      Square // NoSquare when no en passant capture is possible
+	HalfMoveClock  int
+	FullMoveNumber int
+}
+
+const StartFEN = "rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1"
+
+// StartPosition returns the standard initial position.
+func StartPosition() Position {
+	pos, _ := ParseFEN(StartFEN)
+	return pos
+}
+
+// ParseFEN reads a FEN string. Missing trailing fields fall back to "w - - 0 1".
+func ParseFEN(fen string) (Position, error) {
+	fields := strings.Fields(fen)
+	if len(fields) == 0 {
+		return Position{}, errors.New("empty FEN")
+	}
+
+	pos := Position{WhiteTurn: true, EnPassant: NoSquare, FullMoveNumber: 1}
+
+	rows := strings.Split(fields[0], "/")
+	if len(rows) != 8 {
+		return Position{}, fmt.Errorf("FEN board has %d ranks, want 8", len(rows))
+	}
+	for rowIdx, row := range rows {
+		colIdx := 0
+		for _, char := range row {
+			if char >= '1' && char <= '8' {
+				colIdx += int(char - '0')
+				continue
+			}
+			if !strings.ContainsRune("PNBRQKpnbrqk", char) {
+				return Position{}, fmt.Errorf("invalid piece %q in FEN", char)
+			}
+			if colIdx >= 8 {
+				return Position{}, fmt.Errorf("FEN rank %d has more than 8 squares", 8-rowIdx)
+			}
+			pos.Board[rowIdx][colIdx] = char
+			colIdx++
+		}
+		if colIdx != 8 {
+			return Position{}, fmt.Errorf("FEN rank %d does not have 8 squares", 8-rowIdx)
+		}
+	}
+
+	if len(fields) > 1 {
+		switch fields[1] {
+		case "w":
+		case "b":
+			pos.WhiteTurn = false
+		default:
+			return Position{}, fmt.Errorf("invalid side to move %q", fields[1])
+		}
+	}
+	if len(fields) > 2 {
+		for _, char := range fields[2] {
+			if !strings.ContainsRune("KQkq-", char) {
+				return Position{}, fmt.Errorf("invalid castling field %q", fields[2])
+			}
+		}
+		pos.Castling = NewCastlingRights(fields[2])
+	}
+	if len(fields) > 3 && fields[3] != "-" {
+		ep, err := ParseSquare(fields[3])
+		if err != nil || (ep.Rank() != 3 && ep.Rank() != 6) {
+			return Position{}, fmt.Errorf("invalid en passant square %q", fields[3])
+		}
+		pos.EnPassant = ep
+	}
+	if len(fields) > 4 {
+		if _, err := fmt.Sscan(fields[4], &pos.HalfMoveClock); err != nil {
+			return Position{}, fmt.Errorf("invalid halfmove clock %q", fields[4])
+		}
+	}
+	if len(fields) > 5 {
+		if _, err := fmt.Sscan(fields[5], &pos.FullMoveNumber); err != nil {
+			return Position{}, fmt.Errorf("invalid fullmove number %q", fields[5])
+		}
+	}
+	return pos, nil
+}
+
+// FEN returns the position in Forsyth-Edwards Notation.
+func (p Position) FEN() string {
+	turn := "w"
+	if !p.WhiteTurn {
+		turn = "b"
+	}
+	return fmt.Sprintf("%s %s %s %s %d %d", p.placement(), turn, p.Castling, p.EnPassant, p.HalfMoveClock, p.FullMoveNumber)
+}
+
+// placement returns the piece placement field of the FEN.
+func (p Position) placement() string {
+	var sb strings.Builder
+	for i := 0; i < 8; i++ {
+		empty := 0
+		for j := 0; j < 8; j++ {
+			if p.Board[i][j] == 0 {
+				empty++
+				continue
+			}
+			if empty > 0 {
+				sb.WriteByte(byte('0' + empty))
+				empty = 0
+			}
+			sb.WriteRune(p.Board[i][j])
+		}
+		if empty > 0 {
+			sb.WriteByte(byte('0' + empty))
+		}
+		if i < 7 {
+			sb.WriteByte('/')
+		}
+	}
+	return sb.String()
+}
+
+// Key identifies the position for repetition detection: placement, side to move, castling and en passant.
+// The en passant square only counts when a pawn can take there.
+func (p Position) Key() string {
+	if p.EnPassant.Valid() && !p.canTakeEnPassant() {
+		p.EnPassant = NoSquare
+	}
+	fields := strings.Fields(p.FEN())
+	return strings.Join(fields[:4], " ")
+}
+
+// canTakeEnPassant reports whether the side to move has a legal en passant capture.
+func (p Position) canTakeEnPassant() bool {
+	ep := p.EnPassant
+	fromRow := ep.Row + 1
+	if !p.WhiteTurn {
+		fromRow = ep.Row - 1
+	}
+	for _, fromCol := range []int{ep.Col - 1, ep.Col + 1} {
+		if p.IsLegal(Move{From: Square{Row: fromRow, Col: fromCol}, To: ep}) {
+			return true
+		}
+	}
+	return false
+}
+
+// PieceAt returns the piece on s, or 0 if the square is empty.
+func (p Position) PieceAt(s Square) rune {
+	return p.Board[s.Row][s.Col]
+}
+
+// KingSquare returns where the king of the given color stands, or NoSquare if it is missing.
+func (p Position) KingSquare(white bool) Square {
+	king := 'k'
+	if white {
+		king = 'K'
+	}
+	for i := 0; i < 8; i++ {
+		for j := 0; j < 8; j++ {
+			if p.Board[i][j] == king {
+				return Square{Row: i, Col: j}
+			}
+		}
+	}
+	return NoSquare
+}
+
+// InCheck reports whether the side to move is in check.
+func (p Position) InCheck() bool {
+	king := p.KingSquare(p.WhiteTurn)
+	return king.Valid() && IsInCheck(p.Board, p.WhiteTurn, king)
+}
+
+// Play applies m without checking it, updating castling rights, en passant and the move counters.
+func (p Position) Play(m Move) Position {
+	next := p
+	board := &next.Board
+	piece := board[m.From.Row][m.From.Col]
+	target := board[m.To.Row][m.To.Col]
+	isPawn := piece == 'P' || piece == 'p'
+
+	UpdateCastlingRights(p.Board, m.From, m.To, &next.Castling)
+
+	// a pawn moving diagonally onto an empty square is an en passant capture
+	if isPawn && m.From.Col != m.To.Col && target == 0 {
+		board[m.From.Row][m.To.Col] = 0
+	}
+
+	if (piece == 'K' || piece == 'k') && abs(m.To.Col-m.From.Col) == 2 {
+		rookFromCol, rookToCol := 0, 3
+		if m.To.Col > m.From.Col {
+			rookFromCol, rookToCol = 7, 5
+		}
+		board[m.From.Row][rookToCol] = board[m.From.Row][rookFromCol]
+		board[m.From.Row][rookFromCol] = 0
+	}
+
+	board[m.To.Row][m.To.Col] = piece
+	if m.Promotion != 0 {
+		board[m.To.Row][m.To.Col] = m.Promotion
+	}
+	board[m.From.Row][m.From.Col] = 0
+
+	next.EnPassant = NoSquare
+	if isPawn && abs(m.To.Row-m.From.Row) == 2 {
+		next.EnPassant = Square{Row: (m.From.Row + m.To.Row) / 2, Col: m.From.Col}
+	}
+
+	if isPawn || target != 0 {
+		next.HalfMoveClock = 0
+	} else {
+		next.HalfMoveClock++
+	}
+	if !p.WhiteTurn {
+		next.FullMoveNumber++
+	}
+	next.WhiteTurn = !p.WhiteTurn
+	return next
+}
+
+// IsLegal reports whether m can be played by the side to move without leaving its own king in check.
+func (p Position) IsLegal(m Move) bool {
+	if !m.From.Valid() || !m.To.Valid() {
+		return false
+	}
+	piece := p.PieceAt(m.From)
+	if piece == 0 || isWhite(piece) != p.WhiteTurn {
+		return false
+	}
+
+	isPawn := piece == 'P' || piece == 'p'
+	lastRank := 7
+	if piece == 'P' {
+		lastRank = 0
+	}
+	if m.Promotion != 0 && (!isPawn || m.To.Row != lastRank || isWhite(m.Promotion) != p.WhiteTurn) {
+		return false
+	}
+
+	// IsValidMove only sees the board, so en passant captures are recognised separately
+	if !p.isEnPassant(m) {
+		var promotionPiece *rune
+		if m.Promotion != 0 {
+			promotionPiece = &m.Promotion
+		}
+		if !IsValidMove(p.Board, piece, m.From, m.To, promotionPiece, &p.Castling) {
+			return false
+		}
+	}
+
+	after := p.Play(m)
+	king := after.KingSquare(p.WhiteTurn)
+	return !king.Valid() || !IsSquareUnderAttack(after.Board, king, p.WhiteTurn)
+}
+
+func (p Position) isEnPassant(m Move) bool {
+	piece := p.PieceAt(m.From)
+	if !p.EnPassant.Valid() || m.To != p.EnPassant || abs(m.From.Col-m.To.Col) != 1 {
+		return false
+	}
+	switch piece {
+	case 'P':
+		return m.To.Row == m.From.Row-1 && p.Board[m.From.Row][m.To.Col] == 'p'
+	case 'p':
+		return m.To.Row == m.From.Row+1 && p.Board[m.From.Row][m.To.Col] == 'P'
+	}
+	return false
+}
+
+// LegalMoves lists every legal move for the side to move, with one entry per promotion piece.
+func (p Position) LegalMoves() []Move {
+	var moves []Move
+	for i := 0; i < 8; i++ {
+		for j := 0; j < 8; j++ {
+			piece := p.Board[i][j]
+			if piece == 0 || isWhite(piece) != p.WhiteTurn {
+				continue
+			}
+			from := Square{Row: i, Col: j}
+			for _, target := range candidateTargets(p.Board, piece, from) {
+				m := Move{From: from, To: target}
+				if (piece == 'P' && m.To.Row == 0) || (piece == 'p' && m.To.Row == 7) {
+					for _, promotion := range "QRBN" {
+						if piece == 'p' {
+							promotion += 'a' - 'A'
+						}
+						m.Promotion = promotion
+						if p.IsLegal(m) {
+							moves = append(moves, m)
+						}
+					}
+					continue
+				}
+				if p.IsLegal(m) {
+					moves = append(moves, m)
+				}
+			}
+		}
+	}
+	return moves
+}
+
+var knightOffsets = [][2]int{{-2, -1}, {-2, 1}, {-1, -2}, {-1, 2}, {1, -2}, {1, 2}, {2, -1}, {2, 1}}
+var kingOffsets = [][2]int{{-1, -1}, {-1, 0}, {-1, 1}, {0, -1}, {0, 1}, {1, -1}, {1, 0}, {1, 1}}
+
+// candidateTargets narrows the squares a piece could reach by its movement pattern.
+// The rules themselves stay in IsValidMove, which every candidate is checked against.
+func candidateTargets(board [8][8]rune, piece rune, from Square) []Square {
+	var targets []Square
+	row, col := from.Row, from.Col
+	add := func(r, c int) {
+		if r >= 0 && r < 8 && c >= 0 && c < 8 {
+			targets = append(targets, Square{Row: r, Col: c})
+		}
+	}
+	slide := func(directions [][2]int) {
+		for _, d := range directions {
+			for r, c := row+d[0], col+d[1]; r >= 0 && r < 8 && c >= 0 && c < 8; r, c = r+d[0], c+d[1] {
+				targets = append(targets, Square{Row: r, Col: c})
+				if board[r][c] != 0 {
+					break
+				}
+			}
+		}
+	}
+
+	switch piece {
+	case 'P', 'p':
+		dir := 1
+		if piece == 'P' {
+			dir = -1
+		}
+		add(row+dir, col)
+		add(row+2*dir, col)
+		add(row+dir, col-1)
+		add(row+dir, col+1)
+	case 'N', 'n':
+		for _, o := range knightOffsets {
+			add(row+o[0], col+o[1])
+		}
+	case 'K', 'k':
+		for _, o := range kingOffsets {
+			add(row+o[0], col+o[1])
+		}
+		add(row, col-2)
+		add(row, col+2)
+	case 'R', 'r':
+		slide([][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}})
+	case 'B', 'b':
+		slide([][2]int{{-1, -1}, {-1, 1}, {1, -1}, {1, 1}})
+	case 'Q', 'q':
+		slide(kingOffsets)
+	}
+	return targets
+}
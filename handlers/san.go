@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// SAN returns m in Standard Algebraic Notation ("Nbd7", "exd5", "O-O", "e8=Q+").
+// m must be legal in p.
+func (p Position) SAN(m Move) string {
+	san := p.sanWithoutCheck(m)
+	after := p.Play(m)
+	if after.InCheck() {
+		if len(after.LegalMoves()) == 0 {
+			return san + "#"
+		}
+		return san + "+"
+	}
+	return san
+}
+
+// ParseSAN finds the legal move written as san. It accepts the check and annotation
+// suffixes (+, #, !, ?), castling written with zeros, and promotions without "=".
+func (p Position) ParseSAN(san string) (Move, error) {
+	want := normalizeSAN(san)
+	if want == "" {
+		return Move{}, errors.New("empty move")
+	}
+	var found []Move
+	for _, m := range p.LegalMoves() {
+		if normalizeSAN(p.sanWithoutCheck(m)) == want {
+			found = append(found, m)
+		}
+	}
+	switch len(found) {
+	case 0:
+		traceRejection(p, san, errors.New("no legal move is written so"))
+		return Move{}, fmt.Errorf("%s is not a legal move", san)
+	case 1:
+		return found[0], nil
+	}
+	err := fmt.Errorf("%s is ambiguous", san)
+	traceRejection(p, san, err)
+	return Move{}, err
+}
+
+// normalizeSAN drops the parts of a SAN move that writers disagree on.
+func normalizeSAN(san string) string {
+	san = strings.TrimRight(san, "+#!?")
+	san = strings.ReplaceAll(san, "0", "O")
+	return strings.NewReplacer("x", "", "=", "", ":", "").Replace(san)
+}
+
+func (p Position) sanWithoutCheck(m Move) string {
+	piece := p.PieceAt(m.From)
+	kind := unicode.ToUpper(piece)
+	var sb strings.Builder
+
+	switch {
+	case kind == 'K' && m.To.Col-m.From.Col == 2:
+		sb.WriteString("O-O")
+	case kind == 'K' && m.From.Col-m.To.Col == 2:
+		sb.WriteString("O-O-O")
+	case kind == 'P':
+		if m.From.Col != m.To.Col {
+			sb.WriteByte(m.From.File())
+			sb.WriteByte('x')
+		}
+		sb.WriteString(m.To.String())
+		if m.Promotion != 0 {
+			sb.WriteByte('=')
+			sb.WriteRune(unicode.ToUpper(m.Promotion))
+		}
+	default:
+		sb.WriteRune(kind)
+		sb.WriteString(p.disambiguation(m))
+		if p.PieceAt(m.To) != 0 {
+			sb.WriteByte('x')
+		}
+		sb.WriteString(m.To.String())
+	}
+	return sb.String()
+}
+
+// disambiguation returns the file, rank or square needed to tell m apart from
+// another piece of the same kind that can reach the same square.
+func (p Position) disambiguation(m Move) string {
+	piece := p.PieceAt(m.From)
+	ambiguous, sameFile, sameRank := false, false, false
+	for _, other := range p.LegalMoves() {
+		if other.To != m.To || other.From == m.From {
+			continue
+		}
+		if p.PieceAt(other.From) != piece {
+			continue
+		}
+		ambiguous = true
+		if other.From.Col == m.From.Col {
+			sameFile = true
+		}
+		if other.From.Row == m.From.Row {
+			sameRank = true
+		}
+	}
+
+	switch {
+	case !ambiguous:
+		return ""
+	case !sameFile:
+		return string(m.From.File())
+	case !sameRank:
+		return strconv.Itoa(m.From.Rank())
+	}
+	return m.From.String()
+}
+
+// SANLine writes a sequence of moves played from p in SAN with move numbers, e.g.
+// "12. Nf3 Nc6 13. Bb5" or "12... Nc6 13. Bb5". It stops at the first illegal move.
+func (p Position) SANLine(moves []Move) string {
+	var sb strings.Builder
+	pos := p
+	for i, m := range moves {
+		if !pos.IsLegal(m) {
+			break
+		}
+		if i > 0 {
+			sb.WriteByte(' ')
+		}
+		if pos.WhiteTurn {
+			sb.WriteString(strconv.Itoa(pos.FullMoveNumber) + ". ")
+		} else if i == 0 {
+			sb.WriteString(strconv.Itoa(pos.FullMoveNumber) + "... ")
+		}
+		sb.WriteString(pos.SAN(m))
+		pos = pos.Play(m)
+	}
+	return sb.String()
+}
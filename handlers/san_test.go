@@ -0,0 +1,61 @@
+package handlers
+
+import "testing"
+
+func TestParseSAN(t *testing.T) {
+	tests := []struct {
+		name string
+		fen  string
+		san  string
+		move string
+	}{
+		{"check suffix", StartFEN, "Nf3+", "g1f3"},
+		{"annotation", StartFEN, "e4!?", "e2e4"},
+		{"castling with zeros", "r3k2r/8/8/8/8/8/8/R3K2R w KQkq - 0 1", "0-0-0", "e1c1"},
+		{"capture without x", "rnbqkbnr/ppp1pppp/8/3p4/4P3/8/PPPP1PPP/RNBQKBNR w KQkq d6 0 2", "ed5", "e4d5"},
+		{"promotion without =", "8/4P3/8/8/8/8/k7/4K3 w - - 0 1", "e8N", "e7e8n"},
+		{"black promotion", "4k3/8/8/8/8/8/p7/4K3 b - - 0 1", "a1=R", "a2a1r"},
+		{"square disambiguation", "4k3/8/8/8/8/Q1Q5/8/Q3K3 w - - 0 1", "Qa3b2", "a3b2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pos, err := ParseFEN(tt.fen)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m, err := pos.ParseSAN(tt.san)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m.String() != tt.move {
+				t.Errorf("ParseSAN(%q) = %s, want %s", tt.san, m, tt.move)
+			}
+		})
+	}
+}
+
+func TestParseSANErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		fen  string
+		san  string
+	}{
+		{"empty", StartFEN, ""},
+		{"illegal", StartFEN, "e5"},
+		{"no such piece", StartFEN, "Qh5"},
+		{"ambiguous", "4k3/8/8/8/8/8/8/1N2KN2 w - - 0 1", "Nd2"},
+		{"castling through pieces", StartFEN, "O-O"},
+		{"missing promotion", "8/4P3/8/8/8/8/k7/4K3 w - - 0 1", "e8"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pos, err := ParseFEN(tt.fen)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if m, err := pos.ParseSAN(tt.san); err == nil {
+				t.Errorf("ParseSAN(%q) = %s, want an error", tt.san, m)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"unicode"
+)
+
+// Square is a square in board coordinates: Row 0 is the 8th rank and Col 0 the a-file.
+type Square struct {
+	Row, Col int
+}
+
+// NoSquare stands for a missing square, such as no en passant target or no king on the board.
+var NoSquare = Square{Row: -1, Col: -1}
+
+// ParseSquare reads a square in algebraic notation, e.g. "e4".
+func ParseSquare(name string) (Square, error) {
+	if len(name) != 2 || name[0] < 'a' || name[0] > 'h' || name[1] < '1' || name[1] > '8' {
+		return NoSquare, fmt.Errorf("invalid square %q", name)
+	}
+	return Square{Row: int('8' - name[1]), Col: int(name[0] - 'a')}, nil
+}
+
+// Valid reports whether s is on the board.
+func (s Square) Valid() bool {
+	return s.Row >= 0 && s.Row < 8 && s.Col >= 0 && s.Col < 8
+}
+
+// File returns the file letter, 'a' to 'h'.
+func (s Square) File() byte {
+	return byte('a' + s.Col)
+}
+
+// Rank returns the rank number, 1 to 8.
+func (s Square) Rank() int {
+	return 8 - s.Row
+}
+
+// String returns the square in algebraic notation, or "-" when it is not on the board.
+func (s Square) String() string {
+	if !s.Valid() {
+		return "-"
+	}
+	return string([]byte{s.File(), byte('0' + s.Rank())})
+}
+
+// NewMove builds a move between two squares.
+func NewMove(from, to Square, promotion rune) Move {
+	return Move{From: from, To: to, Promotion: promotion}
+}
+
+// String returns the move in coordinate notation, e.g. "e2e4" or "e7e8q".
+func (m Move) String() string {
+	s := m.From.String() + m.To.String()
+	if m.Promotion != 0 {
+		s += string(unicode.ToLower(m.Promotion))
+	}
+	return s
+}
+
+// ParseUCI reads a legal move in coordinate notation, as String writes it, though
+// the promotion piece may be in upper case too.
+func (p Position) ParseUCI(text string) (Move, error) {
+	for _, m := range p.LegalMoves() {
+		if m.String() == text {
+			return m, nil
+		}
+	}
+	m, err := p.coordinateMove(text)
+	if err != nil {
+		return Move{}, fmt.Errorf("%s is not a legal move", text)
+	}
+	if err := p.CheckMove(m); err != nil {
+		return Move{}, fmt.Errorf("%s is not a legal move: %w", text, err)
+	}
+	// written differently from String, such as e7e8Q
+	return m, nil
+}
+
+// ParseMove reads a legal move in coordinate notation or SAN. A move in coordinate
+// notation that is not legal is reported with the reason.
+func (p Position) ParseMove(text string) (Move, error) {
+	m, err := p.ParseUCI(text)
+	if err == nil || errors.Unwrap(err) != nil {
+		return m, err
+	}
+	return p.ParseSAN(text)
+}
+
+// coordinateMove reads text as a move in coordinate notation, legal or not; the
+// promotion piece takes the color of the side to move.
+func (p Position) coordinateMove(text string) (Move, error) {
+	if len(text) != 4 && len(text) != 5 {
+		return Move{}, errors.New("not coordinate notation")
+	}
+	from, err := ParseSquare(text[:2])
+	if err != nil {
+		return Move{}, err
+	}
+	to, err := ParseSquare(text[2:4])
+	if err != nil {
+		return Move{}, err
+	}
+	var promotion rune
+	if len(text) == 5 {
+		promotion = unicode.ToLower(rune(text[4]))
+		if p.WhiteTurn {
+			promotion = unicode.ToUpper(promotion)
+		}
+	}
+	return NewMove(from, to, promotion), nil
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseUCI(t *testing.T) {
+	tests := []struct {
+		name   string
+		fen    string
+		text   string
+		want   string // the move, "" for an error
+		reason error  // the reason given for an illegal move, nil for none
+	}{
+		{"plain move", StartFEN, "e2e4", "e2e4", nil},
+		{"promotion", "8/4P3/8/8/8/8/k7/4K3 w - - 0 1", "e7e8q", "e7e8q", nil},
+		{"promotion in upper case", "8/4P3/8/8/8/8/k7/4K3 w - - 0 1", "e7e8Q", "e7e8q", nil},
+		{"black promotion in upper case", "4k3/8/8/8/8/8/4p3/K7 b - - 0 1", "e2e1N", "e2e1n", nil},
+		{"illegal move", StartFEN, "e2e5", "", ErrMovement},
+		{"no piece", StartFEN, "e4e5", "", ErrNoPiece},
+		{"promotion without a piece", "8/4P3/8/8/8/8/k7/4K3 w - - 0 1", "e7e8", "", ErrMustPromote},
+		{"not coordinate notation", StartFEN, "Nf3", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pos, err := ParseFEN(tt.fen)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m, err := pos.ParseUCI(tt.text)
+			if tt.want != "" {
+				if err != nil || m.String() != tt.want {
+					t.Errorf("ParseUCI(%q) = %v, %v, want %s", tt.text, m, err, tt.want)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("ParseUCI(%q) = %v, want an error", tt.text, m)
+			}
+			if tt.reason != nil && !errors.Is(err, tt.reason) {
+				t.Errorf("ParseUCI(%q) error %v, want %v", tt.text, err, tt.reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Validate reports why p cannot be played from: each side needs exactly one king, no
+// pawn may stand on the first or last rank, the side not to move must not be in check,
+// and the castling rights and en passant square must match the pieces on the board.
+func (p Position) Validate() error {
+	for _, white := range []bool{true, false} {
+		king := 'k'
+		if white {
+			king = 'K'
+		}
+		if n := p.count(king); n != 1 {
+			return fmt.Errorf("%s has %d kings, it needs exactly one", colorName(white), n)
+		}
+	}
+
+	for _, row := range []int{0, 7} {
+		for col := 0; col < 8; col++ {
+			if piece := p.Board[row][col]; piece == 'P' || piece == 'p' {
+				return fmt.Errorf("%s pawn on %s: pawns cannot stand on the first or last rank",
+					pieceColor(piece == 'P'), Square{Row: row, Col: col})
+			}
+		}
+	}
+
+	opponent := p.KingSquare(!p.WhiteTurn)
+	if IsInCheck(p.Board, !p.WhiteTurn, opponent) {
+		return fmt.Errorf("%s is in check but it is %s's turn", colorName(!p.WhiteTurn), colorName(p.WhiteTurn))
+	}
+
+	castling := []struct {
+		allowed    bool
+		right      string
+		king, rook rune
+		kingSquare Square
+		rookSquare Square
+	}{
+		{p.Castling.WhiteKingSide, "K", 'K', 'R', initialPositions.whiteKing, initialPositions.whiteKingRook},
+		{p.Castling.WhiteQueenSide, "Q", 'K', 'R', initialPositions.whiteKing, initialPositions.whiteQueenRook},
+		{p.Castling.BlackKingSide, "k", 'k', 'r', initialPositions.blackKing, initialPositions.blackKingRook},
+		{p.Castling.BlackQueenSide, "q", 'k', 'r', initialPositions.blackKing, initialPositions.blackQueenRook},
+	}
+	for _, c := range castling {
+		if c.allowed && (p.PieceAt(c.kingSquare) != c.king || p.PieceAt(c.rookSquare) != c.rook) {
+			return fmt.Errorf("castling right %s needs the %s king on %s and a rook on %s",
+				c.right, pieceColor(c.king == 'K'), c.kingSquare, c.rookSquare)
+		}
+	}
+
+	if p.EnPassant.Valid() {
+		// the pawn that just moved two squares stands in front of the en passant square
+		rank, pawn, dir := 6, 'p', 1
+		if !p.WhiteTurn {
+			rank, pawn, dir = 3, 'P', -1
+		}
+		ep := p.EnPassant
+		if ep.Rank() != rank {
+			return fmt.Errorf("en passant square %s is not on rank %d with %s to move", ep, rank, colorName(p.WhiteTurn))
+		}
+		if p.PieceAt(ep) != 0 || p.Board[ep.Row-dir][ep.Col] != 0 {
+			return fmt.Errorf("en passant square %s and the square behind it must be empty", ep)
+		}
+		if p.Board[ep.Row+dir][ep.Col] != pawn {
+			return fmt.Errorf("en passant square %s needs a %s pawn on %s",
+				ep, pieceColor(pawn == 'P'), Square{Row: ep.Row + dir, Col: ep.Col})
+		}
+	}
+	return nil
+}
+
+func (p Position) count(piece rune) int {
+	n := 0
+	for i := 0; i < 8; i++ {
+		for j := 0; j < 8; j++ {
+			if p.Board[i][j] == piece {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+func colorName(white bool) string {
+	if white {
+		return "White"
+	}
+	return "Black"
+}
+
+// pieceColor is colorName as an adjective, as in "white pawn".
+func pieceColor(white bool) string {
+	return strings.ToLower(colorName(white))
+}
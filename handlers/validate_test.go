@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		fen  string
+		err  string // a substring of the expected error, "" for a valid position
+	}{
+		{"start", StartFEN, ""},
+		{"kiwipete", "r3k2r/p1ppqpb1/bn2pnp1/3PN3/1p2P3/2N2Q1p/PPPBBPPP/R3K2R w KQkq - 0 1", ""},
+		{"en passant", "rnbqkbnr/ppp1pppp/8/3p4/4P3/8/PPPP1PPP/RNBQKBNR w KQkq d6 0 2", ""},
+		{"side to move in check", "4k3/8/8/8/8/8/8/r3K3 w - - 0 1", ""},
+		{"no white king", "4k3/8/8/8/8/8/8/8 w - - 0 1", "White has 0 kings"},
+		{"two black kings", "k3k3/8/8/8/8/8/8/4K3 w - - 0 1", "Black has 2 kings"},
+		{"pawn on the first rank", "4k3/8/8/8/8/8/8/P3K3 w - - 0 1", "pawn on a1"},
+		{"pawn on the last rank", "4k2p/8/8/8/8/8/8/4K3 w - - 0 1", "pawn on h8"},
+		{"opponent in check", "4k3/8/8/8/8/8/8/4RK2 w - - 0 1", "Black is in check but it is White's turn"},
+		{"castling without rook", "4k3/8/8/8/8/8/8/4K3 w K - 0 1", "castling right K"},
+		{"castling with moved king", "r3k2r/8/8/8/8/8/8/R2K3R w Q - 0 1", "castling right Q"},
+		{"black castling", "r3k3/8/8/8/8/8/8/4K3 b k - 0 1", "castling right k"},
+		{"en passant on the wrong rank", "4k3/8/8/8/3p4/8/8/4K3 w - d3 0 1", "not on rank 6"},
+		{"en passant without pawn", "4k3/8/8/8/8/8/8/4K3 w - d6 0 1", "needs a black pawn on d5"},
+		{"en passant square occupied", "4k3/8/3n4/3p4/8/8/8/4K3 w - d6 0 1", "must be empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pos, err := ParseFEN(tt.fen)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = pos.Validate()
+			switch {
+			case tt.err == "" && err != nil:
+				t.Errorf("Validate() = %v, want nil", err)
+			case tt.err != "" && err == nil:
+				t.Errorf("Validate() = nil, want an error containing %q", tt.err)
+			case tt.err != "" && !strings.Contains(err.Error(), tt.err):
+				t.Errorf("Validate() = %v, want an error containing %q", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"chess-engine/game"
+	"chess-engine/handlers"
+	"image/color"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+)
+
+var (
+	selectedColor = color.NRGBA{R: 20, G: 110, B: 210, A: 120}
+	lastMoveColor = color.NRGBA{R: 255, G: 210, B: 0, A: 100}
+	targetColor   = color.NRGBA{R: 40, G: 170, B: 70, A: 190}
+	checkColor    = color.NRGBA{R: 230, G: 0, B: 0, A: 230}
+)
+
+// shapeColors are the colors of the arrows and squares the player draws, by their PGN letter.
+var shapeColors = map[byte]color.NRGBA{
+	'G': {R: 21, G: 120, B: 27, A: 170},
+	'R': {R: 136, G: 32, B: 32, A: 170},
+	'Y': {R: 230, G: 143, B: 0, A: 170},
+	'B': {R: 0, G: 48, B: 136, A: 170},
+}
+
+// squareMarks are the highlights drawn on one square.
+type squareMarks struct {
+	selected      bool
+	lastMove      bool
+	legalTarget   bool // the selected piece can move here
+	captureTarget bool // the selected piece can capture here
+	check         bool
+	shape         byte // color of a circle the player drew on the square, 0 for none
+}
+
+// displayedMarks is what the board widget currently highlights.
+var displayedMarks [8][8]squareMarks
+
+// computeMarks works out the highlights for the shown position: the last move, a king
+// in check, and the selected piece with the squares it can move to.
+func computeMarks() [8][8]squareMarks {
+	var marks [8][8]squareMarks
+	if editing {
+		return marks
+	}
+	pos := currentGame.PositionAt(viewPly)
+
+	for _, shape := range currentGame.Shapes(viewPly) {
+		if !shape.IsArrow() {
+			marks[shape.From.Row][shape.From.Col].shape = shape.Color
+		}
+	}
+
+	if viewPly > 0 {
+		last := currentGame.Moves()[viewPly-1]
+		marks[last.From.Row][last.From.Col].lastMove = true
+		marks[last.To.Row][last.To.Col].lastMove = true
+	}
+
+	if pos.InCheck() {
+		king := pos.KingSquare(pos.WhiteTurn)
+		marks[king.Row][king.Col].check = true
+	}
+
+	if pieceSelected {
+		marks[selectedRow][selectedCol].selected = true
+		for _, m := range pos.LegalMoves() {
+			if m.From.Row != selectedRow || m.From.Col != selectedCol {
+				continue
+			}
+			target := &marks[m.To.Row][m.To.Col]
+			piece := pos.PieceAt(m.From)
+			isEnPassant := (piece == 'P' || piece == 'p') && m.From.Col != m.To.Col
+			if pos.PieceAt(m.To) != 0 || isEnPassant {
+				target.captureTarget = true
+			} else {
+				target.legalTarget = true
+			}
+		}
+	}
+	return marks
+}
+
+// computeArrows lists the arrows for the shown position: the ones the player drew,
+// then the engine's best move and threat while analysis is on.
+func computeArrows() []boardArrow {
+	if editing {
+		return nil
+	}
+	var arrows []boardArrow
+	for _, shape := range currentGame.Shapes(viewPly) {
+		if shape.IsArrow() {
+			arrows = append(arrows, newBoardArrow(shape.From, shape.To, shapeColors[shape.Color]))
+		}
+	}
+	return append(arrows, engineArrows...)
+}
+
+// drawShape adds a shape the player drew to the shown position, or takes it away
+// when the same shape is drawn again.
+func drawShape(shape game.Shape) {
+	currentGame.ToggleShape(viewPly, shape)
+	redrawBoard()
+}
+
+// shapeColor picks the color of a drawn shape from the modifier keys held: green
+// without any, red with Shift or Ctrl, blue with Alt and yellow with both.
+func shapeColor(modifier fyne.KeyModifier) byte {
+	shift := modifier&(fyne.KeyModifierShift|fyne.KeyModifierControl) != 0
+	alt := modifier&fyne.KeyModifierAlt != 0
+	switch {
+	case shift && alt:
+		return 'Y'
+	case shift:
+		return 'R'
+	case alt:
+		return 'B'
+	}
+	return 'G'
+}
+
+func newBoardArrow(from, to handlers.Square, c color.Color) boardArrow {
+	return boardArrow{fromRow: from.Row, fromCol: from.Col, toRow: to.Row, toCol: to.Col, color: c}
+}
+
+// markOverlay is a highlight object drawn centered on its square, scale times the square's size.
+type markOverlay struct {
+	object fyne.CanvasObject
+	scale  float32
+}
+
+// markOverlays returns the objects drawn between the square and the piece, and
+// those drawn above the piece.
+func markOverlays(marks squareMarks) (below, above []markOverlay) {
+	if marks.lastMove {
+		below = append(below, markOverlay{canvas.NewRectangle(lastMoveColor), 1})
+	}
+	if marks.selected {
+		below = append(below, markOverlay{canvas.NewRectangle(selectedColor), 1})
+	}
+	if marks.check {
+		glow := canvas.NewRadialGradient(checkColor, color.Transparent)
+		below = append(below, markOverlay{glow, 1})
+	}
+	if marks.captureTarget {
+		ring := canvas.NewCircle(color.Transparent)
+		ring.StrokeColor = targetColor
+		ring.StrokeWidth = 5
+		below = append(below, markOverlay{ring, 0.9})
+	}
+	if marks.legalTarget {
+		dot := canvas.NewCircle(targetColor)
+		above = append(above, markOverlay{dot, 0.3})
+	}
+	if marks.shape != 0 {
+		circle := canvas.NewCircle(color.Transparent)
+		circle.StrokeColor = shapeColors[marks.shape]
+		circle.StrokeWidth = 4
+		above = append(above, markOverlay{circle, 0.95})
+	}
+	return below, above
+}
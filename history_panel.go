@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+const historyPanelWidth = 260
+
+var historyList *widget.List
+
+// historyPanel is the score sheet shown next to the board, and sidePanel the
+// container that shows it or the board editor in its place.
+var historyPanel fyne.CanvasObject
+var sidePanel *fyne.Container
+
+// historyOffset is 1 when the game started with Black to move, leaving the first White cell empty.
+func historyOffset() int {
+	if currentGame.StartPosition().WhiteTurn {
+		return 0
+	}
+	return 1
+}
+
+func historyRows() int {
+	return (currentGame.Ply() + historyOffset() + 1) / 2
+}
+
+// newHistoryPanel builds the score sheet: one row per move number with White's and
+// Black's move in SAN, and first/previous/next/last controls underneath.
+func newHistoryPanel() fyne.CanvasObject {
+	historyList = widget.NewList(
+		historyRows,
+		func() fyne.CanvasObject {
+			whiteButton := widget.NewButton("", nil)
+			blackButton := widget.NewButton("", nil)
+			return container.NewGridWithColumns(3, widget.NewLabel(""), whiteButton, blackButton)
+		},
+		func(row widget.ListItemID, item fyne.CanvasObject) {
+			cells := item.(*fyne.Container).Objects
+			cells[0].(*widget.Label).SetText(fmt.Sprintf("%d.", currentGame.StartPosition().FullMoveNumber+row))
+
+			san := currentGame.SAN()
+			for side, cell := range cells[1:] {
+				button := cell.(*widget.Button)
+				index := 2*row + side - historyOffset()
+				if index < 0 || index >= len(san) {
+					button.Hide()
+					continue
+				}
+				button.SetText(san[index])
+				button.Importance = widget.LowImportance
+				if index == viewPly-1 {
+					button.Importance = widget.HighImportance
+				}
+				button.OnTapped = func() {
+					showPly(index + 1)
+				}
+				button.Show()
+				button.Refresh()
+			}
+		},
+	)
+
+	navigation := container.NewGridWithColumns(4,
+		widget.NewButtonWithIcon("", theme.MediaSkipPreviousIcon(), func() { showPly(0) }),
+		widget.NewButtonWithIcon("", theme.NavigateBackIcon(), func() { showPly(viewPly - 1) }),
+		widget.NewButtonWithIcon("", theme.NavigateNextIcon(), func() { showPly(viewPly + 1) }),
+		widget.NewButtonWithIcon("", theme.MediaSkipNextIcon(), func() { showPly(currentGame.Ply()) }),
+	)
+
+	// the spacer gives the panel its width, the list alone would collapse
+	spacer := canvas.NewRectangle(color.Transparent)
+	spacer.SetMinSize(fyne.NewSize(historyPanelWidth, 0))
+	return container.NewStack(spacer, container.NewBorder(widget.NewLabel("Moves"), container.NewVBox(navigation, newAnalysisPanel(), newLANPanel()), nil, nil, historyList))
+}
+
+// refreshHistory redraws the score sheet and keeps the shown move in view.
+func refreshHistory() {
+	if historyList == nil {
+		return
+	}
+	historyList.Refresh()
+	if viewPly > 0 {
+		historyList.ScrollTo((viewPly - 1 + historyOffset()) / 2)
+	}
+}
+
+// handleHistoryKey steps through the game with the arrow keys: left and right move
+// one half-move, up and down jump to the start and the end.
+func handleHistoryKey(event *fyne.KeyEvent) {
+	if editing {
+		return
+	}
+	switch event.Name {
+	case fyne.KeyLeft:
+		showPly(viewPly - 1)
+	case fyne.KeyRight:
+		showPly(viewPly + 1)
+	case fyne.KeyUp, fyne.KeyHome:
+		showPly(0)
+	case fyne.KeyDown, fyne.KeyEnd:
+		showPly(currentGame.Ply())
+	}
+}
+
+// confirmTruncation asks before a move played from an earlier position discards
+// the rest of the game, then runs onConfirm from the truncated game.
+func confirmTruncation(onConfirm func()) {
+	later := currentGame.Ply() - viewPly
+	message := fmt.Sprintf("Playing a move here discards the %d later half-moves.", later)
+	dialog.ShowConfirm("Continue from here?", message, func(confirmed bool) {
+		if !confirmed {
+			return
+		}
+		stopPlay()
+		currentGame.Truncate(viewPly)
+		// the clock now runs for the side to move in the earlier position
+		if gameClock != nil {
+			gameClock.Stop()
+			gameClock.Start(currentGame.Position().WhiteTurn)
+		}
+		refreshHistory()
+		resumePlay()
+		onConfirm()
+	}, chessWindow)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"chess-engine/game"
+	"chess-engine/handlers"
+	"errors"
+	"io"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/storage"
+)
+
+var pgnFilter = storage.NewExtensionFileFilter([]string{".pgn"})
+
+// copyFEN puts the position on the board on the clipboard.
+func copyFEN() {
+	chessWindow.Clipboard().SetContent(shownPosition().FEN())
+}
+
+// copyPGN puts the whole game on the clipboard.
+func copyPGN() {
+	chessWindow.Clipboard().SetContent(currentGame.PGN())
+}
+
+// pasteGame loads a FEN or a PGN game from the clipboard.
+func pasteGame() {
+	if err := loadGameText(chessWindow.Clipboard().Content()); err != nil {
+		dialog.ShowError(err, chessWindow)
+	}
+}
+
+// loadGameText starts a game from text holding either a FEN string or a PGN game.
+// A FEN starts a game between the current players; a PGN keeps its own tags and
+// continues from its last position.
+func loadGameText(text string) error {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return errors.New("there is no FEN or PGN to load")
+	}
+	if isFEN(text) {
+		pos, err := handlers.ParseFEN(text)
+		if err != nil {
+			return err
+		}
+		if err := pos.Validate(); err != nil {
+			return err
+		}
+		startGame(newGameFrom(pos))
+		return nil
+	}
+
+	g, err := game.ParsePGN(text)
+	if err != nil {
+		return err
+	}
+	startGame(g)
+	return nil
+}
+
+// isFEN tells a FEN string from PGN text by its eight ranks on a single line.
+func isFEN(text string) bool {
+	fields := strings.Fields(text)
+	return len(fields) > 0 && !strings.ContainsAny(text, "[\n") && strings.Count(fields[0], "/") == 7
+}
+
+// openPGN asks for a .pgn file and loads the first game in it.
+func openPGN() {
+	openDialog := dialog.NewFileOpen(func(reader fyne.URIReadCloser, err error) {
+		if err != nil {
+			dialog.ShowError(err, chessWindow)
+			return
+		}
+		if reader == nil {
+			return
+		}
+		defer reader.Close()
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			dialog.ShowError(err, chessWindow)
+			return
+		}
+		g, err := game.ParsePGN(string(data))
+		if err != nil {
+			dialog.ShowError(err, chessWindow)
+			return
+		}
+		startGame(g)
+	}, chessWindow)
+	openDialog.SetFilter(pgnFilter)
+	openDialog.Show()
+}
+
+// newGameMenu builds the Game menu and binds its keyboard shortcuts to the window.
+// The clipboard items use modified chords so plain copy and paste still work in
+// text fields such as the chat.
+func newGameMenu(window fyne.Window) *fyne.Menu {
+	item := func(label string, key fyne.KeyName, modifier fyne.KeyModifier, action func()) *fyne.MenuItem {
+		shortcut := &desktop.CustomShortcut{KeyName: key, Modifier: modifier}
+		window.Canvas().AddShortcut(shortcut, func(fyne.Shortcut) { action() })
+		menuItem := fyne.NewMenuItem(label, action)
+		menuItem.Shortcut = shortcut
+		return menuItem
+	}
+	shortcut := fyne.KeyModifierShortcutDefault
+	return fyne.NewMenu("Game",
+		item("New Game...", fyne.KeyN, shortcut, showNewGameDialog),
+		fyne.NewMenuItemSeparator(),
+		item("Open PGN...", fyne.KeyO, shortcut, openPGN),
+		item("Save PGN...", fyne.KeyS, shortcut, func() { savePGN(currentGame) }),
+		fyne.NewMenuItemSeparator(),
+		item("Copy FEN", fyne.KeyC, shortcut|fyne.KeyModifierShift, copyFEN),
+		item("Copy PGN", fyne.KeyC, shortcut|fyne.KeyModifierAlt, copyPGN),
+		item("Paste FEN or PGN", fyne.KeyV, shortcut|fyne.KeyModifierShift, pasteGame),
+		fyne.NewMenuItemSeparator(),
+		fyne.NewMenuItem("Host LAN Game...", showHostDialog),
+		fyne.NewMenuItem("Join LAN Game...", showJoinDialog),
+		fyne.NewMenuItemSeparator(),
+		fyne.NewMenuItem("Engines...", showEnginesDialog),
+	)
+}
@@ -0,0 +1,53 @@
+// Package lan plays a game between two clients over TCP on a local network, one
+// hosting and one joining. Each side keeps its own copy of the game and checks
+// every move it receives against it. The package has no GUI dependencies.
+//
+// # Protocol, version 1
+//
+// Messages are JSON objects, one per line (UTF-8, "\n" terminated, at most 64 KiB),
+// each with a "type" field. Fields not listed for a type are left out. A client
+// ignores message types and fields it does not know, so later versions can add
+// them; Version changes only when old clients would misunderstand the new messages.
+//
+// The joining client (the guest) opens the connection and introduces itself:
+//
+//	{"type": "hello", "version": 1, "name": "Alice"}
+//
+// The host answers with the game to play, the guest's color and its own name:
+//
+//	{"type": "start", "version": 1, "name": "Bob", "fen": "<FEN>", "color": "black"}
+//
+// or, when it cannot play with the guest, an error followed by closing the
+// connection:
+//
+//	{"type": "error", "code": "version", "message": "..."}
+//
+// The game is then played with these messages, in either direction:
+//
+//	{"type": "move", "move": "e2e4", "ply": 0}
+//	    a move in UCI notation; ply is the number of half-moves played before it
+//	{"type": "draw_offer"}
+//	    stands until it is accepted or declined; the other side declines it
+//	    before playing on instead of answering
+//	{"type": "draw_accept"} and {"type": "draw_decline"}
+//	    answer the other side's open draw offer
+//	{"type": "resign"}
+//	{"type": "takeback_request", "ply": 10}
+//	    asks to go back to the position after ply half-moves: the requester's
+//	    last move is taken back, and the opponent's reply if one was played
+//	{"type": "takeback_accept", "ply": 10} and {"type": "takeback_decline"}
+//	    answer an open takeback request; on accept both sides go back to ply.
+//	    Like a draw offer, the request is declined before playing on
+//	{"type": "chat", "text": "good luck"}
+//	    at most 500 characters
+//	{"type": "bye"}
+//	    the sender is leaving; the connection closes after it
+//
+// A move that is illegal, out of turn or numbered with the wrong ply means the
+// two copies of the game disagree. The receiver answers
+//
+//	{"type": "error", "code": "illegal_move", "message": "..."}
+//
+// and closes the connection. An answer to an offer or request that is not open is
+// ignored. Games are played without clocks in this version.
+package lan
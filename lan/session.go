@@ -0,0 +1,562 @@
+package lan
+
+import (
+	"bufio"
+	"chess-engine/game"
+	"chess-engine/handlers"
+	"chess-engine/logging"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+var logger = logging.For(logging.LAN)
+
+// Version is the protocol version this package speaks.
+const Version = 1
+
+// DefaultPort is the TCP port a host listens on unless told otherwise.
+const DefaultPort = 7654
+
+const (
+	maxLineBytes  = 64 << 10
+	maxChatLength = 500
+	// handshakeTimeout bounds the exchange of hello and start, writeTimeout every message sent
+	handshakeTimeout = 10 * time.Second
+	writeTimeout     = 10 * time.Second
+)
+
+// Message types, which are also the types of the events a Session delivers.
+const (
+	TypeHello           = "hello"
+	TypeStart           = "start"
+	TypeError           = "error"
+	TypeMove            = "move"
+	TypeDrawOffer       = "draw_offer"
+	TypeDrawAccept      = "draw_accept"
+	TypeDrawDecline     = "draw_decline"
+	TypeResign          = "resign"
+	TypeTakebackRequest = "takeback_request"
+	TypeTakebackAccept  = "takeback_accept"
+	TypeTakebackDecline = "takeback_decline"
+	TypeChat            = "chat"
+	TypeBye             = "bye"
+	// TypeClosed is only an event: the connection is gone
+	TypeClosed = "closed"
+)
+
+var (
+	ErrClosed      = errors.New("the connection is closed")
+	ErrNotYourTurn = errors.New("it is not your turn")
+	ErrNoTakeback  = errors.New("there is no move of yours to take back")
+	ErrGameOver    = errors.New("the game is over")
+)
+
+// message is the wire form of every message; see the package documentation. Ply
+// is a pointer so that ply 0 is sent, and a message without one is told apart.
+type message struct {
+	Type    string `json:"type"`
+	Version int    `json:"version,omitempty"`
+	Name    string `json:"name,omitempty"`
+	FEN     string `json:"fen,omitempty"`
+	Color   string `json:"color,omitempty"`
+	Move    string `json:"move,omitempty"`
+	Ply     *int   `json:"ply,omitempty"`
+	Text    string `json:"text,omitempty"`
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// Event is something the opponent did, or the end of the connection. Move is set
+// for TypeMove, Ply for takeback requests and accepts, Text for chat and Err for
+// TypeClosed, nil when the opponent said goodbye.
+type Event struct {
+	Type string
+	Move handlers.Move
+	Ply  int
+	Text string
+	Err  error
+}
+
+// Session is one side of a game over the network. It checks the moves of both
+// sides against its own copy of the game and keeps track of open offers.
+type Session struct {
+	conn     net.Conn
+	scanner  *bufio.Scanner
+	white    bool
+	opponent string
+	events   chan Event
+
+	writeMu sync.Mutex
+
+	mu            sync.Mutex
+	game          *game.Game
+	drawOffer     int // 1 when we offered a draw, -1 when the opponent did, 0 when none is open
+	takebackAsked int // the ply we asked to go back to, -1 if none
+	takebackOffer int // the ply the opponent asked to go back to, -1 if none
+	closed        bool
+}
+
+func newSession(conn net.Conn, white bool, opponent string, start handlers.Position) *Session {
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 4096), maxLineBytes)
+	return &Session{
+		conn: conn, scanner: scanner, white: white, opponent: opponent,
+		events: make(chan Event, 64), game: game.NewFromPosition(start),
+		takebackAsked: -1, takebackOffer: -1,
+	}
+}
+
+// White reports whether this side plays White.
+func (s *Session) White() bool {
+	return s.white
+}
+
+// Opponent returns the name the other side gave.
+func (s *Session) Opponent() string {
+	return s.opponent
+}
+
+// Start returns the position the game starts from.
+func (s *Session) Start() handlers.Position {
+	return s.game.StartPosition()
+}
+
+// Events delivers what the opponent does. It ends with a TypeClosed event and is
+// then closed.
+func (s *Session) Events() <-chan Event {
+	return s.events
+}
+
+func colorName(white bool) string {
+	if white {
+		return "white"
+	}
+	return "black"
+}
+
+// Listener waits for a guest to join a hosted game.
+type Listener struct {
+	ln net.Listener
+}
+
+// Listen opens addr, such as ":7654", for a guest to join.
+func Listen(addr string) (*Listener, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &Listener{ln}, nil
+}
+
+// Addr returns the address the listener is open on.
+func (l *Listener) Addr() net.Addr {
+	return l.ln.Addr()
+}
+
+// Close stops waiting; a blocked Accept returns an error.
+func (l *Listener) Close() error {
+	return l.ln.Close()
+}
+
+// Accept waits for a guest and starts a game from start with it, the host playing
+// White if hostWhite. name is the host's name as the guest sees it.
+func (l *Listener) Accept(name string, start handlers.Position, hostWhite bool) (*Session, error) {
+	conn, err := l.ln.Accept()
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(handshakeTimeout))
+
+	s := newSession(conn, hostWhite, "", start)
+	hello, err := s.read()
+	if err == nil && hello.Type != TypeHello {
+		err = fmt.Errorf("expected hello, got %q", hello.Type)
+	}
+	if err == nil && hello.Version != Version {
+		s.send(message{Type: TypeError, Code: "version",
+			Message: fmt.Sprintf("this host speaks protocol version %d, not %d", Version, hello.Version)})
+		err = fmt.Errorf("the guest speaks protocol version %d, not %d", hello.Version, Version)
+	}
+	if err == nil {
+		s.opponent = hello.Name
+		err = s.send(message{Type: TypeStart, Version: Version, Name: name, FEN: start.FEN(), Color: colorName(!hostWhite)})
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	go s.receive()
+	return s, nil
+}
+
+// Join connects to the host at addr, such as "192.168.1.20:7654", and starts the
+// game it offers.
+func Join(addr, name string) (*Session, error) {
+	conn, err := net.DialTimeout("tcp", addr, handshakeTimeout)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(handshakeTimeout))
+
+	s := newSession(conn, false, "", handlers.StartPosition())
+	err = s.send(message{Type: TypeHello, Version: Version, Name: name})
+	var start message
+	if err == nil {
+		start, err = s.read()
+	}
+	if err == nil {
+		switch {
+		case start.Type == TypeError:
+			err = fmt.Errorf("the host refused: %s", start.Message)
+		case start.Type != TypeStart:
+			err = fmt.Errorf("expected start, got %q", start.Type)
+		case start.Version != Version:
+			err = fmt.Errorf("the host speaks protocol version %d, not %d", start.Version, Version)
+		}
+	}
+	var pos handlers.Position
+	if err == nil {
+		if pos, err = handlers.ParseFEN(start.FEN); err == nil {
+			err = pos.Validate()
+		}
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	s.white = start.Color == "white"
+	s.opponent = start.Name
+	s.game = game.NewFromPosition(pos)
+	go s.receive()
+	return s, nil
+}
+
+func (s *Session) read() (message, error) {
+	if !s.scanner.Scan() {
+		if err := s.scanner.Err(); err != nil {
+			return message{}, err
+		}
+		return message{}, ErrClosed
+	}
+	logger.Debug("received", "message", s.scanner.Text())
+	var msg message
+	if err := json.Unmarshal(s.scanner.Bytes(), &msg); err != nil {
+		return message{}, fmt.Errorf("invalid message: %w", err)
+	}
+	return msg, nil
+}
+
+func (s *Session) send(msg message) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	line, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	logger.Debug("sent", "message", string(line))
+	s.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	_, err = s.conn.Write(append(line, '\n'))
+	return err
+}
+
+// receive reads the opponent's messages until the connection ends.
+func (s *Session) receive() {
+	var err error
+	for {
+		var msg message
+		if msg, err = s.read(); err != nil {
+			break
+		}
+		if msg.Type == TypeBye {
+			err = nil
+			break
+		}
+		event, ok, fatal := s.handle(msg)
+		if fatal != nil {
+			s.send(message{Type: TypeError, Code: "illegal_move", Message: fatal.Error()})
+			err = fatal
+			break
+		}
+		if ok {
+			s.events <- event
+		}
+	}
+
+	s.mu.Lock()
+	closedHere := s.closed
+	s.closed = true
+	s.mu.Unlock()
+	s.conn.Close()
+	if closedHere {
+		err = ErrClosed
+	}
+	logger.Info("session ended", "opponent", s.opponent, "err", err)
+	s.events <- Event{Type: TypeClosed, Err: err}
+	close(s.events)
+}
+
+// handle applies a message from the opponent to the game. It returns the event to
+// deliver, if any, and an error when the two sides no longer agree on the game.
+func (s *Session) handle(msg message) (event Event, ok bool, fatal error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	event = Event{Type: msg.Type}
+	ongoing := !s.game.Over()
+	ply := -1
+	if msg.Ply != nil {
+		ply = *msg.Ply
+	}
+	switch msg.Type {
+	case TypeMove:
+		pos := s.game.Position()
+		if !ongoing || pos.WhiteTurn == s.white || ply != s.game.Ply() {
+			return event, false, fmt.Errorf("move %s at ply %d is out of turn", msg.Move, ply)
+		}
+		m, err := pos.ParseUCI(msg.Move)
+		if err == nil {
+			err = s.game.Play(m)
+		}
+		if err != nil {
+			return event, false, err
+		}
+		// our draw offer and takeback request stand: the opponent may have moved
+		// before they arrived, and a takeback covers the reply
+		s.takebackOffer = -1
+		event.Move = m
+		return event, true, nil
+	case TypeDrawOffer:
+		if !ongoing {
+			return event, false, nil
+		}
+		if s.drawOffer > 0 {
+			// both offered at once: that is an agreement
+			s.game.AgreeDraw()
+			s.drawOffer = 0
+			return Event{Type: TypeDrawAccept}, true, nil
+		}
+		s.drawOffer = -1
+	case TypeDrawAccept:
+		if !ongoing || s.drawOffer <= 0 {
+			return event, false, nil
+		}
+		s.game.AgreeDraw()
+		s.drawOffer = 0
+	case TypeDrawDecline:
+		if s.drawOffer <= 0 {
+			return event, false, nil
+		}
+		s.drawOffer = 0
+	case TypeResign:
+		if !ongoing {
+			return event, false, nil
+		}
+		s.game.Resign(!s.white)
+	case TypeTakebackRequest:
+		if !ongoing || ply < 0 || ply != s.takebackTarget(!s.white) {
+			return event, false, nil
+		}
+		s.takebackOffer = ply
+		event.Ply = ply
+	case TypeTakebackAccept:
+		if !ongoing || s.takebackAsked < 0 || ply != s.takebackAsked {
+			return event, false, nil
+		}
+		s.game.Truncate(ply)
+		s.takebackAsked = -1
+		event.Ply = ply
+	case TypeTakebackDecline:
+		if s.takebackAsked < 0 {
+			return event, false, nil
+		}
+		s.takebackAsked = -1
+	case TypeChat:
+		event.Text = truncateChat(msg.Text)
+	default:
+		// a message of a later version, or an error the other side is about to close after
+		return event, false, nil
+	}
+	return event, true, nil
+}
+
+// takebackTarget returns the ply a takeback by the given side goes back to: before
+// its last move, and before the reply if one was played. It is -1 when the side has
+// not moved yet. s.mu is held.
+func (s *Session) takebackTarget(white bool) int {
+	target := s.game.Ply() - 1
+	if s.game.Position().WhiteTurn == white {
+		target--
+	}
+	if target < 0 {
+		return -1
+	}
+	return target
+}
+
+func truncateChat(text string) string {
+	runes := []rune(text)
+	if len(runes) > maxChatLength {
+		return string(runes[:maxChatLength])
+	}
+	return text
+}
+
+// Move sends our move, which must be legal and our turn.
+func (s *Session) Move(m handlers.Move) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return ErrClosed
+	}
+	ply := s.game.Ply()
+	if s.game.Position().WhiteTurn != s.white {
+		s.mu.Unlock()
+		return ErrNotYourTurn
+	}
+	if err := s.game.Play(m); err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	// playing on declines the opponent's open draw offer and takeback request, so
+	// they are not left waiting for an answer
+	var declines []message
+	if s.drawOffer < 0 {
+		declines = append(declines, message{Type: TypeDrawDecline})
+		s.drawOffer = 0
+	}
+	if s.takebackOffer >= 0 {
+		declines = append(declines, message{Type: TypeTakebackDecline})
+		s.takebackOffer = -1
+	}
+	s.takebackAsked = -1
+	s.mu.Unlock()
+	for _, msg := range declines {
+		if err := s.send(msg); err != nil {
+			return err
+		}
+	}
+	return s.send(message{Type: TypeMove, Move: m.String(), Ply: &ply})
+}
+
+// OfferDraw offers the opponent a draw. If the opponent's offer is open, it is
+// accepted instead, which ends the game, and agreed is true.
+func (s *Session) OfferDraw() (agreed bool, err error) {
+	s.mu.Lock()
+	if s.game.Over() {
+		s.mu.Unlock()
+		return false, ErrGameOver
+	}
+	if s.drawOffer < 0 {
+		s.mu.Unlock()
+		return true, s.AcceptDraw()
+	}
+	s.drawOffer = 1
+	s.mu.Unlock()
+	return false, s.send(message{Type: TypeDrawOffer})
+}
+
+// AcceptDraw accepts the opponent's open draw offer, which ends the game.
+func (s *Session) AcceptDraw() error {
+	s.mu.Lock()
+	if s.drawOffer >= 0 || s.game.Over() {
+		s.mu.Unlock()
+		return nil
+	}
+	s.game.AgreeDraw()
+	s.drawOffer = 0
+	s.mu.Unlock()
+	return s.send(message{Type: TypeDrawAccept})
+}
+
+// DeclineDraw declines the opponent's open draw offer.
+func (s *Session) DeclineDraw() error {
+	s.mu.Lock()
+	if s.drawOffer >= 0 {
+		s.mu.Unlock()
+		return nil
+	}
+	s.drawOffer = 0
+	s.mu.Unlock()
+	return s.send(message{Type: TypeDrawDecline})
+}
+
+// Resign gives up the game.
+func (s *Session) Resign() error {
+	s.mu.Lock()
+	if s.game.Over() {
+		s.mu.Unlock()
+		return ErrGameOver
+	}
+	s.game.Resign(s.white)
+	s.mu.Unlock()
+	return s.send(message{Type: TypeResign})
+}
+
+// RequestTakeback asks the opponent to take back our last move, and their reply if
+// they made one. Nothing changes until they accept.
+func (s *Session) RequestTakeback() error {
+	s.mu.Lock()
+	target := s.takebackTarget(s.white)
+	switch {
+	case s.game.Over():
+		s.mu.Unlock()
+		return ErrGameOver
+	case target < 0:
+		s.mu.Unlock()
+		return ErrNoTakeback
+	}
+	s.takebackAsked = target
+	s.mu.Unlock()
+	return s.send(message{Type: TypeTakebackRequest, Ply: &target})
+}
+
+// AcceptTakeback grants the opponent's open takeback request. It returns the ply
+// both games go back to.
+func (s *Session) AcceptTakeback() (int, error) {
+	s.mu.Lock()
+	target := s.takebackOffer
+	if target < 0 || s.game.Over() {
+		s.mu.Unlock()
+		return -1, nil
+	}
+	s.game.Truncate(target)
+	s.takebackOffer = -1
+	s.mu.Unlock()
+	return target, s.send(message{Type: TypeTakebackAccept, Ply: &target})
+}
+
+// DeclineTakeback refuses the opponent's open takeback request.
+func (s *Session) DeclineTakeback() error {
+	s.mu.Lock()
+	if s.takebackOffer < 0 {
+		s.mu.Unlock()
+		return nil
+	}
+	s.takebackOffer = -1
+	s.mu.Unlock()
+	return s.send(message{Type: TypeTakebackDecline})
+}
+
+// Chat sends a line of chat, cut to the length the protocol allows.
+func (s *Session) Chat(text string) error {
+	return s.send(message{Type: TypeChat, Text: truncateChat(text)})
+}
+
+// Close says goodbye and ends the connection. The events end with a TypeClosed
+// event whose Err is ErrClosed.
+func (s *Session) Close() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	s.mu.Unlock()
+	s.send(message{Type: TypeBye})
+	s.conn.Close()
+}
@@ -0,0 +1,315 @@
+package lan
+
+import (
+	"bufio"
+	"chess-engine/handlers"
+	"encoding/json"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWireFormat(t *testing.T) {
+	ply := func(n int) *int { return &n }
+	tests := []struct {
+		msg  message
+		want string
+	}{
+		{message{Type: TypeHello, Version: 1, Name: "Alice"}, `{"type":"hello","version":1,"name":"Alice"}`},
+		{message{Type: TypeMove, Move: "e2e4", Ply: ply(0)}, `{"type":"move","move":"e2e4","ply":0}`},
+		{message{Type: TypeTakebackRequest, Ply: ply(0)}, `{"type":"takeback_request","ply":0}`},
+		{message{Type: TypeDrawOffer}, `{"type":"draw_offer"}`},
+		{message{Type: TypeChat, Text: "good luck"}, `{"type":"chat","text":"good luck"}`},
+	}
+	for _, tt := range tests {
+		line, err := json.Marshal(tt.msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(line) != tt.want {
+			t.Errorf("%s message = %s, want %s", tt.msg.Type, line, tt.want)
+		}
+	}
+}
+
+// TestReceive feeds lines from White to a session playing Black and checks what
+// each one leads to.
+func TestReceive(t *testing.T) {
+	type step struct {
+		line  string
+		event string // the event delivered, "" for none
+		fatal bool   // whether the session gives up on the game
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{"move", []step{
+			{`{"type": "move", "move": "e2e4", "ply": 0}`, TypeMove, false},
+		}},
+		{"move without a ply", []step{
+			{`{"type": "move", "move": "e2e4"}`, "", true},
+		}},
+		{"move with the wrong ply", []step{
+			{`{"type": "move", "move": "e2e4", "ply": 1}`, "", true},
+		}},
+		{"illegal move", []step{
+			{`{"type": "move", "move": "e2e5", "ply": 0}`, "", true},
+		}},
+		{"move out of turn", []step{
+			{`{"type": "move", "move": "e2e4", "ply": 0}`, TypeMove, false},
+			{`{"type": "move", "move": "d2d4", "ply": 1}`, "", true},
+		}},
+		{"unknown type and fields", []step{
+			{`{"type": "emote", "emote": "wave"}`, "", false},
+			{`{"type": "chat", "text": "hi", "mood": "happy"}`, TypeChat, false},
+		}},
+		{"answers to nothing", []step{
+			{`{"type": "draw_accept"}`, "", false},
+			{`{"type": "draw_decline"}`, "", false},
+			{`{"type": "takeback_accept", "ply": 0}`, "", false},
+			{`{"type": "takeback_decline"}`, "", false},
+		}},
+		{"draw offer", []step{
+			{`{"type": "draw_offer"}`, TypeDrawOffer, false},
+		}},
+		{"takeback to the start", []step{
+			{`{"type": "move", "move": "e2e4", "ply": 0}`, TypeMove, false},
+			{`{"type": "takeback_request", "ply": 0}`, TypeTakebackRequest, false},
+		}},
+		{"takeback without a ply", []step{
+			{`{"type": "move", "move": "e2e4", "ply": 0}`, TypeMove, false},
+			{`{"type": "takeback_request"}`, "", false},
+		}},
+		{"takeback before moving", []step{
+			{`{"type": "takeback_request", "ply": 0}`, "", false},
+		}},
+		{"resignation ends the game", []step{
+			{`{"type": "resign"}`, TypeResign, false},
+			{`{"type": "move", "move": "e2e4", "ply": 0}`, "", true},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newSession(nil, false, "", handlers.StartPosition())
+			var lines []string
+			for _, st := range tt.steps {
+				lines = append(lines, st.line)
+			}
+			s.scanner = bufio.NewScanner(strings.NewReader(strings.Join(lines, "\n")))
+			for _, st := range tt.steps {
+				msg, err := s.read()
+				if err != nil {
+					t.Fatalf("read %s: %v", st.line, err)
+				}
+				event, ok, fatal := s.handle(msg)
+				if (fatal != nil) != st.fatal {
+					t.Fatalf("%s: fatal error %v, want one: %v", st.line, fatal, st.fatal)
+				}
+				got := ""
+				if ok {
+					got = event.Type
+				}
+				if got != st.event {
+					t.Errorf("%s: event %q, want %q", st.line, got, st.event)
+				}
+			}
+		})
+	}
+}
+
+// TestCrossingMessages has Black ask or offer something while White's move is on
+// its way. White answered after moving, so the answer still counts.
+func TestCrossingMessages(t *testing.T) {
+	tests := []struct {
+		name   string
+		ask    func(s *Session) error
+		answer string
+		event  string
+		ply    int // the ply afterwards
+		over   bool
+	}{
+		{"takeback", (*Session).RequestTakeback, `{"type": "takeback_accept", "ply": 1}`, TypeTakebackAccept, 1, false},
+		{"draw", func(s *Session) error {
+			_, err := s.OfferDraw()
+			return err
+		}, `{"type": "draw_accept"}`, TypeDrawAccept, 3, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, other := net.Pipe()
+			defer conn.Close()
+			go io.Copy(io.Discard, other)
+			s := newSession(conn, false, "", handlers.StartPosition())
+			s.scanner = bufio.NewScanner(strings.NewReader(strings.Join([]string{
+				`{"type": "move", "move": "e2e4", "ply": 0}`,
+				`{"type": "move", "move": "g1f3", "ply": 2}`,
+				tt.answer,
+			}, "\n")))
+			receive := func() Event {
+				t.Helper()
+				msg, err := s.read()
+				if err != nil {
+					t.Fatal(err)
+				}
+				event, ok, fatal := s.handle(msg)
+				if !ok || fatal != nil {
+					t.Fatalf("%s: no event, fatal error %v", msg.Type, fatal)
+				}
+				return event
+			}
+
+			receive()
+			e5, _ := s.game.Position().ParseUCI("e7e5")
+			if err := s.Move(e5); err != nil {
+				t.Fatal(err)
+			}
+			if err := tt.ask(s); err != nil {
+				t.Fatal(err)
+			}
+			receive()
+			if event := receive(); event.Type != tt.event {
+				t.Errorf("event %q, want %q", event.Type, tt.event)
+			}
+			if s.game.Ply() != tt.ply || s.game.Over() != tt.over {
+				t.Errorf("ply %d, over %v, want %d, %v", s.game.Ply(), s.game.Over(), tt.ply, tt.over)
+			}
+		})
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"invalid JSON", `{"type": "move"` + "\n"},
+		{"line too long", `{"type": "chat", "text": "` + strings.Repeat("a", maxLineBytes) + `"}` + "\n"},
+		{"end of input", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newSession(nil, false, "", handlers.StartPosition())
+			s.scanner = bufio.NewScanner(strings.NewReader(tt.input))
+			s.scanner.Buffer(make([]byte, 4096), maxLineBytes)
+			if msg, err := s.read(); err == nil {
+				t.Errorf("read = %+v, want an error", msg)
+			}
+		})
+	}
+}
+
+func TestTruncateChat(t *testing.T) {
+	long := strings.Repeat("é", maxChatLength+10)
+	if got := []rune(truncateChat(long)); len(got) != maxChatLength {
+		t.Errorf("chat of %d characters cut to %d, want %d", maxChatLength+10, len(got), maxChatLength)
+	}
+	if got := truncateChat("good luck"); got != "good luck" {
+		t.Errorf("truncateChat(good luck) = %q", got)
+	}
+}
+
+// next returns the next event of s, failing the test if none comes.
+func next(t *testing.T, s *Session) Event {
+	t.Helper()
+	select {
+	case event := <-s.Events():
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no event")
+	}
+	return Event{}
+}
+
+func TestGame(t *testing.T) {
+	ln, err := Listen("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	hosted := make(chan *Session)
+	go func() {
+		host, err := ln.Accept("Bob", handlers.StartPosition(), true)
+		if err != nil {
+			t.Error(err)
+		}
+		hosted <- host
+	}()
+	guest, err := Join(ln.Addr().String(), "Alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	host := <-hosted
+	if host == nil {
+		t.FailNow()
+	}
+	if guest.White() || !host.White() || guest.Opponent() != "Bob" || host.Opponent() != "Alice" {
+		t.Fatalf("guest white %v opponent %q, host white %v opponent %q",
+			guest.White(), guest.Opponent(), host.White(), host.Opponent())
+	}
+
+	start := handlers.StartPosition()
+	e4, _ := start.ParseUCI("e2e4")
+	if err := guest.Move(e4); err != ErrNotYourTurn {
+		t.Errorf("guest moving first: %v, want %v", err, ErrNotYourTurn)
+	}
+	if err := host.Move(e4); err != nil {
+		t.Fatal(err)
+	}
+	if event := next(t, guest); event.Type != TypeMove || event.Move != e4 {
+		t.Fatalf("guest got %+v, want the move e2e4", event)
+	}
+
+	// the host takes the first move back, to ply 0
+	if err := host.RequestTakeback(); err != nil {
+		t.Fatal(err)
+	}
+	if event := next(t, guest); event.Type != TypeTakebackRequest || event.Ply != 0 {
+		t.Fatalf("guest got %+v, want a takeback request to ply 0", event)
+	}
+	if ply, err := guest.AcceptTakeback(); ply != 0 || err != nil {
+		t.Fatalf("AcceptTakeback = %d, %v", ply, err)
+	}
+	if event := next(t, host); event.Type != TypeTakebackAccept || event.Ply != 0 {
+		t.Fatalf("host got %+v, want a takeback accept to ply 0", event)
+	}
+	if err := host.Move(e4); err != nil {
+		t.Fatalf("replaying the move taken back: %v", err)
+	}
+	next(t, guest)
+
+	// the guest plays on instead of answering, which declines the offer
+	if _, err := host.OfferDraw(); err != nil {
+		t.Fatal(err)
+	}
+	if event := next(t, guest); event.Type != TypeDrawOffer {
+		t.Fatalf("guest got %+v, want the draw offer", event)
+	}
+	e5, _ := start.Play(e4).ParseUCI("e7e5")
+	if err := guest.Move(e5); err != nil {
+		t.Fatal(err)
+	}
+	if event := next(t, host); event.Type != TypeDrawDecline {
+		t.Errorf("host got %+v, want the draw declined", event)
+	}
+	if event := next(t, host); event.Type != TypeMove || event.Move != e5 {
+		t.Fatalf("host got %+v, want the move e7e5", event)
+	}
+
+	if err := guest.Chat("good luck"); err != nil {
+		t.Fatal(err)
+	}
+	if event := next(t, host); event.Type != TypeChat || event.Text != "good luck" {
+		t.Errorf("host got %+v, want the chat", event)
+	}
+
+	guest.Close()
+	if event := next(t, host); event.Type != TypeClosed || event.Err != nil {
+		t.Errorf("host got %+v, want a goodbye", event)
+	}
+	if event := next(t, guest); event.Type != TypeClosed || event.Err != ErrClosed {
+		t.Errorf("guest got %+v, want its own close", event)
+	}
+}
@@ -0,0 +1,366 @@
+package main
+
+import (
+	"chess-engine/game"
+	"chess-engine/handlers"
+	"chess-engine/lan"
+	"chess-engine/player"
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+const lanPanelHeight = 160
+
+var (
+	// lanSession is the connection to the opponent of a game over the network, and
+	// lanGame the game it plays; both are nil otherwise.
+	lanSession *lan.Session
+	lanGame    *game.Game
+
+	lanPanel   *fyne.Container
+	lanStatus  *widget.Label
+	lanButtons *fyne.Container
+	chatLog    *widget.Label
+	chatScroll *container.Scroll
+)
+
+// isRemoteSide reports whether the side is played by the opponent over the network.
+func isRemoteSide(white bool) bool {
+	return lanSession != nil && white != lanSession.White()
+}
+
+// isEngineSide reports whether the computer plays the side: neither the player at
+// the board nor an opponent over the network does.
+func isEngineSide(white bool) bool {
+	return !isHumanSide(white) && !isRemoteSide(white)
+}
+
+// lanName is the name the player is known by to opponents over the network.
+func lanName() string {
+	return fyne.CurrentApp().Preferences().StringWithFallback("lanName", "Player")
+}
+
+// newLANPanel builds the panel of a network game: its status, draw and takeback
+// buttons, and the chat. It is hidden until a game over the network starts.
+func newLANPanel() fyne.CanvasObject {
+	lanStatus = widget.NewLabel("")
+	lanStatus.Wrapping = fyne.TextWrapWord
+	drawButton := widget.NewButton("Offer draw", offerLANDraw)
+	takebackButton := widget.NewButton("Takeback", requestLANTakeback)
+	lanButtons = container.NewGridWithColumns(2, drawButton, takebackButton)
+
+	chatLog = widget.NewLabel("")
+	chatLog.Wrapping = fyne.TextWrapWord
+	chatScroll = container.NewVScroll(chatLog)
+	chatScroll.SetMinSize(fyne.NewSize(0, lanPanelHeight))
+	chatEntry := widget.NewEntry()
+	chatEntry.SetPlaceHolder("Say something")
+	chatEntry.OnSubmitted = func(text string) {
+		text = strings.TrimSpace(text)
+		if text == "" || lanSession == nil {
+			return
+		}
+		if err := lanSession.Chat(text); err != nil {
+			addChat("Not sent: " + err.Error())
+			return
+		}
+		addChat("You: " + text)
+		chatEntry.SetText("")
+	}
+
+	lanPanel = container.NewVBox(widget.NewSeparator(), lanStatus, lanButtons, chatScroll, chatEntry)
+	lanPanel.Hide()
+	return lanPanel
+}
+
+func addChat(line string) {
+	if chatLog.Text != "" {
+		line = chatLog.Text + "\n" + line
+	}
+	chatLog.SetText(line)
+	chatScroll.ScrollToBottom()
+}
+
+// showHostDialog opens a game for a player on the local network to join.
+func showHostDialog() {
+	nameEntry := widget.NewEntry()
+	nameEntry.SetText(lanName())
+	portEntry := widget.NewEntry()
+	portEntry.SetText(strconv.Itoa(lan.DefaultPort))
+	colorSelect := widget.NewSelect([]string{"White", "Black", "Random"}, nil)
+	colorSelect.SetSelected("White")
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("Your name", nameEntry),
+		widget.NewFormItem("Port", portEntry),
+		widget.NewFormItem("Play as", colorSelect),
+	}
+	dialog.ShowForm("Host LAN Game", "Host", "Cancel", items, func(confirmed bool) {
+		if !confirmed {
+			return
+		}
+		port, err := strconv.Atoi(portEntry.Text)
+		if err != nil || port <= 0 || port > 65535 {
+			dialog.ShowError(fmt.Errorf("invalid port %q", portEntry.Text), chessWindow)
+			return
+		}
+		hostWhite := colorSelect.Selected == "White" || colorSelect.Selected == "Random" && rand.Intn(2) == 0
+		fyne.CurrentApp().Preferences().SetString("lanName", nameEntry.Text)
+		hostLANGame(nameEntry.Text, port, hostWhite)
+	}, chessWindow)
+}
+
+// hostLANGame waits for a guest to join on port, showing the addresses to join at.
+func hostLANGame(name string, port int, hostWhite bool) {
+	listener, err := lan.Listen(fmt.Sprintf(":%d", port))
+	if err != nil {
+		dialog.ShowError(err, chessWindow)
+		return
+	}
+	addresses := localAddresses(port)
+	message := "Waiting for an opponent to join at\n" + strings.Join(addresses, "\n")
+	waiting := dialog.NewCustom("Host LAN Game", "Cancel", widget.NewLabel(message), chessWindow)
+	// cancelled is set by whichever comes first, the player cancelling or a guest joining
+	var cancelled atomic.Bool
+	waiting.SetOnClosed(func() {
+		cancelled.Store(true)
+		listener.Close()
+	})
+	waiting.Show()
+
+	go func() {
+		session, err := listener.Accept(name, handlers.StartPosition(), hostWhite)
+		if !cancelled.CompareAndSwap(false, true) {
+			if session != nil {
+				session.Close()
+			}
+			return
+		}
+		listener.Close()
+		fyne.Do(func() {
+			waiting.Hide()
+			if err != nil {
+				dialog.ShowError(err, chessWindow)
+				return
+			}
+			startLANGame(session)
+		})
+	}()
+}
+
+// localAddresses lists the addresses of this machine on the local network.
+func localAddresses(port int) []string {
+	var addresses []string
+	if addrs, err := net.InterfaceAddrs(); err == nil {
+		for _, addr := range addrs {
+			if ip, ok := addr.(*net.IPNet); ok && ip.IP.To4() != nil && !ip.IP.IsLoopback() {
+				addresses = append(addresses, net.JoinHostPort(ip.IP.String(), strconv.Itoa(port)))
+			}
+		}
+	}
+	if len(addresses) == 0 {
+		addresses = []string{net.JoinHostPort("localhost", strconv.Itoa(port))}
+	}
+	return addresses
+}
+
+// showJoinDialog joins a game hosted on the local network.
+func showJoinDialog() {
+	nameEntry := widget.NewEntry()
+	nameEntry.SetText(lanName())
+	addressEntry := widget.NewEntry()
+	addressEntry.SetText(fyne.CurrentApp().Preferences().String("lanHost"))
+	addressEntry.SetPlaceHolder("192.168.1.20:" + strconv.Itoa(lan.DefaultPort))
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("Your name", nameEntry),
+		widget.NewFormItem("Host", addressEntry),
+	}
+	dialog.ShowForm("Join LAN Game", "Join", "Cancel", items, func(confirmed bool) {
+		if !confirmed {
+			return
+		}
+		address := strings.TrimSpace(addressEntry.Text)
+		if _, _, err := net.SplitHostPort(address); err != nil {
+			address = net.JoinHostPort(address, strconv.Itoa(lan.DefaultPort))
+		}
+		prefs := fyne.CurrentApp().Preferences()
+		prefs.SetString("lanName", nameEntry.Text)
+		prefs.SetString("lanHost", addressEntry.Text)
+
+		connecting := dialog.NewCustomWithoutButtons("Join LAN Game", widget.NewLabel("Connecting to "+address+"..."), chessWindow)
+		connecting.Show()
+		go func() {
+			session, err := lan.Join(address, nameEntry.Text)
+			fyne.Do(func() {
+				connecting.Hide()
+				if err != nil {
+					dialog.ShowError(err, chessWindow)
+					return
+				}
+				startLANGame(session)
+			})
+		}()
+	}, chessWindow)
+}
+
+// startLANGame starts the game of a new connection, with the player's side at the
+// bottom of the board and no clocks.
+func startLANGame(session *lan.Session) {
+	closeLANGame()
+	lanSession = session
+	lanOpponent = player.NewRemote(session, func(event lan.Event) {
+		fyne.Do(func() {
+			// a game left for another one drains its last events
+			if session == lanSession {
+				handleLANEvent(session, event)
+			}
+		})
+	})
+	humanWhite, humanBlack = session.White(), !session.White()
+	boardFlipped = !session.White()
+	applyOrientation()
+	lanGame = newGameFrom(session.Start())
+	lanGame.Tags["Event"] = "LAN game"
+
+	lanStatus.SetText(fmt.Sprintf("Playing %s over the network, you have %s.", session.Opponent(), colorName(session.White())))
+	chatLog.SetText("")
+	lanButtons.Show()
+	lanPanel.Show()
+	sidePanel.Refresh()
+	startGame(lanGame)
+}
+
+func colorName(white bool) string {
+	if white {
+		return "White"
+	}
+	return "Black"
+}
+
+// closeLANGame leaves the game over the network, if one is being played, and puts
+// its panel away.
+func closeLANGame() {
+	if session := lanSession; session != nil {
+		lanSession, lanGame, lanOpponent = nil, nil, nil
+		session.Close()
+	}
+	if lanPanel != nil && lanPanel.Visible() {
+		lanPanel.Hide()
+		sidePanel.Refresh()
+	}
+}
+
+// handleLANEvent carries out what the opponent does besides moving; lanOpponent
+// passes their moves to the controller.
+func handleLANEvent(session *lan.Session, event lan.Event) {
+	opponent := session.Opponent()
+	switch event.Type {
+	case lan.TypeError:
+		addChat("The move could not be sent: " + event.Err.Error())
+	case lan.TypeDrawOffer:
+		addChat(opponent + " offers a draw.")
+		dialog.ShowConfirm("Draw Offer", opponent+" offers a draw. Accept?", func(accepted bool) {
+			if session != lanSession {
+				return
+			}
+			if !accepted {
+				session.DeclineDraw()
+				return
+			}
+			if session.AcceptDraw() == nil && !currentGame.Over() {
+				agreeLANDraw()
+			}
+		}, chessWindow)
+	case lan.TypeDrawAccept:
+		agreeLANDraw()
+	case lan.TypeDrawDecline:
+		addChat(opponent + " declines the draw.")
+	case lan.TypeResign:
+		stopPlay()
+		currentGame.Resign(!session.White())
+		showGameOverDialog()
+	case lan.TypeTakebackRequest:
+		addChat(opponent + " asks for a takeback.")
+		dialog.ShowConfirm("Takeback", opponent+" asks to take back their last move. Allow it?", func(allowed bool) {
+			if session != lanSession {
+				return
+			}
+			if !allowed {
+				session.DeclineTakeback()
+				return
+			}
+			if ply, err := session.AcceptTakeback(); err == nil && ply >= 0 {
+				takeBackTo(ply)
+			}
+		}, chessWindow)
+	case lan.TypeTakebackAccept:
+		addChat(opponent + " allows the takeback.")
+		takeBackTo(event.Ply)
+	case lan.TypeTakebackDecline:
+		addChat(opponent + " declines the takeback.")
+	case lan.TypeChat:
+		addChat(opponent + ": " + event.Text)
+	case lan.TypeClosed:
+		lanSession, lanGame, lanOpponent = nil, nil, nil
+		lanButtons.Hide()
+		status := opponent + " left the game."
+		if event.Err != nil {
+			status = "The connection was lost: " + event.Err.Error()
+		}
+		lanStatus.SetText(status + " The computer plays their side from here.")
+		addChat(status)
+		resumePlay()
+	}
+}
+
+// takeBackTo goes back to the position after ply half-moves, as both sides agreed.
+func takeBackTo(ply int) {
+	stopPlay()
+	currentGame.Truncate(ply)
+	showPly(ply)
+	refreshHistory()
+	resumePlay()
+}
+
+// agreeLANDraw ends the game in the draw both sides agreed to.
+func agreeLANDraw() {
+	stopPlay()
+	currentGame.AgreeDraw()
+	showGameOverDialog()
+}
+
+func offerLANDraw() {
+	if lanSession == nil {
+		return
+	}
+	agreed, err := lanSession.OfferDraw()
+	switch {
+	case err != nil:
+		addChat(err.Error())
+	case agreed:
+		agreeLANDraw()
+	default:
+		addChat("You offer a draw.")
+	}
+}
+
+func requestLANTakeback() {
+	if lanSession == nil {
+		return
+	}
+	if err := lanSession.RequestTakeback(); err != nil {
+		addChat(err.Error())
+		return
+	}
+	addChat("You ask to take back your last move.")
+}
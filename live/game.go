@@ -0,0 +1,377 @@
+package live
+
+import (
+	"chess-engine/clock"
+	"chess-engine/game"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Role is how a connection takes part in a game.
+type Role string
+
+const (
+	White     Role = "white"
+	Black     Role = "black"
+	Spectator Role = "spectator"
+)
+
+var (
+	ErrNoGame    = errors.New("no such game")
+	ErrBadRole   = errors.New(`role must be "white", "black" or "spectator"`)
+	ErrSeatTaken = errors.New("the seat is taken; reconnect with its token")
+)
+
+// outboxSize is how many messages may wait for a slow client. A client that falls
+// further behind is disconnected; when it reconnects it gets the whole state again.
+const outboxSize = 64
+
+// liveGame is a game being played over connections: two seats and any number of
+// spectators. The server owns the clock, so what clients show is only a display of it.
+type liveGame struct {
+	mu         sync.Mutex
+	id         string
+	game       *game.Game
+	clock      *clock.Clock // nil for an untimed game
+	flagTimer  *time.Timer
+	seats      [2]seat // White, Black
+	spectators map[*Conn]bool
+	drawOffer  Role
+
+	// lastUsed is when a client last connected, sent a command or fetched the
+	// state; the server drops games by it
+	lastUsed time.Time
+	now      func() time.Time
+}
+
+// seat is a player's place in a game. The token proves a reconnecting client is the
+// same player; the seat stays theirs while they are away.
+type seat struct {
+	token string
+	conn  *Conn
+}
+
+// Conn is one client's connection to a game. The WebSocket handler wraps one around
+// every socket, and in-process clients, such as tests, use it directly.
+type Conn struct {
+	g      *liveGame
+	role   Role
+	out    chan Message
+	closed bool // guarded by g.mu
+}
+
+// Updates delivers the messages the server sends to the client: a welcome first,
+// then the state after every change. It is closed when the connection is.
+func (c *Conn) Updates() <-chan Message {
+	return c.out
+}
+
+// Role returns how the connection takes part in the game.
+func (c *Conn) Role() Role {
+	return c.role
+}
+
+// Send carries out a command from the client. Errors are answered on Updates.
+func (c *Conn) Send(cmd Command) {
+	c.g.mu.Lock()
+	defer c.g.mu.Unlock()
+	if c.closed {
+		return
+	}
+	c.g.lastUsed = c.g.now()
+	c.g.handle(c, cmd)
+}
+
+// reject answers a message that is not a command.
+func (c *Conn) reject(code, message string) {
+	c.g.mu.Lock()
+	defer c.g.mu.Unlock()
+	if !c.closed {
+		c.g.reply(c, code, message)
+	}
+}
+
+// Close drops the connection. A player keeps the seat and can reconnect with its token.
+func (c *Conn) Close() {
+	c.g.mu.Lock()
+	defer c.g.mu.Unlock()
+	if c.closed {
+		return
+	}
+	c.g.drop(c)
+	c.g.broadcast()
+}
+
+func newToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func seatIndex(role Role) int {
+	if role == White {
+		return 0
+	}
+	return 1
+}
+
+// join connects a client in the given role. An empty seat is given to the first
+// player asking for it; after that only its token opens it, and the connection it
+// replaces is dropped.
+func (g *liveGame) join(role Role, token string) (*Conn, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.lastUsed = g.now()
+	c := &Conn{g: g, role: role, out: make(chan Message, outboxSize)}
+	switch role {
+	case Spectator:
+		g.spectators[c] = true
+	case White, Black:
+		s := &g.seats[seatIndex(role)]
+		switch {
+		case s.token == "":
+			s.token = newToken()
+		case token != s.token:
+			return nil, ErrSeatTaken
+		}
+		if s.conn != nil {
+			g.drop(s.conn)
+		}
+		s.conn = c
+		token = s.token
+	default:
+		return nil, ErrBadRole
+	}
+	state := g.state()
+	c.out <- Message{Type: "welcome", Role: role, Token: token, State: &state}
+	g.broadcast()
+	return c, nil
+}
+
+// drop closes c and frees its place; g.mu is held.
+func (g *liveGame) drop(c *Conn) {
+	c.closed = true
+	close(c.out)
+	delete(g.spectators, c)
+	for i := range g.seats {
+		if g.seats[i].conn == c {
+			g.seats[i].conn = nil
+		}
+	}
+}
+
+// close stops the clocks and drops every connection, for a game the server no
+// longer keeps; g.mu is held.
+func (g *liveGame) close() {
+	g.finish()
+	for _, c := range g.conns() {
+		g.drop(c)
+	}
+}
+
+func (g *liveGame) conns() []*Conn {
+	var conns []*Conn
+	for _, s := range g.seats {
+		if s.conn != nil {
+			conns = append(conns, s.conn)
+		}
+	}
+	for c := range g.spectators {
+		conns = append(conns, c)
+	}
+	return conns
+}
+
+// broadcast sends the state to every connection; g.mu is held. A client whose
+// outbox is full is dropped rather than holding up the game.
+func (g *liveGame) broadcast() {
+	state := g.state()
+	for _, c := range g.conns() {
+		select {
+		case c.out <- Message{Type: "state", State: &state}:
+		default:
+			g.drop(c)
+		}
+	}
+}
+
+// reply sends an error to c alone; g.mu is held.
+func (g *liveGame) reply(c *Conn, code, message string) {
+	select {
+	case c.out <- Message{Type: "error", Error: &Error{Code: code, Message: message}}:
+	default:
+		g.drop(c)
+	}
+}
+
+func (g *liveGame) handle(c *Conn, cmd Command) {
+	if cmd.Type == "sync" {
+		state := g.state()
+		select {
+		case c.out <- Message{Type: "state", State: &state}:
+		default:
+			g.drop(c)
+		}
+		return
+	}
+	if c.role == Spectator {
+		g.reply(c, CodeSpectator, "spectators cannot play")
+		return
+	}
+	g.checkFlag()
+	if g.game.Over() {
+		g.reply(c, CodeGameOver, "the game is over")
+		return
+	}
+
+	switch cmd.Type {
+	case "move":
+		g.move(c, cmd.Move)
+	case "resign":
+		g.game.Resign(c.role == White)
+		g.finish()
+	case "draw":
+		// offering when the opponent has offered accepts
+		if g.drawOffer != "" && g.drawOffer != c.role {
+			g.game.AgreeDraw()
+			g.finish()
+		} else {
+			g.drawOffer = c.role
+		}
+	case "decline":
+		if g.drawOffer != "" && g.drawOffer != c.role {
+			g.drawOffer = ""
+		}
+	default:
+		g.reply(c, CodeBadCommand, "unknown command "+cmd.Type)
+		return
+	}
+	g.broadcast()
+}
+
+// move plays the move of the player on c, in UCI or SAN.
+func (g *liveGame) move(c *Conn, text string) {
+	pos := g.game.Position()
+	if (c.role == White) != pos.WhiteTurn {
+		g.reply(c, CodeNotYourTurn, "it is not your turn")
+		return
+	}
+	m, err := pos.ParseMove(text)
+	if err != nil {
+		g.reply(c, CodeIllegalMove, err.Error())
+		return
+	}
+	if err := g.game.Play(m); err != nil {
+		g.reply(c, CodeIllegalMove, err.Error())
+		return
+	}
+	if g.drawOffer != c.role {
+		g.drawOffer = ""
+	}
+
+	if g.clock != nil {
+		// the first move is free: the clock starts with the reply
+		if g.clock.Running() {
+			g.clock.Press()
+		} else {
+			g.clock.Start(!pos.WhiteTurn)
+		}
+	}
+	if g.game.Over() {
+		g.finish()
+		return
+	}
+	g.scheduleFlag()
+}
+
+// scheduleFlag sets the timer that ends the game when the running clock runs out,
+// since a player out of time may simply never move again; g.mu is held.
+func (g *liveGame) scheduleFlag() {
+	if g.clock == nil || !g.clock.Running() {
+		return
+	}
+	if g.flagTimer != nil {
+		g.flagTimer.Stop()
+	}
+	white, _ := g.clock.Flagged()
+	g.flagTimer = time.AfterFunc(g.clock.Remaining(white)+time.Millisecond, func() {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		if g.checkFlag() {
+			g.broadcast()
+		} else {
+			g.scheduleFlag()
+		}
+	})
+}
+
+// checkFlag ends the game if the side to move is out of time and reports whether it
+// did; g.mu is held.
+func (g *liveGame) checkFlag() bool {
+	if g.clock == nil || g.game.Over() {
+		return false
+	}
+	if white, flagged := g.clock.Flagged(); flagged && g.clock.Running() {
+		g.game.Flag(white)
+		g.finish()
+		return true
+	}
+	return false
+}
+
+// finish stops the clocks of a game that ended; g.mu is held.
+func (g *liveGame) finish() {
+	g.drawOffer = ""
+	if g.clock != nil {
+		g.clock.Stop()
+	}
+	if g.flagTimer != nil {
+		g.flagTimer.Stop()
+	}
+}
+
+func (g *liveGame) state() State {
+	pos := g.game.Position()
+	st := State{
+		ID:             g.id,
+		FEN:            pos.FEN(),
+		Turn:           string(Black),
+		Check:          pos.InCheck(),
+		Moves:          g.game.SAN(),
+		UCIMoves:       []string{},
+		Result:         string(g.game.Result()),
+		Reason:         string(g.game.Reason()),
+		DrawOffer:      string(g.drawOffer),
+		WhiteConnected: g.seats[0].conn != nil,
+		BlackConnected: g.seats[1].conn != nil,
+		Spectators:     len(g.spectators),
+	}
+	if pos.WhiteTurn {
+		st.Turn = string(White)
+	}
+	if st.Moves == nil {
+		st.Moves = []string{}
+	}
+	for _, m := range g.game.Moves() {
+		st.UCIMoves = append(st.UCIMoves, m.String())
+	}
+	if ply := g.game.Ply(); ply > 0 {
+		st.LastMove = &Move{UCI: st.UCIMoves[ply-1], SAN: st.Moves[ply-1]}
+	}
+	if g.clock != nil {
+		st.Clock = &ClockState{
+			WhiteMS: g.clock.Remaining(true).Milliseconds(),
+			BlackMS: g.clock.Remaining(false).Milliseconds(),
+		}
+		if white, _ := g.clock.Flagged(); g.clock.Running() {
+			st.Clock.Running = string(Black)
+			if white {
+				st.Clock.Running = string(White)
+			}
+		}
+	}
+	return st
+}
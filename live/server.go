@@ -0,0 +1,346 @@
+// Package live serves real-time games over WebSocket. Two players join a game and
+// every connection, spectators included, is pushed the state after each move; the
+// server keeps the clocks and ends the game when one runs out.
+//
+// Routes:
+//
+//	POST /games                                     create a game, {"fen": "...", "time_control": "5+3"}, both optional
+//	GET  /games/{id}                                the game's state
+//	GET  /games/{id}/ws?role=white&token=...        join over WebSocket
+//	DELETE /games/{id}                              drop the game and close its connections
+//
+// A client joins as white, black or spectator. The first player to take a seat is
+// sent a token in the welcome message; reconnecting with it takes the seat back and
+// resends the whole state. Clients send commands as JSON:
+//
+//	{"type": "move", "move": "e2e4"}   a move in UCI or SAN
+//	{"type": "draw"}                   offer a draw, or accept the opponent's offer
+//	{"type": "decline"}                decline the opponent's draw offer
+//	{"type": "resign"}
+//	{"type": "sync"}                   ask for the state again
+//
+// and receive {"type": "welcome" | "state" | "error", ...} messages. Clocks are sent
+// as the time left when the state was taken; the first move of each game is free.
+//
+// A game nobody uses is dropped after a while, see FinishedTTL and IdleTTL.
+package live
+
+import (
+	"chess-engine/clock"
+	"chess-engine/game"
+	"chess-engine/handlers"
+	"chess-engine/logging"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+var logger = logging.For(logging.Live)
+
+// MaxGames is how many games the server keeps at once.
+const MaxGames = 10000
+
+// Games are dropped when nobody has used them for a while: a finished game after
+// FinishedTTL and a game in progress after IdleTTL. Connecting, sending a command and
+// fetching the state all count as use.
+const (
+	FinishedTTL = time.Hour
+	IdleTTL     = 24 * time.Hour
+)
+
+// Error codes, sent in error messages and error responses.
+const (
+	CodeBadRequest   = "bad_request"
+	CodeBadCommand   = "bad_command"
+	CodeInvalidFEN   = "invalid_fen"
+	CodeInvalidClock = "invalid_time_control"
+	CodeNotFound     = "not_found"
+	CodeBadRole      = "bad_role"
+	CodeSeatTaken    = "seat_taken"
+	CodeSpectator    = "spectator"
+	CodeNotYourTurn  = "not_your_turn"
+	CodeIllegalMove  = "illegal_move"
+	CodeGameOver     = "game_over"
+	CodeTooManyGames = "too_many_games"
+)
+
+// Command is a message from a client.
+type Command struct {
+	Type string `json:"type"`
+	Move string `json:"move,omitempty"`
+}
+
+// Message is a message to a client. Token is only sent to a player, in its welcome.
+type Message struct {
+	Type  string `json:"type"`
+	Role  Role   `json:"role,omitempty"`
+	Token string `json:"token,omitempty"`
+	State *State `json:"state,omitempty"`
+	Error *Error `json:"error,omitempty"`
+}
+
+// Error describes a rejected command or request.
+type Error struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Move is a move in both notations.
+type Move struct {
+	UCI string `json:"uci"`
+	SAN string `json:"san"`
+}
+
+// State is everything a client needs to show the game.
+type State struct {
+	ID             string      `json:"id"`
+	FEN            string      `json:"fen"`
+	Turn           string      `json:"turn"`
+	Check          bool        `json:"check"`
+	Moves          []string    `json:"moves"`
+	UCIMoves       []string    `json:"uci_moves"`
+	LastMove       *Move       `json:"last_move,omitempty"`
+	Result         string      `json:"result"`
+	Reason         string      `json:"reason,omitempty"`
+	DrawOffer      string      `json:"draw_offer,omitempty"`
+	Clock          *ClockState `json:"clock,omitempty"`
+	WhiteConnected bool        `json:"white_connected"`
+	BlackConnected bool        `json:"black_connected"`
+	Spectators     int         `json:"spectators"`
+}
+
+// ClockState is the time left on both clocks and the side whose clock runs, "" while stopped.
+type ClockState struct {
+	WhiteMS int64  `json:"white_ms"`
+	BlackMS int64  `json:"black_ms"`
+	Running string `json:"running,omitempty"`
+}
+
+// Server holds the live games. It is an http.Handler for the routes above, and
+// CreateGame and Connect let in-process clients play without a network.
+type Server struct {
+	mux *http.ServeMux
+
+	mu    sync.Mutex
+	games map[string]*liveGame
+
+	// now is the time source; tests replace it to expire games without waiting.
+	now func() time.Time
+}
+
+// NewServer returns a server with no games.
+func NewServer() *Server {
+	s := &Server{mux: http.NewServeMux(), games: map[string]*liveGame{}, now: time.Now}
+	s.mux.HandleFunc("POST /games", s.createGame)
+	s.mux.HandleFunc("GET /games/{id}", s.getGame)
+	s.mux.HandleFunc("DELETE /games/{id}", s.deleteGame)
+	// any origin may connect: the web front end is served from elsewhere
+	s.mux.Handle("GET /games/{id}/ws", websocket.Server{Handler: s.serveSocket})
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// CreateGame starts a game from fen, the standard position if empty, with the time
+// control written as clock.Parse reads it, untimed if empty. It returns the game's id.
+func (s *Server) CreateGame(fen, timeControl string) (string, error) {
+	g := &liveGame{id: newToken()[:16], game: game.New(), spectators: map[*Conn]bool{}, now: s.now}
+	g.lastUsed = s.now()
+	if fen != "" {
+		pos, err := handlers.ParseFEN(fen)
+		if err == nil {
+			err = pos.Validate()
+		}
+		if err != nil {
+			return "", &requestError{CodeInvalidFEN, err}
+		}
+		g.game = game.NewFromPosition(pos)
+	}
+	if timeControl != "" {
+		tc, err := clock.Parse(timeControl, clock.Increment)
+		if err != nil {
+			return "", &requestError{CodeInvalidClock, err}
+		}
+		g.clock = clock.New(tc)
+		g.game.Tags["TimeControl"] = tc.PGN()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expire()
+	if len(s.games) >= MaxGames {
+		return "", &requestError{CodeTooManyGames, errors.New("the server holds too many games")}
+	}
+	s.games[g.id] = g
+	return g.id, nil
+}
+
+// Connect joins game id in the given role; token reclaims a seat taken before.
+func (s *Server) Connect(id string, role Role, token string) (*Conn, error) {
+	s.mu.Lock()
+	g := s.games[id]
+	s.mu.Unlock()
+	if g == nil {
+		return nil, ErrNoGame
+	}
+	return g.join(role, token)
+}
+
+// RemoveGame drops game id and closes its connections.
+func (s *Server) RemoveGame(id string) error {
+	s.mu.Lock()
+	g := s.games[id]
+	delete(s.games, id)
+	s.mu.Unlock()
+	if g == nil {
+		return ErrNoGame
+	}
+	g.mu.Lock()
+	g.close()
+	g.mu.Unlock()
+	return nil
+}
+
+// expire drops the games that have not been used for longer than their TTL; s.mu is held.
+func (s *Server) expire() {
+	now := s.now()
+	for id, g := range s.games {
+		g.mu.Lock()
+		ttl := IdleTTL
+		if g.game.Over() {
+			ttl = FinishedTTL
+		}
+		if now.Sub(g.lastUsed) > ttl {
+			delete(s.games, id)
+			g.close()
+		}
+		g.mu.Unlock()
+	}
+}
+
+// requestError is a failed request with the code to report it under.
+type requestError struct {
+	code string
+	err  error
+}
+
+func (e *requestError) Error() string { return e.err.Error() }
+
+func (e *requestError) Unwrap() error { return e.err }
+
+// errorCode returns the code for an error of CreateGame or Connect.
+func errorCode(err error) (code string, status int) {
+	var re *requestError
+	switch {
+	case errors.As(err, &re) && re.code == CodeTooManyGames:
+		return re.code, http.StatusServiceUnavailable
+	case errors.As(err, &re):
+		return re.code, http.StatusBadRequest
+	case errors.Is(err, ErrNoGame):
+		return CodeNotFound, http.StatusNotFound
+	case errors.Is(err, ErrBadRole):
+		return CodeBadRole, http.StatusBadRequest
+	case errors.Is(err, ErrSeatTaken):
+		return CodeSeatTaken, http.StatusConflict
+	}
+	return CodeBadRequest, http.StatusBadRequest
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	code, status := errorCode(err)
+	writeJSON(w, status, map[string]Error{"error": {code, err.Error()}})
+}
+
+func (s *Server) createGame(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		FEN         string `json:"fen"`
+		TimeControl string `json:"time_control"`
+	}
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&body)
+	if err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, fmt.Errorf("invalid JSON body: %w", err))
+		return
+	}
+	id, err := s.CreateGame(body.FEN, body.TimeControl)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Location", "/games/"+id)
+	writeJSON(w, http.StatusCreated, map[string]string{"id": id})
+}
+
+func (s *Server) getGame(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	g := s.games[r.PathValue("id")]
+	s.mu.Unlock()
+	if g == nil {
+		writeError(w, ErrNoGame)
+		return
+	}
+	g.mu.Lock()
+	g.lastUsed = s.now()
+	state := g.state()
+	g.mu.Unlock()
+	writeJSON(w, http.StatusOK, state)
+}
+
+func (s *Server) deleteGame(w http.ResponseWriter, r *http.Request) {
+	if err := s.RemoveGame(r.PathValue("id")); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// serveSocket connects a WebSocket client: one goroutine writes the messages for
+// it while this one reads its commands, until either side closes.
+func (s *Server) serveSocket(ws *websocket.Conn) {
+	defer ws.Close()
+	r := ws.Request()
+	c, err := s.Connect(r.PathValue("id"), Role(r.URL.Query().Get("role")), r.URL.Query().Get("token"))
+	if err != nil {
+		logger.Debug("connection refused", "game", r.PathValue("id"), "err", err)
+		code, _ := errorCode(err)
+		websocket.JSON.Send(ws, Message{Type: "error", Error: &Error{code, err.Error()}})
+		return
+	}
+	defer c.Close()
+	logger.Debug("client connected", "game", r.PathValue("id"), "role", r.URL.Query().Get("role"), "addr", r.RemoteAddr)
+
+	go func() {
+		for msg := range c.Updates() {
+			if websocket.JSON.Send(ws, msg) != nil {
+				break
+			}
+		}
+		// dropped by the server, or replaced by a reconnection
+		ws.Close()
+	}()
+	for {
+		var cmd Command
+		if err := websocket.JSON.Receive(ws, &cmd); err != nil {
+			var syntaxErr *json.SyntaxError
+			if errors.As(err, &syntaxErr) {
+				c.reject(CodeBadCommand, "invalid JSON: "+err.Error())
+				continue
+			}
+			return
+		}
+		c.Send(cmd)
+	}
+}
@@ -0,0 +1,398 @@
+package live
+
+import (
+	"chess-engine/game"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// next returns the next message on c, failing the test if none comes.
+func next(t *testing.T, c *Conn) Message {
+	t.Helper()
+	select {
+	case msg, ok := <-c.Updates():
+		if !ok {
+			t.Fatalf("the %s connection was closed", c.Role())
+		}
+		return msg
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no message for %s", c.Role())
+	}
+	return Message{}
+}
+
+// connect joins game id and reads the welcome and the state broadcast after it.
+func connect(t *testing.T, s *Server, id string, role Role, token string) (*Conn, string) {
+	t.Helper()
+	c, err := s.Connect(id, role, token)
+	if err != nil {
+		t.Fatalf("Connect(%s): %v", role, err)
+	}
+	t.Cleanup(c.Close)
+	welcome := next(t, c)
+	if welcome.Type != "welcome" || welcome.Role != role {
+		t.Fatalf("first message %+v, want a welcome as %s", welcome, role)
+	}
+	next(t, c)
+	return c, welcome.Token
+}
+
+// drain reads the messages already waiting on c and returns the last.
+func drain(c *Conn) Message {
+	var last Message
+	for {
+		select {
+		case msg, ok := <-c.Updates():
+			if !ok {
+				return last
+			}
+			last = msg
+		default:
+			return last
+		}
+	}
+}
+
+// closed reports whether the server has closed c, after reading what is waiting.
+func closed(c *Conn) bool {
+	for {
+		select {
+		case _, ok := <-c.Updates():
+			if !ok {
+				return true
+			}
+		default:
+			return false
+		}
+	}
+}
+
+func TestCommands(t *testing.T) {
+	type step struct {
+		role  Role
+		cmd   Command
+		code  string // the error code, "" for a success
+		field string // a field of the state to check, "" for none
+		value string
+	}
+	tests := []struct {
+		name  string
+		fen   string
+		steps []step
+	}{
+		{"moves in both notations", "", []step{
+			{White, Command{Type: "move", Move: "e2e4"}, "", "turn", "black"},
+			{Black, Command{Type: "move", Move: "Nf6"}, "", "moves", "e4 Nf6"},
+			{White, Command{Type: "sync"}, "", "uci_moves", "e2e4 g8f6"},
+		}},
+		{"wrong turn and illegal moves", "", []step{
+			{Black, Command{Type: "move", Move: "e7e5"}, CodeNotYourTurn, "", ""},
+			{White, Command{Type: "move", Move: "e2e5"}, CodeIllegalMove, "", ""},
+			{White, Command{Type: "move", Move: "Ke2"}, CodeIllegalMove, "", ""},
+			{White, Command{Type: "castle"}, CodeBadCommand, "", ""},
+		}},
+		{"spectators watch", "", []step{
+			{Spectator, Command{Type: "move", Move: "e2e4"}, CodeSpectator, "", ""},
+			{Spectator, Command{Type: "sync"}, "", "spectators", "1"},
+		}},
+		{"mate ends the game", "", []step{
+			{White, Command{Type: "move", Move: "f3"}, "", "", ""},
+			{Black, Command{Type: "move", Move: "e5"}, "", "", ""},
+			{White, Command{Type: "move", Move: "g4"}, "", "", ""},
+			{Black, Command{Type: "move", Move: "Qh4#"}, "", "reason", "checkmate"},
+			{White, Command{Type: "move", Move: "a3"}, CodeGameOver, "", ""},
+		}},
+		{"resignation", "", []step{
+			{Black, Command{Type: "resign"}, "", "result", "1-0"},
+			{White, Command{Type: "draw"}, CodeGameOver, "", ""},
+		}},
+		{"draw offer accepted", "", []step{
+			{White, Command{Type: "draw"}, "", "draw_offer", "white"},
+			{Black, Command{Type: "draw"}, "", "result", "1/2-1/2"},
+		}},
+		{"draw offer declined", "", []step{
+			{White, Command{Type: "draw"}, "", "draw_offer", "white"},
+			{White, Command{Type: "decline"}, "", "draw_offer", "white"},
+			{Black, Command{Type: "decline"}, "", "draw_offer", ""},
+		}},
+		{"draw offer lapses when the opponent plays on", "", []step{
+			{White, Command{Type: "draw"}, "", "", ""},
+			{White, Command{Type: "move", Move: "e4"}, "", "draw_offer", "white"},
+			{Black, Command{Type: "move", Move: "e5"}, "", "draw_offer", ""},
+		}},
+		{"from a position", "4k3/8/8/8/8/8/4P3/4K3 b - - 0 1", []step{
+			{Black, Command{Type: "move", Move: "Kd7"}, "", "fen", "8/3k4/8/8/8/8/4P3/4K3 w - - 1 2"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewServer()
+			id, err := s.CreateGame(tt.fen, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			conns := map[Role]*Conn{}
+			for _, role := range []Role{White, Black, Spectator} {
+				conns[role], _ = connect(t, s, id, role, "")
+			}
+			for _, c := range conns {
+				drain(c)
+			}
+			for _, st := range tt.steps {
+				c := conns[st.role]
+				c.Send(st.cmd)
+				msg := next(t, c)
+				code := ""
+				if msg.Error != nil {
+					code = msg.Error.Code
+				}
+				if code != st.code {
+					t.Fatalf("%s %+v: %+v, want error %q", st.role, st.cmd, msg, st.code)
+				}
+				if st.field != "" {
+					if got := stateField(t, msg.State, st.field); got != st.value {
+						t.Errorf("%s %+v: %s = %q, want %q", st.role, st.cmd, st.field, got, st.value)
+					}
+				}
+				for _, c := range conns {
+					drain(c)
+				}
+			}
+		})
+	}
+}
+
+// stateField returns a field of the state as JSON would name it, a list flattened
+// to its items separated by spaces.
+func stateField(t *testing.T, st *State, field string) string {
+	t.Helper()
+	if st == nil {
+		t.Fatalf("no state to read %s from", field)
+	}
+	var fields map[string]any
+	b, _ := json.Marshal(st)
+	json.Unmarshal(b, &fields)
+	switch v := fields[field].(type) {
+	case nil:
+		return ""
+	case []any:
+		var parts []string
+		for _, item := range v {
+			parts = append(parts, fmt.Sprint(item))
+		}
+		return strings.Join(parts, " ")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func TestSeats(t *testing.T) {
+	s := NewServer()
+	id, _ := s.CreateGame("", "")
+	white, token := connect(t, s, id, White, "")
+	if token == "" {
+		t.Fatalf("no token in the welcome")
+	}
+	if _, err := s.Connect(id, White, ""); !errors.Is(err, ErrSeatTaken) {
+		t.Errorf("taking a seat without its token: %v, want %v", err, ErrSeatTaken)
+	}
+	if _, err := s.Connect(id, White, "wrong"); !errors.Is(err, ErrSeatTaken) {
+		t.Errorf("taking a seat with a wrong token: %v, want %v", err, ErrSeatTaken)
+	}
+	if _, err := s.Connect(id, "referee", ""); !errors.Is(err, ErrBadRole) {
+		t.Errorf("joining as a referee: %v, want %v", err, ErrBadRole)
+	}
+	if _, err := s.Connect("nosuchgame", White, ""); !errors.Is(err, ErrNoGame) {
+		t.Errorf("joining a missing game: %v, want %v", err, ErrNoGame)
+	}
+
+	white.Send(Command{Type: "move", Move: "d4"})
+	back, again := connect(t, s, id, White, token)
+	if again != token {
+		t.Errorf("reconnecting changed the token")
+	}
+	if !closed(white) {
+		t.Errorf("the replaced connection is still open")
+	}
+	back.Send(Command{Type: "sync"})
+	if got := stateField(t, next(t, back).State, "moves"); got != "d4" {
+		t.Errorf("moves after reconnecting = %q, want d4", got)
+	}
+
+	// a player who leaves keeps the seat
+	back.Close()
+	black, _ := connect(t, s, id, Black, "")
+	black.Send(Command{Type: "sync"})
+	if st := next(t, black).State; st.WhiteConnected || !st.BlackConnected {
+		t.Errorf("connected = %v, %v, want false, true", st.WhiteConnected, st.BlackConnected)
+	}
+	if _, err := s.Connect(id, White, ""); !errors.Is(err, ErrSeatTaken) {
+		t.Errorf("taking an empty seat without its token: %v, want %v", err, ErrSeatTaken)
+	}
+}
+
+func TestFlag(t *testing.T) {
+	s := NewServer()
+	// 0.002 minutes is 120ms
+	id, err := s.CreateGame("", "0.002")
+	if err != nil {
+		t.Fatal(err)
+	}
+	white, _ := connect(t, s, id, White, "")
+	white.Send(Command{Type: "move", Move: "e4"})
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case msg := <-white.Updates():
+			if msg.State != nil && msg.State.Reason != "" {
+				if msg.State.Result != string(game.WhiteWins) || msg.State.Reason != string(game.Timeout) {
+					t.Errorf("game ended %s (%s), want %s (%s)", msg.State.Result, msg.State.Reason, game.WhiteWins, game.Timeout)
+				}
+				return
+			}
+		case <-deadline:
+			t.Fatalf("black never lost on time")
+		}
+	}
+}
+
+func TestCreateGame(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		code   string
+	}{
+		{"standard", "", 201, ""},
+		{"timed from a FEN", `{"fen": "4k3/8/8/8/8/8/4P3/4K3 w - - 0 1", "time_control": "5+3"}`, 201, ""},
+		{"broken FEN", `{"fen": "rubbish"}`, 400, CodeInvalidFEN},
+		{"impossible position", `{"fen": "8/8/8/8/8/8/8/8 w - - 0 1"}`, 400, CodeInvalidFEN},
+		{"bad time control", `{"time_control": "soon"}`, 400, CodeInvalidClock},
+		{"bad JSON", `{"fen"`, 400, CodeBadRequest},
+	}
+	s := NewServer()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			s.ServeHTTP(w, httptest.NewRequest("POST", "/games", strings.NewReader(tt.body)))
+			var body struct {
+				ID    string `json:"id"`
+				Error Error  `json:"error"`
+			}
+			json.NewDecoder(w.Body).Decode(&body)
+			if w.Code != tt.status || body.Error.Code != tt.code {
+				t.Fatalf("status %d %+v, want %d %q", w.Code, body, tt.status, tt.code)
+			}
+			if w.Code == 201 && w.Header().Get("Location") != "/games/"+body.ID {
+				t.Errorf("Location = %q", w.Header().Get("Location"))
+			}
+		})
+	}
+}
+
+func TestDeleteGame(t *testing.T) {
+	s := NewServer()
+	id, _ := s.CreateGame("", "5+0")
+	white, _ := connect(t, s, id, White, "")
+	status := func(method string) int {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest(method, "/games/"+id, nil))
+		return w.Code
+	}
+
+	if got := status("GET"); got != http.StatusOK {
+		t.Fatalf("GET: status %d", got)
+	}
+	if got := status("DELETE"); got != http.StatusNoContent {
+		t.Fatalf("DELETE: status %d", got)
+	}
+	if !closed(white) {
+		t.Errorf("a connection to the deleted game is still open")
+	}
+	if got := status("GET"); got != http.StatusNotFound {
+		t.Errorf("GET after DELETE: status %d", got)
+	}
+	if got := status("DELETE"); got != http.StatusNotFound {
+		t.Errorf("second DELETE: status %d", got)
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	s := NewServer()
+	s.now = func() time.Time { return now }
+	exists := func(id string) bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.games[id] != nil
+	}
+
+	finished, _ := s.CreateGame("", "")
+	resigner, _ := connect(t, s, finished, White, "")
+	resigner.Send(Command{Type: "resign"})
+	playing, _ := s.CreateGame("", "")
+	player, _ := connect(t, s, playing, White, "")
+
+	// expiry happens when a game is created
+	now = now.Add(FinishedTTL + time.Minute)
+	s.CreateGame("", "")
+	if exists(finished) {
+		t.Errorf("a finished game outlived FinishedTTL")
+	}
+	if !closed(resigner) {
+		t.Errorf("a connection to an expired game is still open")
+	}
+	if !exists(playing) {
+		t.Fatalf("a game in progress was dropped after FinishedTTL")
+	}
+
+	// a command uses the game, so it is kept for another IdleTTL
+	player.Send(Command{Type: "sync"})
+	now = now.Add(IdleTTL - time.Minute)
+	s.CreateGame("", "")
+	if !exists(playing) {
+		t.Fatalf("a game in progress was dropped before IdleTTL")
+	}
+	now = now.Add(2 * time.Minute)
+	s.CreateGame("", "")
+	if exists(playing) {
+		t.Errorf("an idle game outlived IdleTTL")
+	}
+}
+
+func TestFullServer(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	s := NewServer()
+	s.now = func() time.Time { return now }
+	for i := range MaxGames {
+		id := fmt.Sprint(i)
+		s.games[id] = &liveGame{id: id, game: game.New(), spectators: map[*Conn]bool{}, lastUsed: now, now: s.now}
+	}
+
+	_, err := s.CreateGame("", "")
+	if code, status := errorCode(err); status != http.StatusServiceUnavailable || code != CodeTooManyGames {
+		t.Fatalf("CreateGame on a full server: %v, want %s", err, CodeTooManyGames)
+	}
+	if err := s.RemoveGame("0"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CreateGame("", ""); err != nil {
+		t.Fatalf("CreateGame after RemoveGame: %v", err)
+	}
+
+	// once the games go idle they make room for new ones
+	now = now.Add(IdleTTL + time.Minute)
+	id, err := s.CreateGame("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.games) != 1 || s.games[id] == nil {
+		t.Errorf("%d games kept, want only the one created after the others went idle", len(s.games))
+	}
+}
@@ -0,0 +1,217 @@
+// Package logging routes the log output of every subsystem through log/slog, and
+// records an opt-in trace of rule and search decisions as JSON lines.
+//
+// Each subsystem logs through the logger For returns, tagged with its name. Only
+// warnings and errors are written by default, as text on standard error. The
+// CHESSGO_LOG environment variable changes the levels, for all subsystems and for
+// single ones:
+//
+//	CHESSGO_LOG=debug
+//	CHESSGO_LOG=info,engine=debug,lan=debug
+//
+// CHESSGO_TRACE names a file to write the trace to: why each move was rejected and
+// what the engine searched, one JSON object per line. Configure and StartTrace do
+// the same from code.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// LevelTrace is the level of trace records, below debug.
+const LevelTrace = slog.LevelDebug - 4
+
+// Subsystem names used across the module.
+const (
+	Rules  = "rules"
+	Engine = "engine"
+	Player = "player"
+	GUI    = "gui"
+	UCI    = "uci"
+	LAN    = "lan"
+	Live   = "live"
+	Server = "server"
+)
+
+// config is what the root handler consults; it is replaced as a whole.
+type config struct {
+	handler slog.Handler
+	level   slog.Level
+	levels  map[string]slog.Level // per subsystem
+}
+
+var (
+	current atomic.Pointer[config]
+
+	traceMu     sync.Mutex
+	tracer      atomic.Pointer[slog.Logger]
+	traceCloser io.Closer
+)
+
+func init() {
+	current.Store(&config{handler: newTextHandler(os.Stderr), level: slog.LevelWarn})
+	if spec := os.Getenv("CHESSGO_LOG"); spec != "" {
+		if err := Configure(spec, os.Stderr); err != nil {
+			fmt.Fprintln(os.Stderr, "CHESSGO_LOG:", err)
+		}
+	}
+	if path := os.Getenv("CHESSGO_TRACE"); path != "" {
+		if err := StartTrace(path); err != nil {
+			fmt.Fprintln(os.Stderr, "CHESSGO_TRACE:", err)
+		}
+	}
+}
+
+func newTextHandler(w io.Writer) slog.Handler {
+	// the level is filtered per subsystem before records get here
+	return slog.NewTextHandler(w, &slog.HandlerOptions{Level: LevelTrace})
+}
+
+// Configure sets the levels from a spec such as "info,engine=debug" and writes the
+// log to w as text.
+func Configure(spec string, w io.Writer) error {
+	c := &config{handler: newTextHandler(w), level: slog.LevelWarn, levels: map[string]slog.Level{}}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		subsystem, name, found := strings.Cut(part, "=")
+		if !found {
+			subsystem, name = "", part
+		}
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(name)); err != nil {
+			return fmt.Errorf("invalid level %q", name)
+		}
+		if subsystem == "" {
+			c.level = level
+		} else {
+			c.levels[subsystem] = level
+		}
+	}
+	current.Store(c)
+	return nil
+}
+
+// For returns the logger of a subsystem. It follows later calls to Configure, so
+// it can be kept in a package variable.
+func For(subsystem string) *slog.Logger {
+	h := &handler{subsystem: subsystem}
+	return slog.New(h.WithAttrs([]slog.Attr{slog.String("subsystem", subsystem)}))
+}
+
+// handler hands records to the configured handler if the subsystem's level lets
+// them through.
+type handler struct {
+	subsystem string
+	scopes    []scope // in the order WithAttrs and WithGroup were called
+}
+
+// scope is one call of WithAttrs, or of WithGroup when group is set.
+type scope struct {
+	attrs []slog.Attr
+	group string
+}
+
+func (h *handler) Enabled(_ context.Context, level slog.Level) bool {
+	c := current.Load()
+	min, ok := c.levels[h.subsystem]
+	if !ok {
+		min = c.level
+	}
+	return level >= min
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	target := current.Load().handler
+	for _, s := range h.scopes {
+		if s.group != "" {
+			target = target.WithGroup(s.group)
+		} else {
+			target = target.WithAttrs(s.attrs)
+		}
+	}
+	return target.Handle(ctx, r)
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	return h.with(scope{attrs: attrs})
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return h.with(scope{group: name})
+}
+
+func (h *handler) with(s scope) *handler {
+	next := *h
+	next.scopes = append(append([]scope(nil), h.scopes...), s)
+	return &next
+}
+
+// StartTrace writes the trace to the file at path, replacing it.
+func StartTrace(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	TraceTo(f)
+	return nil
+}
+
+// TraceTo writes the trace to w, closing the previous trace file; nil stops tracing.
+func TraceTo(w io.Writer) {
+	traceMu.Lock()
+	defer traceMu.Unlock()
+	if traceCloser != nil {
+		traceCloser.Close()
+		traceCloser = nil
+	}
+	if w == nil {
+		tracer.Store(nil)
+		return
+	}
+	if closer, ok := w.(io.Closer); ok {
+		traceCloser = closer
+	}
+	tracer.Store(slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level: LevelTrace,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && a.Key == slog.LevelKey {
+				return slog.Attr{}
+			}
+			if len(groups) == 0 && a.Key == slog.MessageKey {
+				a.Key = "event"
+			}
+			return a
+		},
+	})))
+}
+
+// Tracing reports whether a trace is being written. Callers check it before
+// gathering what they would trace.
+func Tracing() bool {
+	return tracer.Load() != nil
+}
+
+// Trace records an event of a subsystem in the trace, if one is being written.
+func Trace(subsystem, event string, attrs ...slog.Attr) {
+	t := tracer.Load()
+	if t == nil {
+		return
+	}
+	t.LogAttrs(context.Background(), LevelTrace, event, append([]slog.Attr{slog.String("subsystem", subsystem)}, attrs...)...)
+}
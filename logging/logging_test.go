@@ -0,0 +1,123 @@
+package logging
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// restore puts the default configuration back after a test changes it.
+func restore(t *testing.T) {
+	saved := current.Load()
+	t.Cleanup(func() { current.Store(saved) })
+}
+
+func TestConfigure(t *testing.T) {
+	tests := []struct {
+		spec    string
+		level   slog.Level
+		levels  map[string]slog.Level
+		invalid bool
+	}{
+		{"", slog.LevelWarn, map[string]slog.Level{}, false},
+		{"debug", slog.LevelDebug, map[string]slog.Level{}, false},
+		{"info,engine=debug,lan=error", slog.LevelInfo, map[string]slog.Level{Engine: slog.LevelDebug, LAN: slog.LevelError}, false},
+		{" engine=DEBUG , ", slog.LevelWarn, map[string]slog.Level{Engine: slog.LevelDebug}, false},
+		{"warn+2", slog.LevelWarn + 2, map[string]slog.Level{}, false},
+		{"loud", 0, nil, true},
+		{"engine=", 0, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			restore(t)
+			before := current.Load()
+			err := Configure(tt.spec, os.Stderr)
+			if tt.invalid {
+				if err == nil || current.Load() != before {
+					t.Errorf("Configure(%q) = %v and changed the levels, want an error and no change", tt.spec, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			c := current.Load()
+			if c.level != tt.level || !reflect.DeepEqual(c.levels, tt.levels) {
+				t.Errorf("Configure(%q) sets %v and %v, want %v and %v", tt.spec, c.level, c.levels, tt.level, tt.levels)
+			}
+		})
+	}
+}
+
+func TestLevels(t *testing.T) {
+	restore(t)
+	var sb strings.Builder
+	if err := Configure("info,engine=debug,lan=error", &sb); err != nil {
+		t.Fatal(err)
+	}
+	For(Engine).Debug("engine debug")
+	For(Rules).Debug("rules debug")
+	For(Rules).Info("rules info")
+	For(LAN).Warn("lan warning")
+	For(LAN).Error("lan error")
+
+	out := sb.String()
+	for _, want := range []string{"engine debug", "rules info", "lan error"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("%q is missing from %q", want, out)
+		}
+	}
+	for _, hidden := range []string{"rules debug", "lan warning"} {
+		if strings.Contains(out, hidden) {
+			t.Errorf("%q is in %q", hidden, out)
+		}
+	}
+	if !strings.Contains(out, "subsystem=engine") {
+		t.Errorf("no subsystem in %q", out)
+	}
+}
+
+// TestGroups checks that attributes land in the groups open when they were added.
+func TestGroups(t *testing.T) {
+	restore(t)
+	var sb strings.Builder
+	if err := Configure("info", &sb); err != nil {
+		t.Fatal(err)
+	}
+	For(Engine).With("before", 1).WithGroup("search").With("depth", 3).Info("done", "nodes", 40)
+	want := "subsystem=engine before=1 search.depth=3 search.nodes=40"
+	if out := sb.String(); !strings.Contains(out, want) {
+		t.Errorf("output %q, want it to contain %q", out, want)
+	}
+}
+
+func TestTrace(t *testing.T) {
+	var sb strings.Builder
+	TraceTo(&sb)
+	defer TraceTo(nil)
+	if !Tracing() {
+		t.Fatal("not tracing after TraceTo")
+	}
+	Trace(Rules, "illegal move", slog.String("move", "e2e5"))
+
+	var record map[string]any
+	if err := json.Unmarshal([]byte(sb.String()), &record); err != nil {
+		t.Fatalf("trace %q: %v", sb.String(), err)
+	}
+	if record["event"] != "illegal move" || record["subsystem"] != Rules || record["move"] != "e2e5" {
+		t.Errorf("trace record %v", record)
+	}
+	for _, key := range []string{"level", "msg"} {
+		if _, ok := record[key]; ok {
+			t.Errorf("trace record %v has a %s key", record, key)
+		}
+	}
+
+	TraceTo(nil)
+	if Tracing() {
+		t.Error("still tracing after TraceTo(nil)")
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"math/rand"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// humanWhite and humanBlack say which sides are played from the board; the engine plays the others.
+var humanWhite, humanBlack = true, false
+
+// boardFlipped shows the board from Black's side, rank 1 at the top.
+var boardFlipped bool
+
+var whiteClockRow, blackClockRow fyne.CanvasObject
+
+// topClockSlot and bottomClockSlot hold the clock rows above and below the board.
+var topClockSlot, bottomClockSlot *fyne.Container
+
+const (
+	playAsWhite    = "Play as White"
+	playAsBlack    = "Play as Black"
+	playRandom     = "Random color"
+	humanVsHuman   = "Human vs human"
+	engineVsEngine = "Engine vs engine"
+)
+
+func isHumanSide(white bool) bool {
+	if white {
+		return humanWhite
+	}
+	return humanBlack
+}
+
+func playerName(white bool) string {
+	switch {
+	case isRemoteSide(white):
+		return lanSession.Opponent()
+	case lanSession != nil && isHumanSide(white):
+		return lanName()
+	case isHumanSide(white):
+		return "Player"
+	}
+	if opponentEngine.client != nil {
+		return opponentEngine.name()
+	}
+	return "Computer"
+}
+
+// resigningSide is the human's side, or the side to move when both or neither side is human.
+func resigningSide() bool {
+	if humanWhite != humanBlack {
+		return humanWhite
+	}
+	return currentGame.Position().WhiteTurn
+}
+
+// setPlayers applies a choice from the new game dialog and turns the board so a
+// single human player sits at the bottom.
+func setPlayers(choice string) {
+	if choice == playRandom {
+		choice = playAsWhite
+		if rand.Intn(2) == 1 {
+			choice = playAsBlack
+		}
+	}
+	switch choice {
+	case playAsWhite:
+		humanWhite, humanBlack = true, false
+	case playAsBlack:
+		humanWhite, humanBlack = false, true
+	case humanVsHuman:
+		humanWhite, humanBlack = true, true
+	case engineVsEngine:
+		humanWhite, humanBlack = false, false
+	}
+	boardFlipped = humanBlack && !humanWhite
+	applyOrientation()
+}
+
+// swapPlayers gives each player the other color, for a rematch.
+func swapPlayers() {
+	humanWhite, humanBlack = humanBlack, humanWhite
+	boardFlipped = humanBlack && !humanWhite
+	applyOrientation()
+}
+
+func showNewGameDialog() {
+	choices := widget.NewRadioGroup([]string{playAsWhite, playAsBlack, playRandom, humanVsHuman, engineVsEngine}, nil)
+	choices.Required = true
+	switch {
+	case humanWhite && humanBlack:
+		choices.SetSelected(humanVsHuman)
+	case humanWhite:
+		choices.SetSelected(playAsWhite)
+	case humanBlack:
+		choices.SetSelected(playAsBlack)
+	default:
+		choices.SetSelected(engineVsEngine)
+	}
+
+	dialog.ShowCustomConfirm("New Game", "Start", "Cancel", choices, func(confirmed bool) {
+		if !confirmed {
+			return
+		}
+		setPlayers(choices.Selected)
+		startNewGame()
+	}, chessWindow)
+}
+
+func flipBoard() {
+	boardFlipped = !boardFlipped
+	applyOrientation()
+}
+
+// applyOrientation draws the board with rank 8 at the top, or rank 1 when the board
+// is flipped, and puts each side's clock on its own edge of the board. The board widget
+// maps clicks and drops back to board coordinates, so they land on the right square either way.
+func applyOrientation() {
+	if chessBoard != nil {
+		chessBoard.Refresh()
+	}
+	if scoreBar != nil {
+		scoreBar.Refresh()
+	}
+
+	if topClockSlot != nil {
+		top, bottom := blackClockRow, whiteClockRow
+		if boardFlipped {
+			top, bottom = whiteClockRow, blackClockRow
+		}
+		topClockSlot.Objects = []fyne.CanvasObject{top}
+		bottomClockSlot.Objects = []fyne.CanvasObject{bottom}
+		topClockSlot.Refresh()
+		bottomClockSlot.Refresh()
+	}
+}
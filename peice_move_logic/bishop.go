@@ -1,9 +0,0 @@
-package peice_move_logic
-
-import(
-	"fmt"
-)
-
-func bishop(){
-	fmt.Println("Hi im bishop moving logic")
-}
\ No newline at end of file
@@ -1,9 +0,0 @@
-package peice_move_logic
-
-import(
-	"fmt"
-)
-
-func king(){
-	fmt.Println("Hi im king moving logic")
-}
\ No newline at end of file
@@ -1,9 +0,0 @@
-package peice_move_logic
-
-import(
-	"fmt"
-)
-
-func knight(){
-	fmt.Println("Hi im knight moving logic")
-}
\ No newline at end of file
@@ -1,9 +0,0 @@
-package peice_move_logic
-
-import(
-	"fmt"
-)
-
-func pawn(){
-	fmt.Println("Hi im pawn moving logic")
-}
\ No newline at end of file
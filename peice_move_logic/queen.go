@@ -1,9 +0,0 @@
-package peice_move_logic
-
-import(
-	"fmt"
-)
-
-func queen(){
-	fmt.Println("Hi im queen moving logic")
-}
\ No newline at end of file
@@ -0,0 +1,262 @@
+package player
+
+import (
+	"chess-engine/clock"
+	"chess-engine/engine"
+	"chess-engine/game"
+	"chess-engine/handlers"
+	"chess-engine/logging"
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+var logger = logging.For(logging.Player)
+
+// DefaultMoveTime is how long a searching player thinks per move when the game has
+// no clock.
+const DefaultMoveTime = time.Second
+
+// Controller plays a game between two players: it asks the side to move for a
+// move, plays it, presses the clock and asks the other side, until the game ends.
+// The players can be anything, a person against an engine, two engines while the
+// board looks on, or a person against an opponent over the network.
+type Controller struct {
+	// UntimedMoveTime is the thinking time per move without a clock; DefaultMoveTime if zero.
+	UntimedMoveTime time.Duration
+	// Do runs f on the goroutine that owns the game, such as a UI thread, now or
+	// later. The controller changes the game and calls OnMove, OnEnd and OnError
+	// only through it, so the owner can read the game without a lock. Without Do
+	// they run in the controller's goroutine, and nothing else may use the game
+	// while the controller runs.
+	Do func(f func())
+	// OnMove is called after each move is played. It must not call Stop or Start.
+	OnMove func(m handlers.Move)
+	// OnEnd is called when the game ends by a move or a flag.
+	OnEnd func()
+	// OnError is called when the player of the given side fails, which stops the
+	// controller. OnEnd and OnError run after the controller has stopped, so they
+	// may start it, or another one, again; they are not called once Stop has been.
+	OnError func(white bool, err error)
+
+	game    *game.Game
+	clock   *clock.Clock
+	players [2]Player // White, Black
+
+	cancel  context.CancelFunc
+	done    chan struct{}
+	stopped atomic.Bool
+}
+
+// NewController returns a controller playing g between white and black, charging
+// their time to c, which may be nil for an untimed game. The clock is pressed but
+// not started; it runs once the caller starts it.
+func NewController(g *game.Game, white, black Player, c *clock.Clock) *Controller {
+	return &Controller{game: g, clock: c, players: [2]Player{white, black}}
+}
+
+func index(white bool) int {
+	if white {
+		return 0
+	}
+	return 1
+}
+
+// Player returns the player of the given side.
+func (c *Controller) Player(white bool) Player {
+	return c.players[index(white)]
+}
+
+// SetPlayer hands the side to p. It takes effect from the side's next turn, so a
+// controller that is running should be stopped and started again around it.
+func (c *Controller) SetPlayer(white bool, p Player) {
+	c.players[index(white)] = p
+}
+
+// Start asks the side to move for its move and plays the game on in the
+// background. The request is made before Start returns, so a person at the board
+// can move at once. A game that is over is left alone.
+func (c *Controller) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel, c.done = cancel, make(chan struct{})
+	c.stopped.Store(false)
+	if c.game.Over() {
+		close(c.done)
+		return
+	}
+	results := c.request(ctx)
+	go c.run(ctx, results)
+}
+
+// Stop withdraws the current request and waits until the controller has stopped.
+// The game can then be changed, a move taken back or a result agreed, and the
+// controller started again.
+func (c *Controller) Stop() {
+	if c.cancel == nil {
+		return
+	}
+	c.stopped.Store(true)
+	c.cancel()
+	<-c.done
+}
+
+// do runs f where the game is owned.
+func (c *Controller) do(f func()) {
+	if c.Do == nil {
+		f()
+		return
+	}
+	c.Do(f)
+}
+
+// request asks the side to move for its move.
+func (c *Controller) request(ctx context.Context) <-chan Result {
+	pos := c.game.Position()
+	turn := Turn{
+		Start:    c.game.StartPosition(),
+		Moves:    c.game.Moves(),
+		Position: pos,
+		Limits:   c.limits(pos.WhiteTurn),
+	}
+	return c.Player(pos.WhiteTurn).Move(ctx, turn)
+}
+
+// limits feeds the clock state to players that manage their own thinking time.
+func (c *Controller) limits(whiteTurn bool) engine.Limits {
+	if c.clock == nil {
+		moveTime := c.UntimedMoveTime
+		if moveTime == 0 {
+			moveTime = DefaultMoveTime
+		}
+		return engine.Limits{MoveTime: moveTime}
+	}
+	return engine.Limits{
+		WhiteTime: c.clock.Remaining(true),
+		BlackTime: c.clock.Remaining(false),
+		WhiteInc:  c.clock.Bonus(true),
+		BlackInc:  c.clock.Bonus(false),
+		MovesToGo: c.clock.MovesToGo(whiteTurn),
+	}
+}
+
+// step is what the owner of the game reports after playing a move or checking the
+// flag: the request for the next move, or why the controller stops.
+type step struct {
+	next   <-chan Result
+	before handlers.Position // the position a move was played in, for the observers
+	move   *handlers.Move
+	finish func()
+}
+
+func (c *Controller) run(ctx context.Context, results <-chan Result) {
+	var finish func()
+	defer func() {
+		// withdraw the request of a game that ended
+		c.cancel()
+		close(c.done)
+		if finish != nil {
+			c.do(func() {
+				if !c.stopped.Load() {
+					finish()
+				}
+			})
+		}
+	}()
+
+	for {
+		var carry func() step
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.flagTimer():
+			carry = func() step { return c.flag(results) }
+		case r := <-results:
+			carry = func() step { return c.play(ctx, r) }
+		}
+		done := make(chan step, 1)
+		c.do(func() {
+			// a controller stopped meanwhile leaves the game alone
+			if ctx.Err() == nil {
+				done <- carry()
+			}
+		})
+		var st step
+		select {
+		case st = <-done:
+		case <-ctx.Done():
+			// the owner may have played the move just before stopping the controller
+			select {
+			case st = <-done:
+			default:
+				return
+			}
+		}
+		if st.move != nil {
+			for _, p := range c.players {
+				if observer, ok := p.(Observer); ok {
+					observer.Moved(st.before, *st.move)
+				}
+			}
+		}
+		if st.next == nil {
+			finish = st.finish
+			return
+		}
+		results = st.next
+	}
+}
+
+// flag ends the game if the side to move ran out of time; the owner of the game
+// runs it.
+func (c *Controller) flag(results <-chan Result) step {
+	white, flagged := c.clock.Flagged()
+	if !flagged || !c.clock.Running() {
+		return step{next: results}
+	}
+	c.clock.Stop()
+	c.game.Flag(white)
+	return step{finish: c.OnEnd}
+}
+
+// play plays the move of a player's result and asks for the next one; the owner of
+// the game runs it.
+func (c *Controller) play(ctx context.Context, r Result) step {
+	pos := c.game.Position()
+	err := r.Err
+	if err == nil {
+		err = c.game.Play(r.Move)
+	}
+	if err != nil {
+		logger.Warn("player failed", "player", c.players[index(pos.WhiteTurn)].Name(), "err", err)
+		if c.OnError == nil {
+			return step{}
+		}
+		return step{finish: func() { c.OnError(pos.WhiteTurn, err) }}
+	}
+	logger.Debug("move played", "player", c.players[index(pos.WhiteTurn)].Name(), "move", pos.SAN(r.Move))
+	if c.clock != nil {
+		c.clock.Press()
+	}
+	if c.OnMove != nil {
+		c.OnMove(r.Move)
+	}
+	st := step{before: pos, move: &r.Move}
+	if c.game.Over() {
+		if c.clock != nil {
+			c.clock.Stop()
+		}
+		st.finish = c.OnEnd
+		return st
+	}
+	st.next = c.request(ctx)
+	return st
+}
+
+// flagTimer fires when the running clock would run out, nil without a running clock.
+func (c *Controller) flagTimer() <-chan time.Time {
+	if c.clock == nil || !c.clock.Running() {
+		return nil
+	}
+	white, _ := c.clock.Flagged()
+	return time.After(c.clock.Remaining(white))
+}
@@ -0,0 +1,129 @@
+package player
+
+import (
+	"chess-engine/clock"
+	"chess-engine/game"
+	"chess-engine/handlers"
+	"errors"
+	"testing"
+	"time"
+)
+
+// owner is the goroutine that owns a game, as the UI thread does: the controller
+// hands it work through do, and the test reads the game between the jobs.
+type owner struct {
+	jobs chan func()
+}
+
+func newOwner() *owner {
+	return &owner{jobs: make(chan func())}
+}
+
+// do queues f without waiting, as fyne.Do does.
+func (o *owner) do(f func()) {
+	go func() { o.jobs <- f }()
+}
+
+// until runs jobs until done is closed, failing the test if it takes too long.
+func (o *owner) until(t *testing.T, done <-chan struct{}) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case f := <-o.jobs:
+			f()
+		case <-done:
+			return
+		case <-timeout:
+			t.Fatal("the controller did not finish")
+		}
+	}
+}
+
+func TestController(t *testing.T) {
+	tests := []struct {
+		name         string
+		white, black []string
+		delay        time.Duration // how long White thinks
+		control      string        // the time control, "" for none
+		moves        int           // the moves played
+		result       game.Result
+		failed       string // the side that failed, "" if none
+	}{
+		{"mate", []string{"f3", "g4"}, []string{"e5", "Qh4#"}, 0, "", 4, game.BlackWins, ""},
+		{"player failure", []string{"e4"}, []string{"e5"}, 0, "", 2, game.Ongoing, "white"},
+		{"flag", []string{"e4"}, nil, time.Second, "0.001", 0, game.BlackWins, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := game.New()
+			var c *clock.Clock
+			white, black := NewScripted("White", tt.white...), NewScripted("Black", tt.black...)
+			white.Delay = tt.delay
+			if tt.control != "" {
+				tc, _ := clock.Parse(tt.control, clock.Increment)
+				c = clock.New(tc)
+				c.Start(true)
+			}
+			o := newOwner()
+			ctl := NewController(g, white, black, c)
+			ctl.Do = o.do
+			moves, failed := 0, ""
+			finished := make(chan struct{})
+			ctl.OnMove = func(m handlers.Move) {
+				moves++
+				// the owner reads the game while the controller runs
+				if last := g.Moves()[g.Ply()-1]; last != m {
+					t.Errorf("OnMove(%s) after %s", m, last)
+				}
+			}
+			ctl.OnEnd = func() { close(finished) }
+			ctl.OnError = func(white bool, err error) {
+				failed = "black"
+				if white {
+					failed = "white"
+				}
+				if !errors.Is(err, ErrScriptEnded) {
+					t.Errorf("OnError(%v), want %v", err, ErrScriptEnded)
+				}
+				close(finished)
+			}
+			ctl.Start()
+			o.until(t, finished)
+			ctl.Stop()
+
+			if moves != tt.moves || failed != tt.failed || g.Result() != tt.result {
+				t.Errorf("%d moves, failed %q, result %s, want %d, %q, %s", moves, failed, g.Result(), tt.moves, tt.failed, tt.result)
+			}
+		})
+	}
+}
+
+// TestControllerStopped stops a controller whose move is waiting for the owner: the
+// move is dropped and nothing is called.
+func TestControllerStopped(t *testing.T) {
+	g := game.New()
+	o := newOwner()
+	ctl := NewController(g, NewScripted("White", "f3"), NewScripted("Black"), nil)
+	ctl.Do = o.do
+	ctl.OnMove = func(handlers.Move) { t.Errorf("OnMove called after Stop") }
+	ctl.OnError = func(bool, error) { t.Errorf("OnError called after Stop") }
+	ctl.Start()
+	// the scripted move is answered at once; the job to play it waits for the owner
+	job := <-o.jobs
+	ctl.Stop()
+	job()
+
+	timeout := time.After(100 * time.Millisecond)
+	for {
+		select {
+		case f := <-o.jobs:
+			f()
+		case <-timeout:
+			if g.Ply() != 0 {
+				t.Errorf("a stopped controller played %v", g.Moves())
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package player
+
+import (
+	"chess-engine/engine"
+	"chess-engine/uci"
+	"context"
+)
+
+// Engine is a player that searches for its moves: the built-in engine, or a UCI
+// engine running as a subprocess.
+type Engine struct {
+	name     string
+	searcher engine.Searcher
+	client   *uci.Client // nil for the built-in engine
+}
+
+// NewEngine returns the built-in engine e as a player called name.
+func NewEngine(name string, e *engine.Engine) *Engine {
+	return &Engine{name: name, searcher: e}
+}
+
+// NewUCI returns the UCI engine behind c as a player, named as the engine names itself.
+func NewUCI(c *uci.Client) *Engine {
+	return &Engine{name: c.Name, searcher: c, client: c}
+}
+
+func (e *Engine) Name() string {
+	return e.name
+}
+
+// Move searches within turn.Limits. A UCI engine that crashed or hung answers with
+// the error it failed with.
+func (e *Engine) Move(ctx context.Context, turn Turn) <-chan Result {
+	results := make(chan Result, 1)
+	go func() {
+		m, ok := e.searcher.Search(ctx, turn.Position, turn.Limits, nil)
+		switch {
+		case ctx.Err() != nil:
+		case ok:
+			results <- Result{Move: m}
+		case e.client != nil && e.client.Err() != nil:
+			results <- Result{Err: e.client.Err()}
+		default:
+			results <- Result{Err: ErrNoMove}
+		}
+	}()
+	return results
+}
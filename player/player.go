@@ -0,0 +1,158 @@
+// Package player lets anything choose the moves of a side: a person at the board,
+// the built-in engine, a UCI engine, an opponent over the network or a script. A
+// Controller plays a game between any two of them. The package has no GUI
+// dependencies.
+package player
+
+import (
+	"chess-engine/engine"
+	"chess-engine/game"
+	"chess-engine/handlers"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNotYourTurn = errors.New("it is not your turn")
+	ErrScriptEnded = errors.New("the script has no more moves")
+	ErrNoMove      = errors.New("the engine found no move")
+)
+
+// Turn is what a player is given when it is to move.
+type Turn struct {
+	Start    handlers.Position
+	Moves    []handlers.Move // played since Start
+	Position handlers.Position
+	// Limits is the thinking time the clock allows, for players that search
+	Limits engine.Limits
+}
+
+// Result is a player's answer to a Turn.
+type Result struct {
+	Move handlers.Move
+	Err  error
+}
+
+// Player chooses the moves of one side.
+type Player interface {
+	Name() string
+	// Move asks for a move in turn.Position and returns at once. The answer is sent
+	// on the channel when the player has it. Cancelling ctx withdraws the request;
+	// nothing need be sent then.
+	Move(ctx context.Context, turn Turn) <-chan Result
+}
+
+// Observer is a player that is told of every move played, by either side, such as
+// a network opponent that passes the other side's moves on.
+type Observer interface {
+	Moved(before handlers.Position, m handlers.Move)
+}
+
+// answer returns a channel holding r.
+func answer(r Result) <-chan Result {
+	results := make(chan Result, 1)
+	results <- r
+	return results
+}
+
+// Human is a person at the board. The board hands in the moves with Submit while
+// the player is asked for one.
+type Human struct {
+	name string
+
+	mu      sync.Mutex
+	pending chan Result
+	request context.Context // of the pending answer
+	pos     handlers.Position
+}
+
+// NewHuman returns a person called name.
+func NewHuman(name string) *Human {
+	return &Human{name: name}
+}
+
+func (h *Human) Name() string {
+	return h.name
+}
+
+func (h *Human) Move(ctx context.Context, turn Turn) <-chan Result {
+	results := make(chan Result, 1)
+	h.mu.Lock()
+	h.pending, h.request, h.pos = results, ctx, turn.Position
+	h.mu.Unlock()
+	return results
+}
+
+// Waiting reports whether the player is asked for a move.
+func (h *Human) Waiting() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.waiting()
+}
+
+func (h *Human) waiting() bool {
+	return h.pending != nil && h.request.Err() == nil
+}
+
+// Submit plays m as the player's answer. It fails when the player is not asked for
+// a move or m is not legal.
+func (h *Human) Submit(m handlers.Move) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.waiting() {
+		return ErrNotYourTurn
+	}
+	if err := h.pos.CheckMove(m); err != nil {
+		return fmt.Errorf("%w: %w", game.ErrIllegalMove, err)
+	}
+	h.pending <- Result{Move: m}
+	h.pending = nil
+	return nil
+}
+
+// Scripted plays a fixed list of moves, in UCI or SAN, waiting Delay before each,
+// and fails once the list runs out.
+type Scripted struct {
+	name  string
+	Delay time.Duration
+
+	mu    sync.Mutex
+	moves []string
+	next  int
+}
+
+// NewScripted returns a player called name that plays moves in order.
+func NewScripted(name string, moves ...string) *Scripted {
+	return &Scripted{name: name, moves: moves}
+}
+
+func (s *Scripted) Name() string {
+	return s.name
+}
+
+func (s *Scripted) Move(ctx context.Context, turn Turn) <-chan Result {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.next >= len(s.moves) {
+		return answer(Result{Err: ErrScriptEnded})
+	}
+	text := s.moves[s.next]
+	s.next++
+	m, err := turn.Position.ParseMove(text)
+	if s.Delay == 0 || err != nil {
+		return answer(Result{Move: m, Err: err})
+	}
+
+	results := make(chan Result, 1)
+	go func() {
+		select {
+		case <-time.After(s.Delay):
+			results <- Result{Move: m}
+		case <-ctx.Done():
+		}
+	}()
+	return results
+}
@@ -0,0 +1,84 @@
+package player
+
+import (
+	"chess-engine/handlers"
+	"chess-engine/lan"
+	"context"
+	"errors"
+	"sync"
+)
+
+// Remote is the opponent at the other end of a network game. It sends the moves
+// of the side played here as they are made, and answers with the moves that
+// arrive. Everything else the opponent does, offers, chat and the end of the
+// connection, is passed to the onEvent function given to NewRemote.
+type Remote struct {
+	session *lan.Session
+	onEvent func(lan.Event)
+
+	mu      sync.Mutex
+	arrived []handlers.Move // moves received before they were asked for
+	pending chan Result
+	request context.Context // of the pending answer
+}
+
+// NewRemote returns the opponent of session. It reads the session's events from
+// now on, so nothing else may.
+func NewRemote(session *lan.Session, onEvent func(lan.Event)) *Remote {
+	r := &Remote{session: session, onEvent: onEvent}
+	go r.receive()
+	return r
+}
+
+func (r *Remote) Name() string {
+	return r.session.Opponent()
+}
+
+func (r *Remote) receive() {
+	for event := range r.session.Events() {
+		switch event.Type {
+		case lan.TypeMove:
+			r.mu.Lock()
+			if r.pending != nil && r.request.Err() == nil {
+				r.pending <- Result{Move: event.Move}
+				r.pending = nil
+			} else {
+				r.arrived = append(r.arrived, event.Move)
+			}
+			r.mu.Unlock()
+			continue
+		case lan.TypeTakebackAccept:
+			// the game goes back, so a move sent before is void
+			r.mu.Lock()
+			r.arrived = nil
+			r.mu.Unlock()
+		}
+		r.onEvent(event)
+	}
+}
+
+// Move waits for the opponent's move. If the connection ends meanwhile the request
+// stays open; the TypeClosed event tells the caller to hand the side to someone else.
+func (r *Remote) Move(ctx context.Context, turn Turn) <-chan Result {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.arrived) > 0 {
+		m := r.arrived[0]
+		r.arrived = r.arrived[1:]
+		return answer(Result{Move: m})
+	}
+	results := make(chan Result, 1)
+	r.pending, r.request = results, ctx
+	return results
+}
+
+// Moved sends the moves of the side played here to the opponent. A move that could
+// not be sent is reported as a TypeError event.
+func (r *Remote) Moved(before handlers.Position, m handlers.Move) {
+	if before.WhiteTurn != r.session.White() {
+		return
+	}
+	if err := r.session.Move(m); err != nil && !errors.Is(err, lan.ErrClosed) {
+		r.onEvent(lan.Event{Type: lan.TypeError, Err: err})
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"chess-engine/handlers"
+	"chess-engine/player"
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+)
+
+var (
+	// controller plays the current game between its two players; nil while the game
+	// is over or paused in the editor.
+	controller *player.Controller
+
+	// humanPlayers are the people at the board, White's and Black's; the board hands
+	// their moves in.
+	humanPlayers = [2]*player.Human{player.NewHuman("White"), player.NewHuman("Black")}
+
+	// lanOpponent is the player of the opponent's side in a game over the network.
+	lanOpponent *player.Remote
+)
+
+func humanPlayer(white bool) *player.Human {
+	if white {
+		return humanPlayers[0]
+	}
+	return humanPlayers[1]
+}
+
+// newPlayer returns who plays the side: the opponent over the network, the person
+// at the board, or the opponent engine.
+func newPlayer(white bool) player.Player {
+	switch {
+	case isRemoteSide(white):
+		return lanOpponent
+	case isHumanSide(white):
+		return humanPlayer(white)
+	}
+	return opponentEngine.player()
+}
+
+// resumePlay (re)starts the controller on the current game with the players as they
+// are now chosen, so whoever is to move is asked for a move.
+func resumePlay() {
+	stopPlay()
+	if editing || currentGame.Over() {
+		return
+	}
+	c := player.NewController(currentGame, newPlayer(true), newPlayer(false), gameClock)
+	c.UntimedMoveTime = untimedMoveTime
+	// the controller plays its moves on the UI thread, which owns currentGame
+	c.Do = fyne.Do
+	c.OnMove = moveMade
+	c.OnEnd = showGameOverDialog
+	c.OnError = playerFailed
+	controller = c
+	c.Start()
+}
+
+// stopPlay stops the controller, so the game can be changed without a move arriving.
+func stopPlay() {
+	if c := controller; c != nil {
+		controller = nil
+		c.Stop()
+	}
+}
+
+// submitMove plays a move made on the board for the side to move.
+func submitMove(move handlers.Move) {
+	if err := humanPlayer(currentGame.Position().WhiteTurn).Submit(move); err != nil {
+		logger.Debug("move rejected", "move", move, "reason", err)
+	}
+}
+
+// playerFailed hands the side of an external engine that stopped working to the
+// built-in engine, and reports any other failure.
+func playerFailed(white bool, err error) {
+	if isEngineSide(white) && opponentEngine.checkFailed() {
+		resumePlay()
+		return
+	}
+	dialog.ShowError(fmt.Errorf("%s cannot move: %w", playerName(white), err), chessWindow)
+}
@@ -0,0 +1,160 @@
+package tui
+
+import (
+	"chess-engine/engine"
+	"chess-engine/handlers"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// glyphs are the Unicode chess pieces. On colored squares both sides use the solid
+// ones, told apart by their color; without colors White's are the outlined ones.
+var glyphs = map[rune]rune{
+	'K': '♔', 'Q': '♕', 'R': '♖', 'B': '♗', 'N': '♘', 'P': '♙',
+	'k': '♚', 'q': '♛', 'r': '♜', 'b': '♝', 'n': '♞', 'p': '♟',
+}
+
+// ANSI 256-color backgrounds of the squares, and the foregrounds of the pieces.
+const (
+	lightSquare     = "\x1b[48;5;187m"
+	darkSquare      = "\x1b[48;5;137m"
+	lightLastMove   = "\x1b[48;5;186m"
+	darkLastMove    = "\x1b[48;5;143m"
+	checkSquare     = "\x1b[48;5;167m"
+	whitePiece      = "\x1b[1;97m"
+	blackPiece      = "\x1b[1;30m"
+	resetAttributes = "\x1b[0m"
+	bold            = "\x1b[1m"
+)
+
+// boardWidth is how many columns the board takes: the rank numbers on both sides
+// and eight squares three columns wide.
+const boardWidth = 3 + 8*3 + 2
+
+// renderBoard draws pos as ten lines, the files above and below the ranks, with the
+// squares of the last move and a king in check highlighted.
+func renderBoard(pos handlers.Position, last *handlers.Move, flipped, colored bool) []string {
+	files := "   "
+	for j := 0; j < 8; j++ {
+		col := j
+		if flipped {
+			col = 7 - j
+		}
+		files += " " + string(rune('a'+col)) + " "
+	}
+	lines := []string{files}
+
+	check := handlers.NoSquare
+	if pos.InCheck() {
+		check = pos.KingSquare(pos.WhiteTurn)
+	}
+	for i := 0; i < 8; i++ {
+		row := i
+		if flipped {
+			row = 7 - i
+		}
+		var b strings.Builder
+		fmt.Fprintf(&b, " %d ", 8-row)
+		for j := 0; j < 8; j++ {
+			col := j
+			if flipped {
+				col = 7 - j
+			}
+			square := handlers.Square{Row: row, Col: col}
+			piece := pos.Board[row][col]
+			if !colored {
+				b.WriteString(" " + plainGlyph(piece, (row+col)%2 == 1) + " ")
+				continue
+			}
+
+			light := (row+col)%2 == 0
+			background := darkSquare
+			switch {
+			case square == check:
+				background = checkSquare
+			case last != nil && (square == last.From || square == last.To):
+				background = darkLastMove
+				if light {
+					background = lightLastMove
+				}
+			case light:
+				background = lightSquare
+			}
+			b.WriteString(background)
+			if piece == 0 {
+				b.WriteString("   ")
+			} else {
+				foreground := blackPiece
+				if unicode.IsUpper(piece) {
+					foreground = whitePiece
+				}
+				b.WriteString(foreground + " " + string(glyphs[unicode.ToLower(piece)]) + " ")
+			}
+			b.WriteString(resetAttributes)
+		}
+		fmt.Fprintf(&b, " %d", 8-row)
+		lines = append(lines, b.String())
+	}
+	return append(lines, files)
+}
+
+func plainGlyph(piece rune, dark bool) string {
+	switch {
+	case piece != 0:
+		return string(glyphs[piece])
+	case dark:
+		return "·"
+	}
+	return " "
+}
+
+// moveList numbers the moves in pairs, "12. Nf3 Nc6", starting from the move
+// number of the start position.
+func moveList(start handlers.Position, san []string) []string {
+	var lines []string
+	number := start.FullMoveNumber
+	i := 0
+	if !start.WhiteTurn && len(san) > 0 {
+		lines = append(lines, fmt.Sprintf("%3d. %-8s%s", number, "...", san[0]))
+		number++
+		i = 1
+	}
+	for ; i < len(san); i += 2 {
+		line := fmt.Sprintf("%3d. %-8s", number, san[i])
+		if i+1 < len(san) {
+			line += san[i+1]
+		}
+		lines = append(lines, strings.TrimRight(line, " "))
+		number++
+	}
+	return lines
+}
+
+// formatClock shows minutes and seconds, and tenths once less than ten seconds are left.
+func formatClock(d time.Duration) string {
+	if d < 10*time.Second {
+		return fmt.Sprintf("0:%04.1f", d.Seconds())
+	}
+	d = d.Round(time.Second)
+	if d >= time.Hour {
+		return fmt.Sprintf("%d:%02d:%02d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
+	}
+	return fmt.Sprintf("%d:%02d", int(d.Minutes()), int(d.Seconds())%60)
+}
+
+// formatEval shows an engine line with its score from White's point of view.
+func formatEval(pos handlers.Position, info engine.Info) string {
+	score, mate := info.Score, info.Mate
+	if !pos.WhiteTurn {
+		score, mate = -score, -mate
+	}
+	text := fmt.Sprintf("%+.2f", float64(score)/100)
+	if mate != 0 {
+		text = "#" + strconv.Itoa(mate)
+	}
+	pv := info.PV[:min(len(info.PV), evalPVMoves)]
+	return fmt.Sprintf("%s  depth %d  %s", text, info.Depth, pos.SANLine(pv))
+}
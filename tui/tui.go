@@ -0,0 +1,485 @@
+// Package tui plays chess in a terminal: a Unicode board with colored squares, moves
+// typed in SAN or UCI, the move list, the clocks and the engine's evaluation. It
+// needs nothing but a terminal, so it works over SSH on a machine without a display.
+package tui
+
+import (
+	"bufio"
+	"chess-engine/clock"
+	"chess-engine/engine"
+	"chess-engine/game"
+	"chess-engine/handlers"
+	"chess-engine/player"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// evalPVMoves is how many moves of the engine's line the evaluation shows.
+const evalPVMoves = 6
+
+// tick is how often the clocks are redrawn.
+const tick = 100 * time.Millisecond
+
+const help = "Type a move (e4, Nf3, e2e4) or: flip, eval, undo, resign, new, quit"
+
+// Options configure a game in the terminal.
+type Options struct {
+	Start handlers.Position
+	// WhiteEngine and BlackEngine hand the side to the built-in engine; the person at
+	// the keyboard plays the others. With neither, both sides are entered by hand, to
+	// analyse with the evaluation switched on.
+	WhiteEngine, BlackEngine bool
+	Control                  *clock.TimeControl // nil for an untimed game
+	MoveTime                 time.Duration      // the engine's thinking time per move without a clock
+	Eval                     bool               // show the evaluation from the start
+	Plain                    bool               // no colors
+}
+
+type app struct {
+	opts    Options
+	out     io.Writer
+	tty     bool
+	flipped bool
+	players [2]player.Player // White, Black
+	humans  [2]*player.Human
+
+	game       *game.Game
+	clock      *clock.Clock
+	controller *player.Controller
+
+	eval       bool
+	evalEngine *engine.Engine
+	cancelEval context.CancelFunc
+	evalFEN    string
+
+	input   []rune
+	changed chan struct{} // work was posted, the game changed or the evaluation deepened
+
+	mu       sync.Mutex // guards the fields below, set from other goroutines
+	status   string
+	evalText string
+	jobs     []func() // posted for the main loop, which owns the game
+}
+
+// Run plays games in the terminal on in and out until the player quits. When in is
+// a terminal it is switched to raw mode, and restored before Run returns; otherwise
+// the moves and commands are read line by line.
+func Run(in io.Reader, out io.Writer, opts Options) error {
+	a := &app{
+		opts:       opts,
+		out:        out,
+		flipped:    opts.WhiteEngine && !opts.BlackEngine,
+		eval:       opts.Eval,
+		evalEngine: engine.New(),
+		changed:    make(chan struct{}, 1),
+	}
+	computer := player.NewEngine("chessgo", engine.New())
+	for i, isEngine := range []bool{opts.WhiteEngine, opts.BlackEngine} {
+		a.humans[i] = player.NewHuman("Player")
+		a.players[i] = a.humans[i]
+		if isEngine {
+			a.players[i] = computer
+		}
+	}
+
+	keys := make(chan []byte)
+	if f, ok := in.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		state, err := term.MakeRaw(int(f.Fd()))
+		if err != nil {
+			return err
+		}
+		defer term.Restore(int(f.Fd()), state)
+		a.tty = true
+		// the alternate screen keeps the shell's scrollback as it was
+		fmt.Fprint(out, "\x1b[?1049h")
+		defer fmt.Fprint(out, "\x1b[?1049l")
+		go readKeys(f, keys)
+	} else {
+		go readLines(in, keys)
+	}
+
+	a.newGame()
+	defer a.stop()
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+	a.draw()
+	for {
+		select {
+		case <-a.changed:
+			a.runJobs()
+			a.updateEval()
+		case <-ticker.C:
+			if !a.tty || a.clock == nil || !a.clock.Running() {
+				// a redraw that changes nothing would only repeat the screen in a pipe
+				continue
+			}
+		case data, ok := <-keys:
+			if !ok || a.typed(data) {
+				return nil
+			}
+		}
+		a.draw()
+	}
+}
+
+func readKeys(f *os.File, keys chan<- []byte) {
+	buf := make([]byte, 64)
+	for {
+		n, err := f.Read(buf)
+		if n > 0 {
+			keys <- append([]byte(nil), buf[:n]...)
+		}
+		if err != nil {
+			close(keys)
+			return
+		}
+	}
+}
+
+// readLines passes each line of input on as if it was typed and Enter pressed.
+func readLines(in io.Reader, keys chan<- []byte) {
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		keys <- []byte(scanner.Text() + "\r")
+	}
+	close(keys)
+}
+
+func index(white bool) int {
+	if white {
+		return 0
+	}
+	return 1
+}
+
+func (a *app) isEngine(white bool) bool {
+	return a.players[index(white)] != player.Player(a.humans[index(white)])
+}
+
+func (a *app) notify() {
+	select {
+	case a.changed <- struct{}{}:
+	default:
+	}
+}
+
+// post queues f for the main loop, which alone reads and changes the game; the
+// controller plays its moves through it.
+func (a *app) post(f func()) {
+	a.mu.Lock()
+	a.jobs = append(a.jobs, f)
+	a.mu.Unlock()
+	a.notify()
+}
+
+func (a *app) runJobs() {
+	a.mu.Lock()
+	jobs := a.jobs
+	a.jobs = nil
+	a.mu.Unlock()
+	for _, f := range jobs {
+		f()
+	}
+}
+
+func (a *app) setStatus(text string) {
+	a.mu.Lock()
+	a.status = text
+	a.mu.Unlock()
+}
+
+// newGame starts a game from the start position, with a fresh clock.
+func (a *app) newGame() {
+	a.stop()
+	a.game = game.NewFromPosition(a.opts.Start)
+	a.game.Tags["White"], a.game.Tags["Black"] = a.players[0].Name(), a.players[1].Name()
+	a.clock = nil
+	if tc := a.opts.Control; tc != nil {
+		a.clock = clock.New(*tc)
+		a.clock.Start(a.opts.Start.WhiteTurn)
+		a.game.Tags["TimeControl"] = tc.PGN()
+	}
+	a.setStatus(help)
+	a.resume()
+}
+
+// resume asks the side to move for its move.
+func (a *app) resume() {
+	a.stop()
+	a.notify()
+	if a.game.Over() {
+		return
+	}
+	c := player.NewController(a.game, a.players[0], a.players[1], a.clock)
+	c.UntimedMoveTime = a.opts.MoveTime
+	// moves are played in the main loop, which redraws after every job
+	c.Do = a.post
+	c.OnError = func(white bool, err error) {
+		a.setStatus(fmt.Sprintf("%s cannot move: %v", a.players[index(white)].Name(), err))
+	}
+	a.controller = c
+	c.Start()
+}
+
+func (a *app) stop() {
+	if a.controller != nil {
+		a.controller.Stop()
+		a.controller = nil
+	}
+}
+
+// typed handles keys and reports whether the player quit.
+func (a *app) typed(data []byte) bool {
+	for len(data) > 0 {
+		r, size := utf8.DecodeRune(data)
+		data = data[size:]
+		switch {
+		case r == 3 || r == 4: // Ctrl-C, Ctrl-D
+			return true
+		case r == '\r' || r == '\n':
+			line := strings.TrimSpace(string(a.input))
+			a.input = nil
+			if a.command(line) {
+				return true
+			}
+		case r == 127 || r == 8: // Backspace
+			if len(a.input) > 0 {
+				a.input = a.input[:len(a.input)-1]
+			}
+		case r == 21: // Ctrl-U
+			a.input = nil
+		case r == 27: // an escape sequence, such as an arrow key, is skipped
+			if len(data) > 0 && data[0] == '[' {
+				end := 1
+				for end < len(data) && (data[end] < 0x40 || data[end] > 0x7e) {
+					end++
+				}
+				data = data[min(end+1, len(data)):]
+			}
+		case unicode.IsPrint(r):
+			a.input = append(a.input, r)
+		}
+	}
+	return false
+}
+
+// command carries out a line the player entered and reports whether they quit.
+func (a *app) command(line string) bool {
+	switch strings.ToLower(line) {
+	case "":
+		return false
+	case "quit", "exit", "q":
+		return true
+	case "help", "?":
+		a.setStatus(help)
+	case "flip":
+		a.flipped = !a.flipped
+	case "eval":
+		a.eval = !a.eval
+		a.updateEval()
+	case "new":
+		a.newGame()
+	case "undo":
+		a.undo()
+	case "resign":
+		a.resign()
+	default:
+		a.move(line)
+	}
+	return false
+}
+
+// move plays a move typed in SAN or UCI for the side to move.
+func (a *app) move(text string) {
+	pos := a.game.Position()
+	switch {
+	case a.game.Over():
+		a.setStatus("The game is over. Type new for another one.")
+		return
+	case a.isEngine(pos.WhiteTurn):
+		a.setStatus("It is the engine's turn.")
+		return
+	}
+	m, err := pos.ParseMove(text)
+	if err == nil {
+		err = a.humans[index(pos.WhiteTurn)].Submit(m)
+	}
+	if err != nil {
+		a.setStatus(fmt.Sprintf("Illegal move %s: %v", text, err))
+		return
+	}
+	a.setStatus("")
+}
+
+// undo takes back moves until the player is to move again: one half-move when
+// people play both sides, two against the engine.
+func (a *app) undo() {
+	a.stop()
+	ply := a.game.Ply() - 1
+	if !a.opts.WhiteEngine || !a.opts.BlackEngine {
+		for ply > 0 && a.isEngine(a.game.PositionAt(ply).WhiteTurn) {
+			ply--
+		}
+	}
+	if ply < 0 {
+		a.setStatus("There is no move to take back.")
+		a.resume()
+		return
+	}
+	a.game.Truncate(ply)
+	if a.clock != nil {
+		a.clock.Stop()
+		a.clock.Start(a.game.Position().WhiteTurn)
+	}
+	a.setStatus("")
+	a.resume()
+}
+
+// resign gives up the game for the player, or for the side to move when people
+// play both sides or neither.
+func (a *app) resign() {
+	if a.game.Over() {
+		return
+	}
+	a.stop()
+	white := a.game.Position().WhiteTurn
+	if a.opts.WhiteEngine != a.opts.BlackEngine {
+		white = !a.opts.WhiteEngine
+	}
+	a.game.Resign(white)
+	if a.clock != nil {
+		a.clock.Stop()
+	}
+	a.notify()
+}
+
+// updateEval starts the evaluation of a new position, or stops it when it is
+// switched off or the game is over.
+func (a *app) updateEval() {
+	pos := a.game.Position()
+	if a.eval && !a.game.Over() && pos.FEN() == a.evalFEN {
+		return
+	}
+	if a.cancelEval != nil {
+		a.cancelEval()
+		a.cancelEval = nil
+	}
+	a.evalFEN = ""
+	a.mu.Lock()
+	a.evalText = ""
+	a.mu.Unlock()
+	if !a.eval || a.game.Over() {
+		return
+	}
+
+	a.evalFEN = pos.FEN()
+	ctx, cancel := context.WithCancel(context.Background())
+	a.cancelEval = cancel
+	go a.evalEngine.Search(ctx, pos, engine.Limits{}, func(info engine.Info) {
+		if ctx.Err() != nil || len(info.PV) == 0 || info.MultiPV > 1 {
+			return
+		}
+		a.mu.Lock()
+		a.evalText = formatEval(pos, info)
+		a.mu.Unlock()
+		a.notify()
+	})
+}
+
+// draw shows the screen: the board with the clocks and moves beside it, the
+// evaluation, the status and the line being typed.
+func (a *app) draw() {
+	pos := a.game.Position()
+	var last *handlers.Move
+	if moves := a.game.Moves(); len(moves) > 0 {
+		last = &moves[len(moves)-1]
+	}
+	board := renderBoard(pos, last, a.flipped, !a.opts.Plain)
+
+	// the side at the top of the board has its clock above the moves
+	top, bottom := false, true
+	if a.flipped {
+		top, bottom = true, false
+	}
+	panel := make([]string, len(board))
+	panel[0] = a.clockLine(top)
+	panel[len(panel)-1] = a.clockLine(bottom)
+	moves := moveList(a.game.StartPosition(), a.game.SAN())
+	shown := len(panel) - 2
+	moves = moves[max(0, len(moves)-shown):]
+	for i, move := range moves {
+		panel[1+i] = move
+	}
+
+	var lines []string
+	title := fmt.Sprintf("chessgo  %s vs %s", a.players[0].Name(), a.players[1].Name())
+	if !a.opts.Plain {
+		title = bold + title + resetAttributes
+	}
+	lines = append(lines, title, "")
+	for i, line := range board {
+		lines = append(lines, line+"    "+panel[i])
+	}
+
+	a.mu.Lock()
+	status, evalText := a.status, a.evalText
+	a.mu.Unlock()
+	switch {
+	case !a.eval:
+		evalText = "off"
+	case evalText == "":
+		evalText = "..."
+	}
+	lines = append(lines, "", "Eval: "+evalText, a.stateLine(pos), status)
+
+	prompt := "> " + string(a.input)
+	if !a.tty {
+		fmt.Fprintln(a.out, strings.Join(append(lines, prompt), "\n"))
+		return
+	}
+	// redraw in place, clearing what is left of each line and below the prompt
+	fmt.Fprint(a.out, "\x1b[H"+strings.Join(lines, "\x1b[K\r\n")+"\x1b[K\r\n"+prompt+"\x1b[K\x1b[J")
+}
+
+func (a *app) clockLine(white bool) string {
+	name := "Black"
+	if white {
+		name = "White"
+	}
+	marker := "  "
+	if !a.game.Over() && a.game.Position().WhiteTurn == white {
+		marker = "▶ "
+	}
+	remaining := "--:--"
+	if a.clock != nil {
+		remaining = formatClock(a.clock.Remaining(white))
+	}
+	return fmt.Sprintf("%s%-6s %s  %s", marker, name, remaining, a.players[index(white)].Name())
+}
+
+// stateLine says whose turn it is, or how the game ended.
+func (a *app) stateLine(pos handlers.Position) string {
+	if a.game.Over() {
+		return fmt.Sprintf("Game over: %s (%s)", a.game.Result(), a.game.Reason())
+	}
+	side := "White"
+	if !pos.WhiteTurn {
+		side = "Black"
+	}
+	switch {
+	case a.isEngine(pos.WhiteTurn):
+		return side + " to move, the engine is thinking..."
+	case pos.InCheck():
+		return side + " to move, in check"
+	}
+	return side + " to move"
+}
@@ -0,0 +1,60 @@
+package tui
+
+import (
+	"chess-engine/handlers"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// screen collects what the app draws; the test reads it while the app runs.
+type screen struct {
+	mu sync.Mutex
+	sb strings.Builder
+}
+
+func (s *screen) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sb.Write(p)
+}
+
+func (s *screen) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sb.String()
+}
+
+// TestAgainstEngine plays a move against the engine and waits for its reply, with
+// the screen redrawn as the engine plays.
+func TestAgainstEngine(t *testing.T) {
+	in, typing := io.Pipe()
+	out := &screen{}
+	done := make(chan error)
+	go func() {
+		done <- Run(in, out, Options{Start: handlers.StartPosition(), BlackEngine: true, MoveTime: 20 * time.Millisecond, Plain: true})
+	}()
+
+	// the last screen drawn shows the reply in the move list, and White to move
+	replied := regexp.MustCompile(`(?s)1\. e4 +\S+.*White to move`)
+	io.WriteString(typing, "e4\n")
+	deadline := time.Now().Add(5 * time.Second)
+	for !replied.MatchString(lastScreen(out.String())) {
+		if time.Now().After(deadline) {
+			t.Fatalf("the engine did not reply to e4; the screen shows\n%s", out.String())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	io.WriteString(typing, "quit\n")
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+// lastScreen returns the last screen drawn, from its title on.
+func lastScreen(text string) string {
+	return text[max(0, strings.LastIndex(text, "chessgo  ")):]
+}
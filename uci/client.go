@@ -0,0 +1,495 @@
+package uci
+
+import (
+	"bufio"
+	"chess-engine/engine"
+	"chess-engine/handlers"
+	"chess-engine/logging"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultTimeout is how long an engine gets to answer the handshake and isready, and
+// how far past its thinking time it may go before it is stopped.
+const DefaultTimeout = 10 * time.Second
+
+var logger = logging.For(logging.UCI)
+
+var (
+	ErrEngineExited = errors.New("engine exited")
+	ErrTimeout      = errors.New("engine did not answer in time")
+)
+
+// Option is an option an engine declared in the handshake.
+type Option struct {
+	Name     string
+	Type     string // check, spin, combo, button or string
+	Default  string
+	Min, Max int
+	Vars     []string // the choices of a combo
+}
+
+// Client drives an external UCI engine, usually a subprocess started with Start. It
+// implements engine.Searcher, so the app can use it wherever it uses its own engine.
+// One command runs at a time; a search or option change waits for the one before it.
+//
+// When the engine crashes, stops answering or breaks the protocol, the client kills
+// it and every later call fails with the reason, which Err returns.
+type Client struct {
+	// Name and Author are what the engine reported in the handshake.
+	Name, Author string
+	// Options are the options the engine declared, by name.
+	Options map[string]Option
+	// Timeout bounds the wait for an answer, DefaultTimeout unless changed.
+	Timeout time.Duration
+
+	cmd     *exec.Cmd // nil for a client made with NewClient
+	stdin   io.WriteCloser
+	lines   chan string // the engine's output, closed when it ends
+	exitErr error       // why the output ended, set before lines is closed
+	// failed is closed by fail; the output is then dropped instead of queued, so the
+	// reader gets to the end of it and reaps the engine even when nobody reads lines.
+	failed chan struct{}
+
+	busy    chan struct{} // holds a token while a search runs
+	multiPV int
+
+	errMu sync.Mutex
+	err   error
+}
+
+// Start launches the engine binary at path and runs the UCI handshake.
+func Start(path string, args ...string) (*Client, error) {
+	cmd := exec.Command(path, args...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	c := newClient(stdout, stdin, cmd)
+	if err := c.handshake(); err != nil {
+		c.fail(err)
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, nil
+}
+
+// NewClient runs the UCI handshake with an engine that reads commands from w and
+// writes replies to r, such as a stand-in engine in the same process.
+func NewClient(r io.Reader, w io.WriteCloser) (*Client, error) {
+	c := newClient(r, w, nil)
+	if err := c.handshake(); err != nil {
+		c.fail(err)
+		return nil, err
+	}
+	return c, nil
+}
+
+func newClient(r io.Reader, w io.WriteCloser, cmd *exec.Cmd) *Client {
+	c := &Client{
+		Options: map[string]Option{},
+		Timeout: DefaultTimeout,
+		cmd:     cmd,
+		stdin:   w,
+		lines:   make(chan string, 64),
+		failed:  make(chan struct{}),
+		busy:    make(chan struct{}, 1),
+		multiPV: 1,
+	}
+	go func() {
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			select {
+			case c.lines <- scanner.Text():
+			case <-c.failed:
+			}
+		}
+		if c.cmd != nil {
+			c.exitErr = c.cmd.Wait()
+		}
+		close(c.lines)
+	}()
+	return c
+}
+
+// handshake sends uci and reads the engine's id and options up to uciok.
+func (c *Client) handshake() error {
+	if err := c.send("uci"); err != nil {
+		return err
+	}
+	deadline := time.After(c.Timeout)
+	for {
+		line, err := c.readLine(deadline)
+		if err != nil {
+			return err
+		}
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 0:
+		case fields[0] == "uciok":
+			return nil
+		case fields[0] == "id" && len(fields) > 2 && fields[1] == "name":
+			c.Name = strings.Join(fields[2:], " ")
+		case fields[0] == "id" && len(fields) > 2 && fields[1] == "author":
+			c.Author = strings.Join(fields[2:], " ")
+		case fields[0] == "option":
+			if option, ok := parseOption(fields[1:]); ok {
+				c.Options[option.Name] = option
+			}
+		}
+	}
+}
+
+// parseOption reads the words after "option": name, type, default, min, max and var,
+// where a name, default or var value may span several words.
+func parseOption(fields []string) (Option, bool) {
+	values := map[string][]string{}
+	var vars []string
+	key := ""
+	for _, field := range fields {
+		switch field {
+		case "name", "type", "default", "min", "max":
+			key = field
+			values[key] = []string{}
+		case "var":
+			key = field
+			vars = append(vars, "")
+		default:
+			if key == "var" {
+				vars[len(vars)-1] = strings.TrimSpace(vars[len(vars)-1] + " " + field)
+			} else if key != "" {
+				values[key] = append(values[key], field)
+			}
+		}
+	}
+	option := Option{
+		Name:    strings.Join(values["name"], " "),
+		Type:    strings.Join(values["type"], " "),
+		Default: strings.Join(values["default"], " "),
+		Vars:    vars,
+	}
+	option.Min, _ = strconv.Atoi(strings.Join(values["min"], ""))
+	option.Max, _ = strconv.Atoi(strings.Join(values["max"], ""))
+	return option, option.Name != ""
+}
+
+// SetOption sets an option the engine declared; a button takes no value.
+func (c *Client) SetOption(name, value string) error {
+	c.busy <- struct{}{}
+	defer func() { <-c.busy }()
+	return c.setOption(name, value)
+}
+
+func (c *Client) setOption(name, value string) error {
+	option, ok := c.Options[name]
+	if !ok {
+		return fmt.Errorf("%s has no option %s", c.Name, name)
+	}
+	command := "setoption name " + option.Name
+	if option.Type != "button" {
+		command += " value " + value
+	}
+	if err := c.send(command); err != nil {
+		return err
+	}
+	return c.isReady()
+}
+
+// IsReady waits until the engine has caught up with the commands sent so far.
+func (c *Client) IsReady() error {
+	c.busy <- struct{}{}
+	defer func() { <-c.busy }()
+	return c.isReady()
+}
+
+func (c *Client) isReady() error {
+	if err := c.send("isready"); err != nil {
+		return err
+	}
+	deadline := time.After(c.Timeout)
+	for {
+		line, err := c.readLine(deadline)
+		if err != nil {
+			c.fail(err)
+			return err
+		}
+		if strings.TrimSpace(line) == "readyok" {
+			return nil
+		}
+	}
+}
+
+// NewGame tells the engine the next search belongs to a different game.
+func (c *Client) NewGame() error {
+	c.busy <- struct{}{}
+	defer func() { <-c.busy }()
+	if err := c.send("ucinewgame"); err != nil {
+		return err
+	}
+	return c.isReady()
+}
+
+// Search has the engine search pos within limits, reporting its info lines to onInfo,
+// like engine.Engine.Search. With no limits the engine thinks until ctx is cancelled.
+// Cancelling ctx stops the search and still returns the engine's move. ok is false
+// when there is no legal move or the engine failed, in which case Err says why.
+func (c *Client) Search(ctx context.Context, pos handlers.Position, limits engine.Limits, onInfo func(engine.Info)) (move handlers.Move, ok bool) {
+	select {
+	case c.busy <- struct{}{}:
+	case <-ctx.Done():
+		return handlers.Move{}, false
+	}
+	defer func() { <-c.busy }()
+
+	move, ok, err := c.search(ctx, pos, limits, onInfo)
+	if err != nil {
+		c.fail(err)
+		return handlers.Move{}, false
+	}
+	return move, ok
+}
+
+func (c *Client) search(ctx context.Context, pos handlers.Position, limits engine.Limits, onInfo func(engine.Info)) (handlers.Move, bool, error) {
+	if multiPV := max(limits.MultiPV, 1); multiPV != c.multiPV {
+		if _, ok := c.Options["MultiPV"]; ok {
+			if err := c.setOption("MultiPV", strconv.Itoa(multiPV)); err != nil {
+				return handlers.Move{}, false, err
+			}
+			c.multiPV = multiPV
+		}
+	}
+	if err := c.send("position fen " + pos.FEN()); err != nil {
+		return handlers.Move{}, false, err
+	}
+	if err := c.send(goCommand(limits)); err != nil {
+		return handlers.Move{}, false, err
+	}
+
+	// the engine is stopped when it runs past its time or the search is cancelled,
+	// and then has Timeout to answer with its move
+	var overtime, stopDeadline <-chan time.Time
+	if budget := limits.Budget(pos.WhiteTurn); budget > 0 {
+		overtime = time.After(budget + c.Timeout)
+	}
+	cancelled := ctx.Done()
+	stop := func() error {
+		overtime, cancelled = nil, nil
+		stopDeadline = time.After(c.Timeout)
+		return c.send("stop")
+	}
+
+	for {
+		select {
+		case line, open := <-c.lines:
+			if !open {
+				return handlers.Move{}, false, c.exitError()
+			}
+			fields := strings.Fields(line)
+			if len(fields) == 0 {
+				continue
+			}
+			switch fields[0] {
+			case "info":
+				if info, ok := parseInfo(pos, fields[1:]); ok && onInfo != nil {
+					onInfo(info)
+				}
+			case "bestmove":
+				if len(fields) < 2 || fields[1] == "0000" || fields[1] == "(none)" {
+					return handlers.Move{}, false, nil
+				}
+				m, err := pos.ParseUCI(fields[1])
+				if err != nil {
+					return handlers.Move{}, false, fmt.Errorf("engine played %w", err)
+				}
+				return m, true, nil
+			}
+		case <-cancelled:
+			if err := stop(); err != nil {
+				return handlers.Move{}, false, err
+			}
+		case <-overtime:
+			if err := stop(); err != nil {
+				return handlers.Move{}, false, err
+			}
+		case <-stopDeadline:
+			return handlers.Move{}, false, ErrTimeout
+		}
+	}
+}
+
+// goCommand writes limits as a go command; no limits at all make an infinite search.
+func goCommand(limits engine.Limits) string {
+	var args []string
+	add := func(name string, value int64) {
+		if value > 0 {
+			args = append(args, name, strconv.FormatInt(value, 10))
+		}
+	}
+	add("depth", int64(limits.Depth))
+	add("nodes", limits.Nodes)
+	add("movetime", limits.MoveTime.Milliseconds())
+	add("wtime", limits.WhiteTime.Milliseconds())
+	add("btime", limits.BlackTime.Milliseconds())
+	add("winc", limits.WhiteInc.Milliseconds())
+	add("binc", limits.BlackInc.Milliseconds())
+	add("movestogo", int64(limits.MovesToGo))
+	if len(args) == 0 {
+		return "go infinite"
+	}
+	return "go " + strings.Join(args, " ")
+}
+
+// parseInfo reads an info line that carries a principal variation. Moves of the
+// variation that are not legal end it early, so only moves on the board remain.
+func parseInfo(pos handlers.Position, fields []string) (engine.Info, bool) {
+	info := engine.Info{MultiPV: 1}
+	hasPV := false
+	number := func(i int) int64 {
+		if i >= len(fields) {
+			return 0
+		}
+		n, _ := strconv.ParseInt(fields[i], 10, 64)
+		return n
+	}
+	for i := 0; i < len(fields); i++ {
+		switch fields[i] {
+		case "depth":
+			i++
+			info.Depth = int(number(i))
+		case "multipv":
+			i++
+			info.MultiPV = int(number(i))
+		case "nodes":
+			i++
+			info.Nodes = number(i)
+		case "time":
+			i++
+			info.Time = time.Duration(number(i)) * time.Millisecond
+		case "score":
+			if i+2 < len(fields) {
+				value := int(number(i + 2))
+				if fields[i+1] == "mate" {
+					info.Mate = value
+					info.Score = engine.MateScore - 2*abs(value)
+					if value < 0 {
+						info.Score = -info.Score
+					}
+				} else {
+					info.Score = value
+				}
+				i += 2
+			}
+		case "pv":
+			hasPV = true
+			line := pos
+			for _, text := range fields[i+1:] {
+				m, err := line.ParseUCI(text)
+				if err != nil {
+					break
+				}
+				info.PV = append(info.PV, m)
+				line = line.Play(m)
+			}
+			i = len(fields)
+		case "string":
+			// the rest of the line is free text
+			i = len(fields)
+		}
+	}
+	return info, hasPV && len(info.PV) > 0
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// Err returns why the engine stopped working, or nil while it works.
+func (c *Client) Err() error {
+	c.errMu.Lock()
+	defer c.errMu.Unlock()
+	return c.err
+}
+
+// Close asks the engine to quit and kills it if it does not exit within Timeout.
+func (c *Client) Close() error {
+	c.send("quit")
+	c.stdin.Close()
+	deadline := time.After(c.Timeout)
+	for {
+		select {
+		case _, open := <-c.lines:
+			if !open {
+				return nil
+			}
+		case <-deadline:
+			c.fail(ErrTimeout)
+			return nil
+		}
+	}
+}
+
+func (c *Client) send(command string) error {
+	if err := c.Err(); err != nil {
+		return err
+	}
+	logger.Debug("sent", "engine", c.Name, "line", command)
+	if _, err := io.WriteString(c.stdin, command+"\n"); err != nil {
+		c.fail(err)
+		return err
+	}
+	return nil
+}
+
+// readLine returns the next line of output, failing when the engine exits or the deadline passes.
+func (c *Client) readLine(deadline <-chan time.Time) (string, error) {
+	select {
+	case line, open := <-c.lines:
+		if !open {
+			return "", c.exitError()
+		}
+		logger.Debug("received", "engine", c.Name, "line", line)
+		return line, nil
+	case <-deadline:
+		return "", ErrTimeout
+	}
+}
+
+func (c *Client) exitError() error {
+	if c.exitErr != nil {
+		return fmt.Errorf("%w: %v", ErrEngineExited, c.exitErr)
+	}
+	return ErrEngineExited
+}
+
+// fail records the first error and kills the engine, which cannot be trusted any more.
+func (c *Client) fail(err error) {
+	c.errMu.Lock()
+	defer c.errMu.Unlock()
+	if c.err != nil {
+		return
+	}
+	c.err = err
+	close(c.failed)
+	logger.Warn("engine failed", "engine", c.Name, "err", err)
+	if c.cmd != nil && c.cmd.Process != nil {
+		c.cmd.Process.Kill()
+	}
+}
+
+var _ engine.Searcher = (*Client)(nil)
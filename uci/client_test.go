@@ -0,0 +1,245 @@
+package uci
+
+import (
+	"chess-engine/engine"
+	"chess-engine/handlers"
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"runtime"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// startStub starts testdata/engine.sh, a stand-in engine, in the given mode.
+func startStub(t *testing.T, mode string) (*Client, error) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the stand-in engine is a shell script")
+	}
+	c, err := Start("testdata/engine.sh", mode)
+	if c != nil {
+		t.Cleanup(func() { c.Close() })
+	}
+	return c, err
+}
+
+func TestHandshake(t *testing.T) {
+	c, err := startStub(t, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Name != "Stub Engine" || c.Author != "The Tests" {
+		t.Errorf("name and author = %q, %q", c.Name, c.Author)
+	}
+	want := map[string]Option{
+		"MultiPV":    {Name: "MultiPV", Type: "spin", Default: "1", Min: 1, Max: 5},
+		"Style":      {Name: "Style", Type: "combo", Default: "Normal", Vars: []string{"Solid", "Normal", "Risky Play"}},
+		"Clear Hash": {Name: "Clear Hash", Type: "button"},
+	}
+	if !reflect.DeepEqual(c.Options, want) {
+		t.Errorf("options = %+v, want %+v", c.Options, want)
+	}
+	if err := c.SetOption("Clear Hash", ""); err != nil {
+		t.Errorf("SetOption(Clear Hash): %v", err)
+	}
+	if err := c.SetOption("Contempt", "10"); err == nil {
+		t.Errorf("SetOption of an undeclared option succeeded")
+	}
+	if err := c.NewGame(); err != nil {
+		t.Errorf("NewGame: %v", err)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	c, err := startStub(t, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := handlers.StartPosition()
+	afterE4 := start.Play(mustMove(t, start, "e2e4"))
+
+	tests := []struct {
+		name   string
+		pos    handlers.Position
+		limits engine.Limits
+		best   string
+		pvs    [][]string // the variations reported, by MultiPV number
+	}{
+		{"white", start, engine.Limits{Depth: 3}, "e2e4", [][]string{{"e2e4", "e7e5"}}},
+		{"black", afterE4, engine.Limits{Depth: 3}, "e7e5", [][]string{{"e7e5", "g1f3"}}},
+		{"multipv", start, engine.Limits{Depth: 3, MultiPV: 3}, "e2e4",
+			[][]string{{"e2e4", "e7e5"}, {"d2d4", "e7e5"}, {"g1f3", "e7e5"}}},
+		{"back to one line", start, engine.Limits{MoveTime: time.Second}, "e2e4", [][]string{{"e2e4", "e7e5"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var pvs [][]string
+			move, ok := c.Search(context.Background(), tt.pos, tt.limits, func(info engine.Info) {
+				if info.MultiPV != len(pvs)+1 || info.Depth != 3 || info.Nodes != 1200 {
+					t.Errorf("unexpected info %+v", info)
+				}
+				var pv []string
+				for _, m := range info.PV {
+					pv = append(pv, m.String())
+				}
+				pvs = append(pvs, pv)
+			})
+			if !ok || move.String() != tt.best {
+				t.Errorf("Search = %s, %v, want %s (err %v)", move, ok, tt.best, c.Err())
+			}
+			if !reflect.DeepEqual(pvs, tt.pvs) {
+				t.Errorf("variations = %v, want %v", pvs, tt.pvs)
+			}
+		})
+	}
+}
+
+func TestSearchCancelled(t *testing.T) {
+	c, err := startStub(t, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	move, ok := c.Search(ctx, handlers.StartPosition(), engine.Limits{}, nil)
+	if !ok || move.String() != "e2e4" {
+		t.Errorf("infinite search stopped with %s, %v (err %v)", move, ok, c.Err())
+	}
+}
+
+func TestEngineFailures(t *testing.T) {
+	tests := []struct {
+		name string
+		mode string
+		run  func(c *Client) error
+		want error
+	}{
+		{"crash while searching", "crash", func(c *Client) error {
+			c.Search(context.Background(), handlers.StartPosition(), engine.Limits{Depth: 1}, nil)
+			return c.Err()
+		}, ErrEngineExited},
+		{"no answer to isready", "hang", func(c *Client) error {
+			return c.IsReady()
+		}, ErrTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := startStub(t, tt.mode)
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.Timeout = 200 * time.Millisecond
+			if err := tt.run(c); !errors.Is(err, tt.want) {
+				t.Errorf("error = %v, want %v", err, tt.want)
+			}
+			if !errors.Is(c.Err(), tt.want) {
+				t.Errorf("Err() = %v, want %v", c.Err(), tt.want)
+			}
+			if _, ok := c.Search(context.Background(), handlers.StartPosition(), engine.Limits{Depth: 1}, nil); ok {
+				t.Errorf("a failed engine still searched")
+			}
+			waitReaped(t, c)
+		})
+	}
+}
+
+// TestFailedEngineReaped fails a client while the engine's output is queued and
+// nobody reads it; the engine must still be waited for.
+func TestFailedEngineReaped(t *testing.T) {
+	c, err := startStub(t, "chatty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.Search(context.Background(), handlers.StartPosition(), engine.Limits{Depth: 1}, nil); !ok {
+		t.Fatal(c.Err())
+	}
+	// let the chatter after the best move fill the queue
+	time.Sleep(100 * time.Millisecond)
+	c.fail(errors.New("given up on"))
+	waitReaped(t, c)
+}
+
+// waitReaped waits for the client to wait for the engine process, so it is not left a zombie.
+func waitReaped(t *testing.T, c *Client) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if err := c.cmd.Process.Signal(syscall.Signal(0)); errors.Is(err, os.ErrProcessDone) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Errorf("the engine process was not waited for")
+}
+
+func TestGoCommand(t *testing.T) {
+	tests := []struct {
+		limits engine.Limits
+		want   string
+	}{
+		{engine.Limits{}, "go infinite"},
+		{engine.Limits{MultiPV: 3}, "go infinite"},
+		{engine.Limits{Depth: 12}, "go depth 12"},
+		{engine.Limits{Nodes: 5000, MoveTime: 1500 * time.Millisecond}, "go nodes 5000 movetime 1500"},
+		{engine.Limits{WhiteTime: time.Minute, BlackTime: 50 * time.Second, WhiteInc: 2 * time.Second, BlackInc: 2 * time.Second, MovesToGo: 20},
+			"go wtime 60000 btime 50000 winc 2000 binc 2000 movestogo 20"},
+	}
+	for _, tt := range tests {
+		if got := goCommand(tt.limits); got != tt.want {
+			t.Errorf("goCommand(%+v) = %q, want %q", tt.limits, got, tt.want)
+		}
+	}
+}
+
+func TestParseInfo(t *testing.T) {
+	start := handlers.StartPosition()
+	tests := []struct {
+		line  string
+		ok    bool
+		want  engine.Info
+		moves string
+	}{
+		{"depth 5 seldepth 8 multipv 2 score cp -35 nodes 9000 nps 100 time 90 pv d2d4 d7d5", true,
+			engine.Info{Depth: 5, MultiPV: 2, Score: -35, Nodes: 9000, Time: 90 * time.Millisecond}, "d2d4 d7d5"},
+		{"depth 9 score mate 2 pv e2e4", true,
+			engine.Info{Depth: 9, MultiPV: 1, Score: engine.MateScore - 4, Mate: 2}, "e2e4"},
+		{"depth 9 score mate -3 pv e2e4", true,
+			engine.Info{Depth: 9, MultiPV: 1, Score: -(engine.MateScore - 6), Mate: -3}, "e2e4"},
+		{"depth 4 score cp 10 pv e2e4 e2e4 d7d5", true,
+			engine.Info{Depth: 4, MultiPV: 1, Score: 10}, "e2e4"},
+		{"depth 4 currmove e2e4 currmovenumber 1", false, engine.Info{}, ""},
+		{"string pv e2e4", false, engine.Info{}, ""},
+		{"depth 4 pv e2e5", false, engine.Info{}, ""},
+	}
+	for _, tt := range tests {
+		info, ok := parseInfo(start, strings.Fields(tt.line))
+		if ok != tt.ok {
+			t.Errorf("parseInfo(%q) ok = %v, want %v", tt.line, ok, tt.ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		var moves []string
+		for _, m := range info.PV {
+			moves = append(moves, m.String())
+		}
+		info.PV = nil
+		if !reflect.DeepEqual(info, tt.want) || strings.Join(moves, " ") != tt.moves {
+			t.Errorf("parseInfo(%q) = %+v %v, want %+v %s", tt.line, info, moves, tt.want, tt.moves)
+		}
+	}
+}
+
+func mustMove(t *testing.T, pos handlers.Position, text string) handlers.Move {
+	t.Helper()
+	m, err := pos.ParseUCI(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
@@ -0,0 +1,343 @@
+// Package uci speaks the Universal Chess Interface, the text protocol chess GUIs and
+// testing tools use to run engines. It has no GUI dependencies.
+package uci
+
+import (
+	"bufio"
+	"chess-engine/engine"
+	"chess-engine/handlers"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EngineName is how the engine introduces itself to GUIs.
+const EngineName = "chessgo"
+
+// maxMultiPV is the most lines the MultiPV option allows.
+const maxMultiPV = 64
+
+// Server answers the UCI commands of a GUI for one engine. Commands are read one
+// line at a time; searches run in the background so stop and isready are answered
+// while the engine thinks.
+type Server struct {
+	engine *engine.Engine
+
+	outMu sync.Mutex
+	out   io.Writer
+
+	pos     handlers.Position
+	multiPV int
+	search  *searchJob // the last search started, nil before the first go
+}
+
+// searchJob is a search started by go.
+type searchJob struct {
+	cancel context.CancelFunc
+	done   chan struct{} // closed once bestmove is sent
+
+	mu sync.Mutex
+	// held searches, infinite or pondering, keep their bestmove until stop or
+	// ponderhit closes release
+	held    bool
+	release chan struct{}
+
+	// ponder searches get budget as their thinking time when the opponent plays the expected move
+	ponder bool
+	budget time.Duration
+}
+
+// NewServer returns a server for e that writes its replies to out.
+func NewServer(e *engine.Engine, out io.Writer) *Server {
+	return &Server{engine: e, out: out, pos: handlers.StartPosition(), multiPV: 1}
+}
+
+// Run reads commands from in until quit or the end of the input, then stops any search.
+func (s *Server) Run(in io.Reader) error {
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		if !s.Handle(scanner.Text()) {
+			return nil
+		}
+	}
+	s.stop()
+	return scanner.Err()
+}
+
+// Handle carries out one command line and reports whether to keep reading; it is
+// false after quit. Unknown commands are answered with an info string and ignored.
+func (s *Server) Handle(line string) bool {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return true
+	}
+	command, args := fields[0], fields[1:]
+	switch command {
+	case "uci":
+		s.send("id name " + EngineName)
+		s.send("id author the " + EngineName + " authors")
+		s.send(fmt.Sprintf("option name Hash type spin default %d min 1 max %d", engine.DefaultHash, engine.MaxHash))
+		s.send(fmt.Sprintf("option name Threads type spin default 1 min 1 max %d", engine.MaxThreads))
+		s.send(fmt.Sprintf("option name MultiPV type spin default 1 min 1 max %d", maxMultiPV))
+		s.send("option name Ponder type check default false")
+		s.send("option name Clear Hash type button")
+		s.send("uciok")
+	case "isready":
+		s.send("readyok")
+	case "debug", "register":
+		// nothing to switch on and nothing to register
+	case "setoption":
+		s.setOption(args)
+	case "ucinewgame":
+		s.stop()
+		s.engine.Clear()
+		s.pos = handlers.StartPosition()
+	case "position":
+		s.position(args)
+	case "go":
+		s.goSearch(args)
+	case "stop":
+		s.stop()
+	case "ponderhit":
+		s.ponderHit()
+	case "quit":
+		s.stop()
+		return false
+	default:
+		s.send("info string unknown command " + command)
+	}
+	return true
+}
+
+func (s *Server) send(line string) {
+	s.outMu.Lock()
+	defer s.outMu.Unlock()
+	io.WriteString(s.out, line+"\n")
+}
+
+// setOption handles "setoption name <id> [value <x>]"; the name may contain spaces.
+func (s *Server) setOption(args []string) {
+	var name, value []string
+	target := &name
+	for _, arg := range args {
+		switch arg {
+		case "name":
+			target = &name
+		case "value":
+			target = &value
+		default:
+			*target = append(*target, arg)
+		}
+	}
+
+	s.stop()
+	optionName := strings.ToLower(strings.Join(name, " "))
+	number, err := strconv.Atoi(strings.Join(value, " "))
+	switch {
+	case optionName == "clear hash":
+		s.engine.Clear()
+	case optionName == "ponder":
+		// pondering needs nothing set up, go ponder is enough
+	case err != nil:
+		s.send(fmt.Sprintf("info string option %s needs a number", strings.Join(name, " ")))
+	case optionName == "hash":
+		s.engine.SetHash(number)
+	case optionName == "threads":
+		s.engine.SetThreads(number)
+	case optionName == "multipv":
+		s.multiPV = min(max(number, 1), maxMultiPV)
+	default:
+		s.send("info string unknown option " + strings.Join(name, " "))
+	}
+}
+
+// position handles "position startpos|fen <fen> [moves <move>...]". A position that
+// cannot be read leaves the current one in place.
+func (s *Server) position(args []string) {
+	if len(args) == 0 {
+		s.send("info string position needs startpos or fen")
+		return
+	}
+	movesAt := len(args)
+	for i, arg := range args {
+		if arg == "moves" {
+			movesAt = i
+			break
+		}
+	}
+
+	var pos handlers.Position
+	switch args[0] {
+	case "startpos":
+		pos = handlers.StartPosition()
+	case "fen":
+		var err error
+		pos, err = handlers.ParseFEN(strings.Join(args[1:movesAt], " "))
+		if err == nil {
+			err = pos.Validate()
+		}
+		if err != nil {
+			s.send("info string invalid FEN: " + err.Error())
+			return
+		}
+	default:
+		s.send("info string position needs startpos or fen")
+		return
+	}
+
+	for _, text := range args[min(movesAt+1, len(args)):] {
+		m, err := pos.ParseUCI(text)
+		if err != nil {
+			s.send("info string " + err.Error())
+			return
+		}
+		pos = pos.Play(m)
+	}
+	s.pos = pos
+}
+
+// goSearch handles go: it starts a search in the background that prints info lines
+// for every depth and bestmove at the end.
+func (s *Server) goSearch(args []string) {
+	s.stop()
+	limits, infinite, ponder := parseGo(args)
+	limits.MultiPV = s.multiPV
+
+	job := &searchJob{done: make(chan struct{}), release: make(chan struct{}), ponder: ponder}
+	if infinite || ponder {
+		job.held = true
+	} else {
+		close(job.release)
+	}
+	if ponder {
+		// the clock only starts once the opponent plays the move we ponder on
+		job.budget = limits.Budget(s.pos.WhiteTurn)
+		limits.MoveTime, limits.WhiteTime, limits.BlackTime = 0, 0, 0
+	}
+	var ctx context.Context
+	ctx, job.cancel = context.WithCancel(context.Background())
+	s.search = job
+
+	pos := s.pos
+	go func() {
+		defer close(job.done)
+		defer job.cancel()
+		var pv []handlers.Move
+		move, ok := s.engine.Search(ctx, pos, limits, func(info engine.Info) {
+			s.send(formatInfo(info))
+			if info.MultiPV == 1 {
+				pv = info.PV
+			}
+		})
+		<-job.release
+		switch {
+		case !ok:
+			s.send("bestmove 0000")
+		case len(pv) > 1 && pv[0] == move:
+			s.send("bestmove " + move.String() + " ponder " + pv[1].String())
+		default:
+			s.send("bestmove " + move.String())
+		}
+	}()
+}
+
+// parseGo reads the limits of a go command. Unknown parameters, such as
+// searchmoves, are ignored.
+func parseGo(args []string) (limits engine.Limits, infinite, ponder bool) {
+	millis := func(i int) time.Duration {
+		n, _ := strconv.Atoi(args[i])
+		return time.Duration(n) * time.Millisecond
+	}
+	for i := 0; i < len(args); i++ {
+		hasValue := i+1 < len(args)
+		switch {
+		case args[i] == "infinite":
+			infinite = true
+		case args[i] == "ponder":
+			ponder = true
+		case !hasValue:
+		case args[i] == "depth":
+			i++
+			limits.Depth, _ = strconv.Atoi(args[i])
+		case args[i] == "nodes":
+			i++
+			limits.Nodes, _ = strconv.ParseInt(args[i], 10, 64)
+		case args[i] == "movetime":
+			i++
+			limits.MoveTime = millis(i)
+		case args[i] == "wtime":
+			i++
+			// a flagged clock still has to bound the search, zero would lift the limit
+			limits.WhiteTime = max(millis(i), time.Millisecond)
+		case args[i] == "btime":
+			i++
+			limits.BlackTime = max(millis(i), time.Millisecond)
+		case args[i] == "winc":
+			i++
+			limits.WhiteInc = millis(i)
+		case args[i] == "binc":
+			i++
+			limits.BlackInc = millis(i)
+		case args[i] == "movestogo":
+			i++
+			limits.MovesToGo, _ = strconv.Atoi(args[i])
+		}
+	}
+	return limits, infinite, ponder
+}
+
+// formatInfo writes a search report as an info line.
+func formatInfo(info engine.Info) string {
+	score := fmt.Sprintf("cp %d", info.Score)
+	if info.Mate != 0 {
+		score = fmt.Sprintf("mate %d", info.Mate)
+	}
+	millis := info.Time.Milliseconds()
+	nps := info.Nodes * 1000 / max(millis, 1)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "info depth %d multipv %d score %s nodes %d nps %d time %d pv",
+		info.Depth, info.MultiPV, score, info.Nodes, nps, millis)
+	for _, m := range info.PV {
+		sb.WriteString(" " + m.String())
+	}
+	return sb.String()
+}
+
+// stop ends the running search, if any, and waits for its bestmove.
+func (s *Server) stop() {
+	job := s.search
+	if job == nil {
+		return
+	}
+	job.cancel()
+	job.unhold()
+	<-job.done
+}
+
+// ponderHit turns a ponder search into a normal one: the opponent played the
+// expected move, so the search now gets the time it was given.
+func (s *Server) ponderHit() {
+	job := s.search
+	if job == nil || !job.ponder {
+		return
+	}
+	if job.budget > 0 {
+		time.AfterFunc(job.budget, job.cancel)
+	}
+	job.unhold()
+}
+
+// unhold lets a held search send its bestmove as soon as it finishes.
+func (j *searchJob) unhold() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.held {
+		j.held = false
+		close(j.release)
+	}
+}
@@ -0,0 +1,236 @@
+package uci
+
+import (
+	"chess-engine/engine"
+	"chess-engine/handlers"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// output collects what the server sends; the search goroutine writes to it too.
+type output struct {
+	mu sync.Mutex
+	sb strings.Builder
+}
+
+func (o *output) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.sb.Write(p)
+}
+
+func (o *output) lines() []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return strings.Split(strings.TrimSuffix(o.sb.String(), "\n"), "\n")
+}
+
+// bestMove returns the bestmove line, "" if the server has not sent one.
+func (o *output) bestMove() string {
+	for _, line := range o.lines() {
+		if strings.HasPrefix(line, "bestmove ") {
+			return line
+		}
+	}
+	return ""
+}
+
+// waitBestMove waits for the bestmove line, failing the test if none comes.
+func (o *output) waitBestMove(t *testing.T) string {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		if line := o.bestMove(); line != "" {
+			return line
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("no bestmove, output %q", o.lines())
+	return ""
+}
+
+// run starts a server and hands it the commands, one per line.
+func run(t *testing.T, commands ...string) (*Server, *output) {
+	t.Helper()
+	out := &output{}
+	s := NewServer(engine.New(), out)
+	for _, command := range commands {
+		if !s.Handle(command) {
+			break
+		}
+	}
+	t.Cleanup(func() { s.Handle("quit") })
+	return s, out
+}
+
+func TestHandle(t *testing.T) {
+	tests := []struct {
+		name     string
+		commands []string
+		out      []string // "" for no output at all, a line ending in "..." matches by prefix
+		fen      string   // the position afterwards, "" to skip the check
+	}{
+		{"uci", []string{"uci"}, []string{
+			"id name chessgo", "id author the chessgo authors",
+			"option name Hash ...", "option name Threads ...", "option name MultiPV ...",
+			"option name Ponder type check default false", "option name Clear Hash type button",
+			"uciok",
+		}, handlers.StartFEN},
+		{"isready", []string{"isready"}, []string{"readyok"}, ""},
+		{"unknown command", []string{"castle"}, []string{"info string unknown command castle"}, ""},
+		{"ignored commands", []string{"debug on", "register later"}, []string{""}, handlers.StartFEN},
+		{"startpos with moves", []string{"position startpos moves e2e4 c7c5"}, []string{""},
+			"rnbqkbnr/pp1ppppp/8/2p5/4P3/8/PPPP1PPP/RNBQKBNR w KQkq c6 0 2"},
+		{"fen with moves", []string{"position fen 4k3/8/8/8/8/8/4P3/4K3 w - - 0 1 moves e2e4"}, []string{""},
+			"4k3/8/8/8/4P3/8/8/4K3 b - e3 0 1"},
+		{"illegal move", []string{"position startpos moves e2e4 e7e4"},
+			[]string{"info string e7e4 is not a legal move: ..."}, handlers.StartFEN},
+		{"broken FEN", []string{"position fen rubbish"}, []string{"info string invalid FEN: ..."}, handlers.StartFEN},
+		{"impossible FEN", []string{"position fen 8/8/8/8/8/8/8/8 w - - 0 1"}, []string{"info string invalid FEN: ..."}, handlers.StartFEN},
+		{"position without a start", []string{"position moves e2e4"}, []string{"info string position needs startpos or fen"}, handlers.StartFEN},
+		{"ucinewgame", []string{"position startpos moves d2d4", "ucinewgame"}, []string{""}, handlers.StartFEN},
+		{"hash and threads", []string{"setoption name Hash value 16", "setoption name Threads value 2"}, []string{""}, ""},
+		{"clear hash", []string{"setoption name Clear Hash"}, []string{""}, ""},
+		{"option without a number", []string{"setoption name Threads value many"},
+			[]string{"info string option Threads needs a number"}, ""},
+		{"unknown option", []string{"setoption name Style value 3"}, []string{"info string unknown option Style"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, out := run(t, tt.commands...)
+			got := out.lines()
+			if len(got) != len(tt.out) {
+				t.Fatalf("output %q, want %q", got, tt.out)
+			}
+			for i, want := range tt.out {
+				if prefix, ok := strings.CutSuffix(want, "..."); ok && strings.HasPrefix(got[i], prefix) {
+					continue
+				}
+				if got[i] != want {
+					t.Errorf("output line %d = %q, want %q", i, got[i], want)
+				}
+			}
+			if tt.fen != "" && s.pos.FEN() != tt.fen {
+				t.Errorf("position %s, want %s", s.pos.FEN(), tt.fen)
+			}
+		})
+	}
+}
+
+func TestMultiPVOption(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int
+	}{
+		{"3", 3},
+		{"0", 1},
+		{"1000", maxMultiPV},
+	}
+	for _, tt := range tests {
+		s, _ := run(t, "setoption name MultiPV value "+tt.value)
+		if s.multiPV != tt.want {
+			t.Errorf("MultiPV %s sets %d lines, want %d", tt.value, s.multiPV, tt.want)
+		}
+	}
+}
+
+func TestLimits(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     string
+		want     engine.Limits
+		infinite bool
+		ponder   bool
+	}{
+		{"depth", "depth 4", engine.Limits{Depth: 4}, false, false},
+		{"nodes", "nodes 20000", engine.Limits{Nodes: 20000}, false, false},
+		{"movetime", "movetime 1500", engine.Limits{MoveTime: 1500 * time.Millisecond}, false, false},
+		{"clock", "wtime 60000 btime 50000 winc 1000 binc 500 movestogo 20", engine.Limits{
+			WhiteTime: time.Minute, BlackTime: 50 * time.Second, WhiteInc: time.Second, BlackInc: 500 * time.Millisecond, MovesToGo: 20,
+		}, false, false},
+		{"flag down", "wtime 0 btime -5", engine.Limits{WhiteTime: time.Millisecond, BlackTime: time.Millisecond}, false, false},
+		{"infinite", "infinite", engine.Limits{}, true, false},
+		{"ponder", "ponder wtime 1000 btime 2000", engine.Limits{WhiteTime: time.Second, BlackTime: 2 * time.Second}, false, true},
+		{"searchmoves ignored", "searchmoves e2e4 d2d4 depth 3", engine.Limits{Depth: 3}, false, false},
+		{"missing value", "depth", engine.Limits{}, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limits, infinite, ponder := parseGo(strings.Fields(tt.args))
+			if !reflect.DeepEqual(limits, tt.want) || infinite != tt.infinite || ponder != tt.ponder {
+				t.Errorf("parseGo(%s) = %+v, %v, %v, want %+v, %v, %v", tt.args, limits, infinite, ponder, tt.want, tt.infinite, tt.ponder)
+			}
+		})
+	}
+}
+
+func TestEngineMoves(t *testing.T) {
+	tests := []struct {
+		name     string
+		commands []string
+		release  string // the command that lets a held search answer, "" if it is not held
+		multiPV  int    // the lines reported for every depth
+	}{
+		{"depth", []string{"go depth 2"}, "", 1},
+		{"movetime", []string{"position startpos moves e2e4", "go movetime 50"}, "", 1},
+		{"hash and threads", []string{"setoption name Hash value 8", "setoption name Threads value 2", "go depth 3"}, "", 1},
+		{"multipv", []string{"setoption name MultiPV value 3", "go depth 2"}, "", 3},
+		{"infinite until stop", []string{"go infinite"}, "stop", 1},
+		{"ponder until ponderhit", []string{"go ponder movetime 50"}, "ponderhit", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, out := run(t, tt.commands...)
+			if tt.release != "" {
+				time.Sleep(100 * time.Millisecond)
+				if line := out.bestMove(); line != "" {
+					t.Fatalf("%s before %s", line, tt.release)
+				}
+				// a held search still answers isready
+				s.Handle("isready")
+				if lines := out.lines(); !strings.Contains(strings.Join(lines, "\n"), "readyok") {
+					t.Errorf("no readyok while searching, output %q", lines)
+				}
+				s.Handle(tt.release)
+			}
+			fields := strings.Fields(out.waitBestMove(t))
+			move, err := s.pos.ParseUCI(fields[1])
+			if err != nil {
+				t.Fatalf("bestmove %s: %v", fields[1], err)
+			}
+			if len(fields) == 4 {
+				if _, err := s.pos.Play(move).ParseUCI(fields[3]); err != nil {
+					t.Errorf("ponder move %s: %v", fields[3], err)
+				}
+			}
+
+			// every completed depth reports each of its lines once
+			lines := map[int][]int{}
+			for _, line := range out.lines() {
+				info := strings.Fields(line)
+				if len(info) < 5 || info[0] != "info" || info[1] != "depth" || info[3] != "multipv" {
+					continue
+				}
+				depth, _ := strconv.Atoi(info[2])
+				multiPV, _ := strconv.Atoi(info[4])
+				lines[depth] = append(lines[depth], multiPV)
+			}
+			if len(lines[1]) == 0 {
+				t.Fatalf("no info lines for depth 1, output %q", out.lines())
+			}
+			for depth, got := range lines {
+				want := make([]int, tt.multiPV)
+				for i := range want {
+					want[i] = i + 1
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("depth %d reports lines %v, want %v", depth, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+#!/bin/sh
+# A stand-in UCI engine for the client tests. It knows no chess: it plays e2e4 with
+# White and e7e5 with Black, offering the next moves of a fixed list as further
+# lines. The first argument makes it misbehave:
+#   crash   exits when told to search
+#   hang    never answers isready
+#   chatty  keeps talking after its best move
+
+mode=$1
+multipv=1
+side=w
+searching=
+
+answer() {
+	if [ "$side" = w ]; then
+		moves="e2e4 d2d4 g1f3 c2c4 b1c3"
+		reply=e7e5
+	else
+		moves="e7e5 d7d5 g8f6 c7c5 b8c6"
+		reply=g1f3
+	fi
+	n=1
+	for move in $moves; do
+		[ $n -gt $multipv ] && break
+		echo "info depth 3 multipv $n score cp $((40 - 10 * n)) nodes 1200 time 5 pv $move $reply"
+		n=$((n + 1))
+	done
+	echo "bestmove ${moves%% *}"
+	if [ "$mode" = chatty ]; then
+		i=0
+		while [ $i -lt 500 ]; do
+			echo "info string still here $i"
+			i=$((i + 1))
+		done
+	fi
+}
+
+while read -r line; do
+	set -- $line
+	case $1 in
+	uci)
+		echo "id name Stub Engine"
+		echo "id author The Tests"
+		echo "option name MultiPV type spin default 1 min 1 max 5"
+		echo "option name Style type combo default Normal var Solid var Normal var Risky Play"
+		echo "option name Clear Hash type button"
+		echo "uciok"
+		;;
+	isready)
+		[ "$mode" = hang ] || echo readyok
+		;;
+	setoption)
+		# setoption name MultiPV value <n>
+		[ "$3" = MultiPV ] && multipv=$5
+		;;
+	position)
+		# position fen <placement> <side> ...
+		side=$4
+		;;
+	go)
+		[ "$mode" = crash ] && exit 1
+		if [ "$2" = infinite ]; then
+			searching=1
+		else
+			answer
+		fi
+		;;
+	stop)
+		if [ -n "$searching" ]; then
+			searching=
+			answer
+		fi
+		;;
+	quit)
+		exit 0
+		;;
+	esac
+done